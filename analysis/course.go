@@ -0,0 +1,238 @@
+package analysis
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// CourseMatchDistance is how far in meters an activity may stray from a
+// course while still counting as on course
+const CourseMatchDistance = 50.0
+
+// gpxFile is the subset of the GPX 1.1 format used for courses
+type gpxFile struct {
+	XMLName  xml.Name `xml:"gpx"`
+	Version  string   `xml:"version,attr"`
+	Creator  string   `xml:"creator,attr"`
+	Xmlns    string   `xml:"xmlns,attr,omitempty"`
+	Metadata *struct {
+		Name string `xml:"name,omitempty"`
+		Desc string `xml:"desc,omitempty"`
+	} `xml:"metadata,omitempty"`
+	Tracks []gpxTrack `xml:"trk"`
+	Routes []gpxRoute `xml:"rte"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name,omitempty"`
+	Desc     string       `xml:"desc,omitempty"`
+	Type     string       `xml:"type,omitempty"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxRoute struct {
+	Name   string     `xml:"name,omitempty"`
+	Desc   string     `xml:"desc,omitempty"`
+	Type   string     `xml:"type,omitempty"`
+	Points []gpxPoint `xml:"rtept"`
+}
+
+type gpxPoint struct {
+	Lat       float64  `xml:"lat,attr"`
+	Lon       float64  `xml:"lon,attr"`
+	Elevation *float64 `xml:"ele,omitempty"`
+}
+
+// ParseGPXCourse reads the tracks, or else the routes, of a GPX file as a course
+func ParseGPXCourse(path string) (*gormin.Course, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GPX file: %w", err)
+	}
+
+	var gpx gpxFile
+	if err := xml.Unmarshal(data, &gpx); err != nil {
+		return nil, fmt.Errorf("failed to parse GPX file: %w", err)
+	}
+
+	course := &gormin.Course{Sport: "generic", Source: "gpx", FilePath: path}
+	if gpx.Metadata != nil {
+		course.Name, course.Description = gpx.Metadata.Name, gpx.Metadata.Desc
+	}
+
+	var points []gpxPoint
+	for _, trk := range gpx.Tracks {
+		if course.Name == "" {
+			course.Name, course.Description = trk.Name, trk.Desc
+		}
+		if trk.Type != "" {
+			course.Sport = trk.Type
+		}
+		for _, seg := range trk.Segments {
+			points = append(points, seg.Points...)
+		}
+	}
+	if len(points) == 0 {
+		for _, rte := range gpx.Routes {
+			if course.Name == "" {
+				course.Name, course.Description = rte.Name, rte.Desc
+			}
+			if rte.Type != "" {
+				course.Sport = rte.Type
+			}
+			points = append(points, rte.Points...)
+		}
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("GPX file has no track or route points")
+	}
+
+	if course.Name == "" {
+		course.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	for _, p := range points {
+		point := gormin.CoursePoint{Latitude: p.Lat, Longitude: p.Lon}
+		if p.Elevation != nil {
+			point.Elevation = *p.Elevation
+		}
+		course.Points = append(course.Points, point)
+	}
+
+	gormin.FinishCourse(course)
+	return course, nil
+}
+
+// WriteGPXCourse writes a course as a GPX track
+func WriteGPXCourse(course *gormin.Course, path string) error {
+	gpx := gpxFile{
+		Version: "1.1",
+		Creator: "GarminDB-Go",
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+	}
+	track := gpxTrack{Name: course.Name, Desc: course.Description, Type: course.Sport}
+	var segment gpxSegment
+	for _, p := range course.Points {
+		elevation := p.Elevation
+		segment.Points = append(segment.Points, gpxPoint{Lat: p.Latitude, Lon: p.Longitude, Elevation: &elevation})
+	}
+	track.Segments = []gpxSegment{segment}
+	gpx.Tracks = []gpxTrack{track}
+
+	data, err := xml.MarshalIndent(gpx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode GPX: %w", err)
+	}
+	data = append([]byte(xml.Header), data...)
+	data = append(data, '\n')
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write GPX file: %w", err)
+	}
+	return nil
+}
+
+// CourseComparison summarises how closely an activity followed a course
+type CourseComparison struct {
+	PlannedDistance float64 // km
+	ActualDistance  float64 // km
+	PlannedAscent   int
+	ActualAscent    int
+	MeanDeviation   float64 // meters
+	MaxDeviation    float64 // meters
+	OnCourse        float64 // fraction of activity points near the course
+	Covered         float64 // fraction of course points the activity passed
+}
+
+// CompareCourse compares the GPS track of an activity with a course
+func CompareCourse(course *gormin.Course, records []gormin.ActivityRecord) (*CourseComparison, error) {
+	var track []gormin.ActivityRecord
+	for _, r := range records {
+		if r.Latitude != 0 || r.Longitude != 0 {
+			track = append(track, r)
+		}
+	}
+	if len(track) == 0 {
+		return nil, fmt.Errorf("activity has no GPS track")
+	}
+	if len(course.Points) < 2 {
+		return nil, fmt.Errorf("course has no track")
+	}
+
+	result := &CourseComparison{
+		PlannedDistance: course.Distance,
+		PlannedAscent:   course.TotalAscent,
+	}
+
+	var deviationSum float64
+	var onCourse int
+	elevations := make([]float64, len(track))
+	for i, r := range track {
+		d := distanceToCourse(course.Points, r.Latitude, r.Longitude)
+		deviationSum += d
+		if d > result.MaxDeviation {
+			result.MaxDeviation = d
+		}
+		if d <= CourseMatchDistance {
+			onCourse++
+		}
+		elevations[i] = r.Altitude
+		if r.Distance/1000.0 > result.ActualDistance {
+			result.ActualDistance = r.Distance / 1000.0
+		}
+	}
+	result.MeanDeviation = deviationSum / float64(len(track))
+	result.OnCourse = float64(onCourse) / float64(len(track))
+	ascent, _ := gormin.ElevationChange(elevations)
+	result.ActualAscent = int(math.Round(ascent))
+
+	var covered int
+	for _, p := range course.Points {
+		for _, r := range track {
+			if gormin.Haversine(p.Latitude, p.Longitude, r.Latitude, r.Longitude) <= CourseMatchDistance {
+				covered++
+				break
+			}
+		}
+	}
+	result.Covered = float64(covered) / float64(len(course.Points))
+
+	return result, nil
+}
+
+// distanceToCourse returns the distance in meters from a position to the
+// nearest segment of a course, using an equirectangular projection around
+// the position which is accurate over the short distances involved
+func distanceToCourse(points []gormin.CoursePoint, lat, lon float64) float64 {
+	toRad := math.Pi / 180.0
+	cosLat := math.Cos(lat * toRad)
+	project := func(pLat, pLon float64) (x, y float64) {
+		return (pLon - lon) * toRad * cosLat * gormin.EarthRadius, (pLat - lat) * toRad * gormin.EarthRadius
+	}
+
+	best := math.Inf(1)
+	for i := 1; i < len(points); i++ {
+		ax, ay := project(points[i-1].Latitude, points[i-1].Longitude)
+		bx, by := project(points[i].Latitude, points[i].Longitude)
+
+		// Closest point to the origin on segment a-b
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if length := dx*dx + dy*dy; length > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/length))
+		}
+		if d := math.Hypot(ax+t*dx, ay+t*dy); d < best {
+			best = d
+		}
+	}
+	return best
+}
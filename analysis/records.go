@@ -0,0 +1,162 @@
+// Package analysis computes best efforts and personal records from activity
+// samples, and reads, writes and compares courses.
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// bestEffortDistances are the distances in meters searched for the fastest
+// efforts of running activities
+var bestEffortDistances = []struct {
+	effort string
+	meters float64
+}{
+	{"400m", 400},
+	{"1k", 1000},
+	{"1mi", 1609.344},
+	{"5k", 5000},
+	{"10k", 10000},
+	{"half_marathon", 21097.5},
+	{"marathon", 42195},
+}
+
+// recordHigherIsBetter reports whether a larger value beats a record, as for
+// longest distances, rather than a smaller one as for fastest times
+func recordHigherIsBetter(recordType string) bool {
+	return !isFastestEffort(recordType)
+}
+
+// isFastestEffort reports whether a record type is a fastest time over a distance
+func isFastestEffort(recordType string) bool {
+	for _, d := range bestEffortDistances {
+		if d.effort == recordType {
+			return true
+		}
+	}
+	return strings.HasPrefix(recordType, "fastest_")
+}
+
+// ParseRecordTime parses an activity record timestamp as stored or as read
+// back from the database
+func ParseRecordTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02 15:04:05", value)
+}
+
+// BestEffortsFromRecords finds the fastest efforts over the standard
+// distances of a running activity and the distance of a run or ride
+func BestEffortsFromRecords(activityType string, records []gormin.ActivityRecord) []gormin.BestEffort {
+	running := strings.Contains(activityType, "running")
+	cycling := strings.Contains(activityType, "cycling") || strings.Contains(activityType, "biking")
+	if !running && !cycling {
+		return nil
+	}
+
+	// Distance over time, skipping samples without distance or going backwards
+	var times []time.Time
+	var distances []float64
+	for _, r := range records {
+		t, err := ParseRecordTime(r.Timestamp)
+		if err != nil || (r.Distance == 0 && len(distances) > 0) {
+			continue
+		}
+		if len(distances) > 0 && r.Distance < distances[len(distances)-1] {
+			continue
+		}
+		times = append(times, t)
+		distances = append(distances, r.Distance)
+	}
+	if len(distances) < 2 {
+		return nil
+	}
+
+	var efforts []gormin.BestEffort
+	total := distances[len(distances)-1]
+	if running {
+		efforts = append(efforts, gormin.BestEffort{Effort: "longest_run", Value: total, StartTime: times[0].Format("2006-01-02 15:04:05")})
+		for _, d := range bestEffortDistances {
+			if effort, ok := fastestEffort(times, distances, d.meters); ok {
+				effort.Effort = d.effort
+				efforts = append(efforts, effort)
+			}
+		}
+	} else {
+		efforts = append(efforts, gormin.BestEffort{Effort: "longest_ride", Value: total, StartTime: times[0].Format("2006-01-02 15:04:05")})
+	}
+	return efforts
+}
+
+// fastestEffort finds the shortest time to cover meters using a sliding
+// window, interpolating the start of the window between samples
+func fastestEffort(times []time.Time, distances []float64, meters float64) (gormin.BestEffort, bool) {
+	best := math.Inf(1)
+	var bestStart time.Time
+
+	i := 0
+	for j := 1; j < len(distances); j++ {
+		for i+1 < j && distances[j]-distances[i+1] >= meters {
+			i++
+		}
+		if distances[j]-distances[i] < meters {
+			continue
+		}
+
+		start := times[i]
+		if span := distances[i+1] - distances[i]; span > 0 {
+			fraction := (distances[j] - meters - distances[i]) / span
+			start = start.Add(time.Duration(fraction * float64(times[i+1].Sub(times[i]))))
+		}
+		if seconds := times[j].Sub(start).Seconds(); seconds > 0 && seconds < best {
+			best, bestStart = seconds, start
+		}
+	}
+
+	if math.IsInf(best, 1) {
+		return gormin.BestEffort{}, false
+	}
+	return gormin.BestEffort{Value: math.Round(best*10) / 10, StartTime: bestStart.Format("2006-01-02 15:04:05")}, true
+}
+
+// BeatsRecord reports whether value beats the record value
+func BeatsRecord(recordType string, value, record float64) bool {
+	if recordHigherIsBetter(recordType) {
+		return value > record
+	}
+	return value < record
+}
+
+// FormatRecordValue formats a record value in the units of its type
+func FormatRecordValue(recordType string, value float64) string {
+	switch {
+	case isFastestEffort(recordType):
+		return FormatDuration(value)
+	case strings.HasPrefix(recordType, "longest_goal"):
+		return fmt.Sprintf("%.0f days", value)
+	case strings.HasPrefix(recordType, "longest_"):
+		return fmt.Sprintf("%.2f km", value/1000.0)
+	case strings.HasPrefix(recordType, "most_steps"):
+		return fmt.Sprintf("%.0f steps", value)
+	case strings.HasPrefix(recordType, "most_ascent"):
+		return fmt.Sprintf("%.0f m", value)
+	case strings.HasPrefix(recordType, "max_power"):
+		return fmt.Sprintf("%.0f W", value)
+	}
+	return fmt.Sprintf("%g", value)
+}
+
+// FormatDuration formats seconds as h:mm:ss or m:ss
+func FormatDuration(seconds float64) string {
+	total := int(math.Round(seconds))
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+	}
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
@@ -0,0 +1,667 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/saram12saram2/gormin"
+	"github.com/saram12saram2/gormin/analysis"
+	"github.com/saram12saram2/gormin/connect"
+	"github.com/saram12saram2/gormin/fit"
+	"github.com/saram12saram2/gormin/ingest"
+	"github.com/saram12saram2/gormin/storage"
+)
+
+// devicesCommand handles the devices command, summarising the devices that
+// recorded activities along with their latest firmware and battery status
+func devicesCommand(db *sql.DB) error {
+	rows, err := db.Query(`SELECT d.manufacturer, COALESCE(NULLIF(d.product_name, ''), d.product),
+			d.serial_number, d.device_type, d.software_version, d.battery_status,
+			COUNT(ad.activity_id), d.last_seen
+		FROM devices d
+		LEFT JOIN activity_devices ad ON ad.device_id = d.id
+		GROUP BY d.id
+		ORDER BY d.last_seen DESC, d.manufacturer`)
+	if err != nil {
+		return fmt.Errorf("failed to query devices: %w", err)
+	}
+	defer rows.Close()
+
+	fmt.Printf("%-16s %-24s %-12s %-22s %-9s %-9s %10s  %s\n",
+		"Manufacturer", "Product", "Serial", "Type", "Software", "Battery", "Activities", "Last seen")
+	for rows.Next() {
+		var (
+			manufacturer, product, serial, deviceType sql.NullString
+			software, battery, lastSeen               sql.NullString
+			count                                     int
+		)
+		if err := rows.Scan(&manufacturer, &product, &serial, &deviceType, &software, &battery, &count, &lastSeen); err != nil {
+			return fmt.Errorf("failed to read devices: %w", err)
+		}
+		fmt.Printf("%-16s %-24s %-12s %-22s %-9s %-9s %10d  %s\n",
+			manufacturer.String, product.String, serial.String, deviceType.String,
+			software.String, battery.String, count, lastSeen.String)
+	}
+	return rows.Err()
+}
+
+// gearCommand handles the gear command, listing gear with its lifetime
+// activity count and distance and warning about gear past its limit. The
+// lifetime totals come from Connect, as the synced activities may cover only
+// part of the gear's history; gear without them falls back to the synced
+// activities, which the hours are always summed from. The limit is the maximum
+// distance set in Connect, or else the configured gear_limits_km for the gear
+// type.
+func gearCommand(db *sql.DB) error {
+	rows, err := db.Query(`SELECT g.name, g.type, g.status, g.max_distance,
+			COALESCE(g.total_activities, COUNT(a.id)), COALESCE(g.total_distance, SUM(a.distance), 0),
+			COALESCE(SUM(a.duration), 0)
+		FROM gear g
+		LEFT JOIN gear_activities ga ON ga.gear_uuid = g.uuid
+		LEFT JOIN activities a ON a.id = ga.activity_id
+		GROUP BY g.uuid
+		ORDER BY g.type, g.status, g.name`)
+	if err != nil {
+		return fmt.Errorf("failed to query gear: %w", err)
+	}
+	defer rows.Close()
+
+	fmt.Printf("%-30s %-8s %-8s %6s %10s %8s %10s\n", "Name", "Type", "Status", "Count", "Distance", "Hours", "Limit")
+	var warnings []string
+	for rows.Next() {
+		var (
+			name, gearType, status sql.NullString
+			maxDistance            sql.NullFloat64
+			count                  int
+			distance               float64
+			duration               int
+		)
+		if err := rows.Scan(&name, &gearType, &status, &maxDistance, &count, &distance, &duration); err != nil {
+			return fmt.Errorf("failed to read gear: %w", err)
+		}
+
+		limit := maxDistance.Float64
+		if limit <= 0 {
+			limit = config.GearLimitsKm[gearType.String]
+		}
+
+		limitStr := "-"
+		if limit > 0 {
+			limitStr = fmt.Sprintf("%.0f km", limit)
+		}
+		fmt.Printf("%-30s %-8s %-8s %6d %7.1f km %8.1f %10s\n",
+			name.String, gearType.String, status.String, count, distance, float64(duration)/3600.0, limitStr)
+
+		if limit > 0 && distance >= limit && status.String != "retired" {
+			warnings = append(warnings, fmt.Sprintf("%s has reached %.1f km, past its %.0f km limit", name.String, distance, limit))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read gear: %w", err)
+	}
+
+	for _, warning := range warnings {
+		fmt.Printf("WARNING: %s\n", warning)
+	}
+	return nil
+}
+
+// bulkEditCommand handles the bulk-edit command, applying a metadata change to
+// every local activity matching a filter, both on Garmin Connect and locally
+//
+//	bulk-edit [-type t] [-name text] [-since date] [-until date] [-min-distance km] [-max-distance km]
+//	          [-set-name n] [-set-type t] [-set-description d] [-set-privacy p] [-dry-run]
+func bulkEditCommand(ctx context.Context, db *sql.DB, args []string) error {
+	fs := flag.NewFlagSet("bulk-edit", flag.ContinueOnError)
+	filterType := fs.String("type", "", "Only activities of this type")
+	filterName := fs.String("name", "", "Only activities whose name contains this text")
+	since := fs.String("since", "", "Only activities starting on or after this date (2006-01-02)")
+	until := fs.String("until", "", "Only activities starting before the end of this date (2006-01-02)")
+	minDistance := fs.Float64("min-distance", 0, "Only activities at least this long (km)")
+	maxDistance := fs.Float64("max-distance", 0, "Only activities at most this long (km)")
+	setName := fs.String("set-name", "", "New activity name")
+	setType := fs.String("set-type", "", "New activity type, e.g. commuting")
+	setDescription := fs.String("set-description", "", "New activity description")
+	setPrivacy := fs.String("set-privacy", "", "New privacy: public, subscribers or private")
+	dryRun := fs.Bool("dry-run", false, "List matching activities without changing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var update connect.ActivityUpdate
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "set-name":
+			update.Name = setName
+		case "set-type":
+			update.Type = setType
+		case "set-description":
+			update.Description = setDescription
+		case "set-privacy":
+			update.Privacy = setPrivacy
+		}
+	})
+	if update == (connect.ActivityUpdate{}) {
+		return fmt.Errorf("nothing to change: use -set-name, -set-type, -set-description or -set-privacy")
+	}
+	if update.Privacy != nil && !connect.ValidPrivacy(*update.Privacy) {
+		return fmt.Errorf("invalid privacy %q: expected public, subscribers or private", *update.Privacy)
+	}
+
+	// Build the filter over local activities
+	query := `SELECT id, garmin_activity_id, name, type, start_time FROM activities WHERE 1 = 1`
+	var params []interface{}
+	if *filterType != "" {
+		query += ` AND type = ?`
+		params = append(params, *filterType)
+	}
+	if *filterName != "" {
+		query += ` AND name LIKE ?`
+		params = append(params, "%"+*filterName+"%")
+	}
+	if *since != "" {
+		query += ` AND start_time >= ?`
+		params = append(params, *since)
+	}
+	if *until != "" {
+		end, err := time.Parse("2006-01-02", *until)
+		if err != nil {
+			return fmt.Errorf("invalid -until date %q: %w", *until, err)
+		}
+		query += ` AND start_time < ?`
+		params = append(params, end.AddDate(0, 0, 1).Format("2006-01-02"))
+	}
+	if *minDistance > 0 {
+		query += ` AND distance >= ?`
+		params = append(params, *minDistance)
+	}
+	if *maxDistance > 0 {
+		query += ` AND distance <= ?`
+		params = append(params, *maxDistance)
+	}
+	query += ` ORDER BY start_time`
+
+	type match struct {
+		id        int
+		garminID  sql.NullInt64
+		name      string
+		typ       string
+		startTime string
+	}
+
+	rows, err := db.Query(storage.Rebind(db, query), params...)
+	if err != nil {
+		return fmt.Errorf("failed to query activities: %w", err)
+	}
+	var matches []match
+	for rows.Next() {
+		var m match
+		if err := rows.Scan(&m.id, &m.garminID, &m.name, &m.typ, &m.startTime); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read activities: %w", err)
+		}
+		matches = append(matches, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read activities: %w", err)
+	}
+
+	fmt.Printf("%d matching activities\n", len(matches))
+	if *dryRun {
+		for _, m := range matches {
+			fmt.Printf("  %d  %s  %-12s %s\n", m.id, m.startTime, m.typ, m.name)
+		}
+		return nil
+	}
+
+	gc := newGarminConnect()
+	for _, m := range matches {
+		if m.garminID.Valid {
+			if err := gc.UpdateActivity(ctx, int(m.garminID.Int64), update); err != nil {
+				return err
+			}
+		} else {
+			fmt.Printf("Activity %d is not on Garmin Connect, updating locally only\n", m.id)
+		}
+
+		if err := updateLocalActivity(db, m.id, m.startTime, update); err != nil {
+			return err
+		}
+		fmt.Printf("Updated activity %d (%s)\n", m.id, m.name)
+	}
+
+	return nil
+}
+
+// updateLocalActivity applies an activity update to the local database
+func updateLocalActivity(db *sql.DB, id int, startTime string, update connect.ActivityUpdate) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to update activity: %w", err)
+	}
+	defer tx.Rollback()
+
+	if update.Name != nil {
+		if _, err := tx.Exec(storage.Rebind(db, `UPDATE activities SET name = ? WHERE id = ?`), *update.Name, id); err != nil {
+			return fmt.Errorf("failed to update activity: %w", err)
+		}
+	}
+	if update.Type != nil {
+		if _, err := tx.Exec(storage.Rebind(db, `UPDATE activities SET type = ? WHERE id = ?`), *update.Type, id); err != nil {
+			return fmt.Errorf("failed to update activity: %w", err)
+		}
+	}
+
+	// Description and privacy live with the details of the activity
+	if update.Description != nil {
+		_, err := tx.Exec(storage.Rebind(db, `INSERT INTO activity_details (activity_id, description) VALUES (?, ?)
+			ON CONFLICT(activity_id) DO UPDATE SET description = excluded.description`),
+			id, *update.Description)
+		if err != nil {
+			return fmt.Errorf("failed to update activity: %w", err)
+		}
+	}
+	if update.Privacy != nil {
+		_, err := tx.Exec(storage.Rebind(db, `INSERT INTO activity_details (activity_id, privacy) VALUES (?, ?)
+			ON CONFLICT(activity_id) DO UPDATE SET privacy = excluded.privacy`),
+			id, *update.Privacy)
+		if err != nil {
+			return fmt.Errorf("failed to update activity: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update activity: %w", err)
+	}
+	// A new type moves the activity between rows of its summaries
+	if update.Type != nil {
+		return storage.RefreshActivitySummaries(db, startTime)
+	}
+	return nil
+}
+
+// uploadCommand handles the upload command, uploading an activity file to
+// Garmin Connect and linking the resulting activity to the local database
+//
+//	upload <file.fit|file.gpx|file.tcx>
+func uploadCommand(ctx context.Context, store storage.Store, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: upload <file>")
+	}
+	path := args[0]
+
+	gc := newGarminConnect()
+	activityID, err := gc.UploadActivity(ctx, path)
+	switch {
+	case errors.Is(err, connect.ErrDuplicateActivity) && activityID != 0:
+		fmt.Printf("%s is already on Garmin Connect as activity %d\n", path, activityID)
+	case err != nil:
+		return err
+	default:
+		fmt.Printf("Uploaded %s as activity %d\n", path, activityID)
+	}
+
+	details, err := gc.GetActivityDetails(ctx, activityID)
+	if err != nil {
+		return err
+	}
+	return linkUploadedActivity(store, details)
+}
+
+// linkUploadedActivity stores an uploaded activity, merging it with the local
+// activity imported from the same file when there is one
+func linkUploadedActivity(store storage.Store, details *gormin.ActivityDetails) error {
+	localID, err := store.SaveActivity(&details.Activity, gormin.SourceConnect)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Linked local activity %d to Garmin activity %d\n", localID, details.Activity.ID)
+
+	return store.SaveActivityDetails(localID, details)
+}
+
+// workoutCommand handles the workout command
+//
+//	workout list
+//	workout upload <file.yaml|file.json>
+//	workout fit <file.yaml|file.json|workout_id> [output.fit]
+func workoutCommand(ctx context.Context, store storage.Store, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: workout <list|upload|fit> [args]")
+	}
+
+	switch args[0] {
+	case "list":
+		return listWorkouts(store)
+	case "upload":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: workout upload <file.yaml|file.json>")
+		}
+		workout, err := loadWorkoutDefinition(args[1])
+		if err != nil {
+			return err
+		}
+
+		gc := newGarminConnect()
+		workoutID, err := gc.CreateWorkout(ctx, workout)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Uploaded workout %s as workout %d\n", workout.Name, workoutID)
+
+		workout.ID = workoutID
+		return store.SaveWorkout(workout)
+	case "fit":
+		if len(args) < 2 || len(args) > 3 {
+			return fmt.Errorf("usage: workout fit <file.yaml|file.json|workout_id> [output.fit]")
+		}
+
+		var workout *gormin.Workout
+		var err error
+		if workoutID, convErr := strconv.Atoi(args[1]); convErr == nil {
+			workout, err = store.Workout(workoutID)
+		} else {
+			workout, err = loadWorkoutDefinition(args[1])
+		}
+		if err != nil {
+			return err
+		}
+
+		data, err := fit.WorkoutToFit(workout)
+		if err != nil {
+			return fmt.Errorf("failed to encode workout: %w", err)
+		}
+
+		output := safeFileName(workout.Name, ".fit")
+		if len(args) == 3 {
+			output = args[2]
+		}
+		if err := os.WriteFile(output, data, 0644); err != nil {
+			return fmt.Errorf("failed to write FIT file: %w", err)
+		}
+		fmt.Printf("Wrote %s, copy it to GARMIN/NewFiles on the watch\n", output)
+		return nil
+	}
+
+	return fmt.Errorf("unknown workout command: %s", args[0])
+}
+
+// listWorkouts prints the stored workouts
+func listWorkouts(store storage.Store) error {
+	workouts, err := store.ListWorkouts()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-12s %-40s %-18s %5s\n", "ID", "Name", "Sport", "Steps")
+	for _, w := range workouts {
+		fmt.Printf("%-12d %-40s %-18s %5d\n", w.ID, w.Name, w.Sport, countWorkoutSteps(w.Steps))
+	}
+	return nil
+}
+
+// countWorkoutSteps counts steps and repeat groups, including those nested in
+// repeat groups
+func countWorkoutSteps(steps []gormin.WorkoutStep) int {
+	n := len(steps)
+	for _, step := range steps {
+		n += countWorkoutSteps(step.Steps)
+	}
+	return n
+}
+
+// loadWorkoutDefinition reads a workout definition file, parsed as JSON for
+// .json files and as YAML otherwise
+func loadWorkoutDefinition(path string) (*gormin.Workout, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workout definition: %w", err)
+	}
+
+	var workout gormin.Workout
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &workout)
+	} else {
+		err = yaml.Unmarshal(data, &workout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse workout definition: %w", err)
+	}
+
+	if workout.Name == "" || len(workout.Steps) == 0 {
+		return nil, fmt.Errorf("workout definition needs a name and steps")
+	}
+	if workout.Sport == "" {
+		workout.Sport = "running"
+	}
+	return &workout, nil
+}
+
+// safeFileName returns a file name with the given extension for a workout
+// or course name
+func safeFileName(name, ext string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	return name + ext
+}
+
+// courseCommand handles the course command
+//
+//	course list
+//	course import <file.gpx|file.fit>
+//	course export <course_id> [output.gpx]
+//	course compare <course_id> <activity_id>
+func courseCommand(store storage.Store, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: course <list|import|export|compare> [args]")
+	}
+
+	switch args[0] {
+	case "list":
+		return listCourses(store)
+	case "import":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: course import <file.gpx|file.fit>")
+		}
+		if strings.EqualFold(filepath.Ext(args[1]), ".gpx") {
+			return ingest.ImportGPXCourse(store, args[1])
+		}
+		return ingest.NewFitProcessor(config.DataPath, store).ProcessFile(args[1])
+	case "export":
+		if len(args) < 2 || len(args) > 3 {
+			return fmt.Errorf("usage: course export <course_id> [output.gpx]")
+		}
+		courseID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid course id: %s", args[1])
+		}
+		course, err := store.Course(courseID)
+		if err != nil {
+			return err
+		}
+
+		output := safeFileName(course.Name, ".gpx")
+		if len(args) == 3 {
+			output = args[2]
+		}
+		if err := analysis.WriteGPXCourse(course, output); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s (%d points)\n", output, len(course.Points))
+		return nil
+	case "compare":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: course compare <course_id> <activity_id>")
+		}
+		courseID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid course id: %s", args[1])
+		}
+		activityID, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid activity id: %s", args[2])
+		}
+		return compareCourseCommand(store, courseID, activityID)
+	}
+
+	return fmt.Errorf("unknown course command: %s", args[0])
+}
+
+// listCourses prints the stored courses
+func listCourses(store storage.Store) error {
+	courses, err := store.ListCourses()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-6s %-40s %-12s %10s %8s  %s\n", "ID", "Name", "Sport", "Distance", "Ascent", "Source")
+	for _, c := range courses {
+		fmt.Printf("%-6d %-40s %-12s %7.2f km %6d m  %s\n",
+			c.ID, c.Name, c.Sport, c.Distance, c.TotalAscent, c.Source)
+	}
+	return nil
+}
+
+// compareCourseCommand prints how closely a local activity followed a course
+func compareCourseCommand(store storage.Store, courseID, activityID int) error {
+	course, err := store.Course(courseID)
+	if err != nil {
+		return err
+	}
+	records, err := store.ActivityRecords(activityID)
+	if err != nil {
+		return err
+	}
+
+	result, err := analysis.CompareCourse(course, records)
+	if err != nil {
+		return fmt.Errorf("failed to compare activity %d with course %d: %w", activityID, courseID, err)
+	}
+
+	fmt.Printf("Course:     %s\n", course.Name)
+	fmt.Printf("Distance:   %.2f km planned, %.2f km actual\n", result.PlannedDistance, result.ActualDistance)
+	fmt.Printf("Ascent:     %d m planned, %d m actual\n", result.PlannedAscent, result.ActualAscent)
+	fmt.Printf("Deviation:  %.0f m mean, %.0f m max\n", result.MeanDeviation, result.MaxDeviation)
+	fmt.Printf("On course:  %.0f%% of the activity within %.0f m of the course\n", result.OnCourse*100, analysis.CourseMatchDistance)
+	fmt.Printf("Covered:    %.0f%% of the course\n", result.Covered*100)
+	return nil
+}
+
+// recordsCommand handles the records command, listing personal records from
+// Garmin Connect next to those computed locally from activity records
+//
+//	records [recompute]
+func recordsCommand(store storage.Store, args []string) error {
+	if len(args) > 0 {
+		if args[0] != "recompute" {
+			return fmt.Errorf("usage: records [recompute]")
+		}
+		if err := ingest.RecomputeBestEfforts(store); err != nil {
+			return err
+		}
+	}
+
+	local, err := store.PersonalRecords(gormin.SourceLocal)
+	if err != nil {
+		return err
+	}
+	connect, err := store.PersonalRecords(gormin.SourceConnect)
+	if err != nil {
+		return err
+	}
+
+	var types []string
+	for recordType := range local {
+		types = append(types, recordType)
+	}
+	for recordType := range connect {
+		if _, ok := local[recordType]; !ok {
+			types = append(types, recordType)
+		}
+	}
+	sort.Strings(types)
+
+	format := func(record gormin.PersonalRecord, ok bool) string {
+		if !ok {
+			return "-"
+		}
+		return fmt.Sprintf("%s (%.10s)", analysis.FormatRecordValue(record.RecordType, record.Value), record.AchievedAt)
+	}
+
+	fmt.Printf("%-20s %-28s %-28s\n", "Record", "Local", "Connect")
+	for _, recordType := range types {
+		l, lok := local[recordType]
+		c, cok := connect[recordType]
+		fmt.Printf("%-20s %-28s %-28s\n", recordType, format(l, lok), format(c, cok))
+	}
+	return nil
+}
+
+// addWeightCommand handles the add-weight command, uploading a manual
+// weigh-in to Garmin Connect and storing it locally. Connect takes only the
+// weight for manual entries, so there are no body composition flags: values
+// kept only locally would be replaced by the next sync of the weigh-in.
+//
+//	add-weight [-date 2006-01-02T15:04] <weight_kg>
+func addWeightCommand(ctx context.Context, store storage.Store, args []string) error {
+	fs := flag.NewFlagSet("add-weight", flag.ContinueOnError)
+	date := fs.String("date", "", "Time of the weigh-in (2006-01-02 or 2006-01-02T15:04, default now)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: add-weight [flags] <weight_kg>")
+	}
+	weight, err := strconv.ParseFloat(fs.Arg(0), 64)
+	if err != nil || weight <= 0 {
+		return fmt.Errorf("invalid weight: %s", fs.Arg(0))
+	}
+
+	at := time.Now()
+	if *date != "" {
+		at, err = parseLocalTime(*date)
+		if err != nil {
+			return err
+		}
+	}
+
+	gc := newGarminConnect()
+	if err := gc.AddWeight(ctx, weight, at); err != nil {
+		return err
+	}
+
+	entry := &gormin.WeightEntry{
+		Date:      at.Format("2006-01-02"),
+		Timestamp: at.UTC().Format("2006-01-02 15:04:05"),
+		Weight:    weight,
+	}
+	return store.SaveWeight(entry)
+}
+
+// parseLocalTime parses a date or date and time given on the command line in local time
+func parseLocalTime(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s", value)
+}
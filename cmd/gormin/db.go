@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/saram12saram2/gormin/storage"
+)
+
+// dbCommand handles the db command for database maintenance. Like migrate,
+// it runs before the database is migrated.
+//
+//	db backup <path>
+//	db check
+//	db vacuum
+func dbCommand(ctx context.Context, db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: db <backup|check|vacuum>")
+	}
+
+	switch args[0] {
+	case "backup":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: db backup <path>")
+		}
+		if err := storage.Backup(ctx, db, args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Backed up database to %s\n", args[1])
+		return nil
+	case "check":
+		problems, err := storage.Check(db)
+		if err != nil {
+			return err
+		}
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("found %d problems", len(problems))
+		}
+		fmt.Println("Database is healthy")
+		return nil
+	case "vacuum":
+		if err := storage.Vacuum(db); err != nil {
+			return err
+		}
+		fmt.Println("Database vacuumed")
+		return nil
+	}
+	return fmt.Errorf("unknown db command: %s", args[0])
+}
+
+// backupBeforeMigrate backs up a SQLite database next to its file before
+// pending migrations change it. New and PostgreSQL databases are skipped. The
+// file is the one SQLite opened, as the DSN may be a URI with parameters.
+func backupBeforeMigrate(ctx context.Context, db *sql.DB) error {
+	if config.DatabaseDriver != storage.DriverSQLite {
+		return nil
+	}
+	file, err := storage.DatabaseFile(db)
+	if err != nil {
+		return fmt.Errorf("failed to locate database file to back up: %w", err)
+	}
+	// Opening a new database creates an empty file, which has nothing to lose
+	info, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("failed to locate database file to back up: %w", err)
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+
+	current, err := storage.SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current >= storage.LatestSchemaVersion() {
+		return nil
+	}
+
+	path := fmt.Sprintf("%s.v%d-%s.bak", file, current, time.Now().Format("20060102-150405"))
+	if err := storage.Backup(ctx, db, path); err != nil {
+		return fmt.Errorf("failed to back up database before migrating: %w", err)
+	}
+	fmt.Printf("Backed up database to %s before migrating\n", path)
+	return nil
+}
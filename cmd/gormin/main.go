@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/saram12saram2/gormin/connect"
+	"github.com/saram12saram2/gormin/ingest"
+	"github.com/saram12saram2/gormin/storage"
+)
+
+type Config struct {
+	DatabaseDriver    string             `json:"database_driver"`
+	DatabaseDSN       string             `json:"database_dsn"`
+	DatabasePath      string             `json:"database_path"`
+	DataPath          string             `json:"data_path"`
+	GarminUsername    string             `json:"garmin_username"`
+	GarminPassword    string             `json:"garmin_password"`
+	RetainFiles       bool               `json:"retain_files"`
+	DownloadDays      int                `json:"download_days"`
+	RequestsPerMinute int                `json:"requests_per_minute"`
+	GearLimitsKm      map[string]float64 `json:"gear_limits_km"`
+}
+
+var (
+	config  *Config
+	mfaCode string
+)
+
+func main() {
+	var configPath = flag.String("config", "config.json", "Path to configuration file")
+	flag.StringVar(&mfaCode, "mfa-code", os.Getenv("GARMIN_MFA_CODE"), "Garmin Connect MFA code (defaults to $GARMIN_MFA_CODE, prompts if empty)")
+	flag.Parse()
+
+	// Load configuration
+	if err := loadConfig(*configPath); err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Open the database
+	db, err := storage.Open(config.DatabaseDriver, config.DatabaseDSN)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	// Cancel long-running commands on Ctrl-C or termination
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The migrate and db commands work on the database as it is
+	switch flag.Arg(0) {
+	case "migrate":
+		defer db.Close()
+		if err := migrateCommand(ctx, db, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to migrate: %v", err)
+		}
+		return
+	case "db":
+		defer db.Close()
+		if err := dbCommand(ctx, db, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to run db command: %v", err)
+		}
+		return
+	}
+
+	if err := backupBeforeMigrate(ctx, db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	if err := storage.Migrate(db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	store, err := storage.NewSQLStore(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer store.Close()
+
+	// Parse command
+	if len(flag.Args()) == 0 {
+		return
+	}
+
+	command := flag.Args()[0]
+	switch command {
+	case "init":
+		fmt.Println("Database initialized successfully")
+	case "version":
+		fmt.Println("GarminDB Go v1.0.0")
+	case "parse-fit":
+		if err := parseFitCommand(store); err != nil {
+			log.Fatalf("Failed to parse FIT files: %v", err)
+		}
+		fmt.Println("FIT file parsing completed")
+	case "sync":
+		if err := syncCommand(ctx, store); err != nil {
+			log.Fatalf("Failed to sync: %v", err)
+		}
+		fmt.Println("Sync completed")
+	case "reprocess":
+		if err := ingest.Reprocess(store); err != nil {
+			log.Fatalf("Failed to reprocess: %v", err)
+		}
+		fmt.Println("Reprocessing completed")
+	case "workout":
+		if err := workoutCommand(ctx, store, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to run workout command: %v", err)
+		}
+	case "course":
+		if err := courseCommand(store, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to run course command: %v", err)
+		}
+	case "records":
+		if err := recordsCommand(store, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to run records command: %v", err)
+		}
+	case "summary":
+		if err := summaryCommand(store, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to summarize: %v", err)
+		}
+	case "query":
+		if err := queryCommand(ctx, db, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to run query: %v", err)
+		}
+	case "devices":
+		if err := devicesCommand(db); err != nil {
+			log.Fatalf("Failed to list devices: %v", err)
+		}
+	case "gear":
+		if err := gearCommand(db); err != nil {
+			log.Fatalf("Failed to list gear: %v", err)
+		}
+	case "bulk-edit":
+		if err := bulkEditCommand(ctx, db, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to edit activities: %v", err)
+		}
+	case "upload":
+		if err := uploadCommand(ctx, store, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to upload: %v", err)
+		}
+	case "add-weight":
+		if err := addWeightCommand(ctx, store, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to add weight: %v", err)
+		}
+	case "login":
+		if err := newGarminConnect().Login(ctx); err != nil {
+			log.Fatalf("Failed to log in: %v", err)
+		}
+
+	default:
+		fmt.Printf("Unknown command: %s\n", command)
+	}
+}
+
+func loadConfig(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	config = &Config{}
+	decoder := json.NewDecoder(file)
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("failed to decode config: %w", err)
+	}
+
+	// Default to the SQLite database at database_path
+	if config.DatabaseDriver == "" {
+		config.DatabaseDriver = storage.DriverSQLite
+	}
+	if config.DatabaseDSN == "" && config.DatabaseDriver == storage.DriverSQLite {
+		config.DatabaseDSN = config.DatabasePath
+	}
+
+	// Create data directory if it doesn't exist
+	if err := os.MkdirAll(config.DataPath, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	return nil
+}
+
+// syncCommand handles the sync command, downloading recent activities and
+// daily statistics from Garmin Connect into the database and archiving every
+// response for reprocess
+func syncCommand(ctx context.Context, store storage.Store) error {
+	gc := newGarminConnect()
+	if config.RequestsPerMinute > 0 {
+		gc.SetRequestsPerMinute(config.RequestsPerMinute)
+	}
+	gc.SetResponseRecorder(store.SaveRawResponse)
+	return ingest.Sync(ctx, gc, store, ingest.SyncOptions{
+		DataPath:     config.DataPath,
+		DownloadDays: config.DownloadDays,
+		RetainFiles:  config.RetainFiles,
+	})
+}
+
+// parseFitCommand handles the parse-fit command
+func parseFitCommand(store storage.Store) error {
+	processor := ingest.NewFitProcessor(config.DataPath, store)
+	return processor.ProcessFitFiles()
+}
+
+// newGarminConnect creates a Garmin Connect client from the loaded configuration
+func newGarminConnect() *connect.GarminConnect {
+	gc := connect.NewGarminConnect(config.GarminUsername, config.GarminPassword)
+	gc.SetMFACodeProvider(promptMFACode)
+	return gc
+}
+
+// promptMFACode returns the MFA code given on the command line or asks for it on the terminal
+func promptMFACode() (string, error) {
+	if mfaCode != "" {
+		return mfaCode, nil
+	}
+
+	fmt.Print("Enter MFA code: ")
+	code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/saram12saram2/gormin/storage"
+)
+
+// migrateCommand handles the migrate command. The database is opened without
+// migrating for this command, so status shows pending migrations. Like the
+// automatic migration, up backs up a SQLite database first.
+//
+//	migrate status
+//	migrate up
+func migrateCommand(ctx context.Context, db *sql.DB, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: migrate <status|up>")
+	}
+
+	switch args[0] {
+	case "up":
+		if err := backupBeforeMigrate(ctx, db); err != nil {
+			return err
+		}
+		if err := storage.Migrate(db); err != nil {
+			return err
+		}
+		fmt.Printf("Database is at schema version %d\n", storage.LatestSchemaVersion())
+		return nil
+	case "status":
+		return migrationStatus(db)
+	}
+	return fmt.Errorf("unknown migrate command: %s", args[0])
+}
+
+// migrationStatus prints every migration with when it was applied
+func migrationStatus(db *sql.DB) error {
+	current, err := storage.SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	applied, err := storage.AppliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Schema version %d, latest %d\n", current, storage.LatestSchemaVersion())
+	fmt.Printf("%-8s %-40s %s\n", "Version", "Description", "Applied")
+	for _, m := range storage.Migrations() {
+		status, ok := applied[m.Version]
+		if !ok {
+			status = "pending"
+		}
+		fmt.Printf("%-8d %-40s %s\n", m.Version, m.Description, status)
+	}
+	return nil
+}
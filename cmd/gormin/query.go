@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/saram12saram2/gormin/storage"
+)
+
+// savedQuery is a named query shipped with the tool
+type savedQuery struct {
+	Name        string
+	Description string
+	SQL         string
+}
+
+// savedQueries are run with query -name. They only use SQL that SQLite and
+// PostgreSQL share, rounding through NUMERIC as PostgreSQL cannot round floats.
+var savedQueries = []savedQuery{
+	{
+		Name:        "weekly-mileage",
+		Description: "Distance, time and count of activities per week over the last 12 weeks",
+		SQL: `SELECT period_key AS week, SUM(activities) AS activities,
+			ROUND(CAST(SUM(distance) AS NUMERIC), 1) AS distance_km, SUM(duration) / 60 AS minutes
+			FROM activity_summaries WHERE period = 'week'
+			GROUP BY period_key ORDER BY period_key DESC LIMIT 12`,
+	},
+	{
+		Name:        "monthly-by-type",
+		Description: "Activity totals per month and type over the last 12 months",
+		SQL: `SELECT period_key AS month, activity_type AS type, activities,
+			ROUND(CAST(distance AS NUMERIC), 1) AS distance_km, duration / 60 AS minutes,
+			elevation_gain, ROUND(CAST(avg_hr AS NUMERIC), 0) AS avg_hr
+			FROM activity_summaries
+			WHERE period = 'month' AND period_key IN (SELECT DISTINCT period_key FROM activity_summaries
+				WHERE period = 'month' ORDER BY period_key DESC LIMIT 12)
+			ORDER BY period_key DESC, activity_type`,
+	},
+	{
+		Name:        "recent-activities",
+		Description: "The 20 most recent activities",
+		SQL: `SELECT id, start_time, type, name, ROUND(CAST(distance AS NUMERIC), 2) AS distance_km,
+			duration / 60 AS minutes, avg_hr
+			FROM activities ORDER BY start_time DESC LIMIT 20`,
+	},
+	{
+		Name:        "longest-activities",
+		Description: "The 10 longest activities by distance",
+		SQL: `SELECT id, start_time, type, name, ROUND(CAST(distance AS NUMERIC), 2) AS distance_km,
+			duration / 60 AS minutes
+			FROM activities ORDER BY distance DESC LIMIT 10`,
+	},
+	{
+		Name:        "resting-hr",
+		Description: "Resting heart rate, sleep and steps over the last 30 days with data",
+		SQL: `SELECT date, resting_hr, ROUND(CAST(sleep_hours AS NUMERIC), 1) AS sleep_hours, steps
+			FROM daily_stats WHERE resting_hr > 0 ORDER BY date DESC LIMIT 30`,
+	},
+	{
+		Name:        "personal-records",
+		Description: "Personal records from Connect and computed locally",
+		SQL: `SELECT record_type, source, value, achieved_at
+			FROM personal_records ORDER BY record_type, source`,
+	},
+}
+
+// queryCommand handles the query command, running read-only SQL against the
+// database and printing the result
+//
+//	query [-format table|csv|json|markdown] <sql>
+//	query [-format f] -name <saved query>
+//	query -list
+func queryCommand(ctx context.Context, db *sql.DB, args []string) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	format := fs.String("format", "table", "Output format: table, csv, json or markdown")
+	name := fs.String("name", "", "Run a saved query instead of SQL given as argument")
+	list := fs.Bool("list", false, "List the saved queries")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *list {
+		for _, q := range savedQueries {
+			fmt.Printf("%-20s %s\n", q.Name, q.Description)
+		}
+		return nil
+	}
+
+	var query string
+	switch {
+	case *name != "" && fs.NArg() == 0:
+		for _, q := range savedQueries {
+			if q.Name == *name {
+				query = q.SQL
+			}
+		}
+		if query == "" {
+			return fmt.Errorf("unknown saved query %q: see query -list", *name)
+		}
+	case *name == "" && fs.NArg() == 1:
+		query = fs.Arg(0)
+	default:
+		return fmt.Errorf("usage: query [-format table|csv|json|markdown] <sql> | -name <saved query> | -list")
+	}
+
+	var write func(*storage.QueryResult) error
+	switch *format {
+	case "table":
+		write = writeTable
+	case "csv":
+		write = writeCSV
+	case "json":
+		write = writeJSON
+	case "markdown":
+		write = writeMarkdown
+	default:
+		return fmt.Errorf("invalid format %q: expected table, csv, json or markdown", *format)
+	}
+
+	result, err := storage.ReadOnlyQuery(ctx, db, query)
+	if err != nil {
+		return err
+	}
+	return write(result)
+}
+
+// formatValue formats a query value for the text formats; NULL is empty
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+// writeTable prints the result as aligned columns with a row count
+func writeTable(result *storage.QueryResult) error {
+	widths := make([]int, len(result.Columns))
+	for i, column := range result.Columns {
+		widths[i] = len(column)
+	}
+	cells := make([][]string, len(result.Rows))
+	for r, row := range result.Rows {
+		cells[r] = make([]string, len(row))
+		for i, value := range row {
+			cells[r][i] = formatValue(value)
+			if len(cells[r][i]) > widths[i] {
+				widths[i] = len(cells[r][i])
+			}
+		}
+	}
+
+	printRow := func(values []string) {
+		padded := make([]string, len(values))
+		for i, value := range values {
+			padded[i] = fmt.Sprintf("%-*s", widths[i], value)
+		}
+		fmt.Println(strings.TrimRight(strings.Join(padded, "  "), " "))
+	}
+	printRow(result.Columns)
+	rule := make([]string, len(widths))
+	for i, width := range widths {
+		rule[i] = strings.Repeat("-", width)
+	}
+	printRow(rule)
+	for _, row := range cells {
+		printRow(row)
+	}
+	if len(result.Rows) == 1 {
+		fmt.Println("(1 row)")
+	} else {
+		fmt.Printf("(%d rows)\n", len(result.Rows))
+	}
+	return nil
+}
+
+// writeCSV prints the result as CSV with a header row
+func writeCSV(result *storage.QueryResult) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write(result.Columns); err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			record[i] = formatValue(value)
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeJSON prints the result as an array of objects, keeping the column order
+func writeJSON(result *storage.QueryResult) error {
+	var b bytes.Buffer
+	b.WriteString("[")
+	for r, row := range result.Rows {
+		if r > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("{")
+		for i, value := range row {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			key, err := json.Marshal(result.Columns[i])
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			b.Write(key)
+			b.WriteString(":")
+			b.Write(data)
+		}
+		b.WriteString("}")
+	}
+	b.WriteString("]")
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, b.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	fmt.Println(out.String())
+	return nil
+}
+
+// writeMarkdown prints the result as a Markdown table
+func writeMarkdown(result *storage.QueryResult) error {
+	escape := func(value string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(value, "|", `\|`), "\n", " ")
+	}
+
+	header := make([]string, len(result.Columns))
+	rule := make([]string, len(result.Columns))
+	for i, column := range result.Columns {
+		header[i] = escape(column)
+		rule[i] = "---"
+	}
+	fmt.Printf("| %s |\n", strings.Join(header, " | "))
+	fmt.Printf("| %s |\n", strings.Join(rule, " | "))
+	for _, row := range result.Rows {
+		cells := make([]string, len(row))
+		for i, value := range row {
+			cells[i] = escape(formatValue(value))
+		}
+		fmt.Printf("| %s |\n", strings.Join(cells, " | "))
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/saram12saram2/gormin"
+	"github.com/saram12saram2/gormin/analysis"
+	"github.com/saram12saram2/gormin/storage"
+)
+
+// summaryCommand handles the summary command, printing activity totals by
+// type and daily stats totals from the rollup tables
+//
+//	summary [-period week|month|year] [-last n] [-rebuild]
+func summaryCommand(store storage.Store, args []string) error {
+	fs := flag.NewFlagSet("summary", flag.ContinueOnError)
+	period := fs.String("period", storage.PeriodWeek, "Period to total over: week, month or year")
+	last := fs.Int("last", 8, "Number of periods to show, up to the current one")
+	rebuild := fs.Bool("rebuild", false, "Recompute all summaries from activities and daily stats first")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !storage.ValidPeriod(*period) {
+		return fmt.Errorf("invalid period %q: expected week, month or year", *period)
+	}
+	if *last < 1 {
+		return fmt.Errorf("-last must be at least 1")
+	}
+
+	if *rebuild {
+		if err := store.RebuildSummaries(); err != nil {
+			return err
+		}
+		fmt.Println("Rebuilt summaries")
+	}
+
+	activities, err := store.ActivitySummaries(*period, *last)
+	if err != nil {
+		return err
+	}
+	daily, err := store.DailySummaries(*period, *last)
+	if err != nil {
+		return err
+	}
+
+	label := strings.ToUpper((*period)[:1]) + (*period)[1:]
+	printActivitySummaries(label, activities)
+	fmt.Println()
+	printDailySummaries(label, daily)
+	return nil
+}
+
+// printActivitySummaries prints activity totals by type, with an overall
+// total for periods with more than one type
+func printActivitySummaries(label string, summaries []gormin.ActivitySummary) {
+	fmt.Printf("%-9s %-20s %10s %11s %10s %9s %9s %7s\n",
+		label, "Type", "Activities", "Distance", "Duration", "Elevation", "Calories", "Avg HR")
+	row := func(key, typ string, s gormin.ActivitySummary) {
+		avgHR := "-"
+		if s.AvgHR > 0 {
+			avgHR = fmt.Sprintf("%.0f", s.AvgHR)
+		}
+		fmt.Printf("%-9s %-20s %10d %8.2f km %10s %7d m %9d %7s\n",
+			key, typ, s.Activities, s.Distance, analysis.FormatDuration(float64(s.Duration)),
+			s.ElevationGain, s.Calories, avgHR)
+	}
+
+	for i := 0; i < len(summaries); {
+		key := summaries[i].PeriodKey
+		var total gormin.ActivitySummary
+		types := 0
+		for ; i < len(summaries) && summaries[i].PeriodKey == key; i++ {
+			s := summaries[i]
+			if types == 0 {
+				row(key, s.Type, s)
+			} else {
+				row("", s.Type, s)
+			}
+			types++
+			total.Activities += s.Activities
+			total.Distance += s.Distance
+			total.Duration += s.Duration
+			total.ElevationGain += s.ElevationGain
+			total.Calories += s.Calories
+		}
+		if types > 1 {
+			row("", "total", total)
+		}
+	}
+}
+
+// printDailySummaries prints daily stats totals and averages per period
+func printDailySummaries(label string, summaries []gormin.DailySummary) {
+	fmt.Printf("%-9s %5s %10s %11s %9s %7s %10s\n",
+		label, "Days", "Steps", "Distance", "Calories", "Sleep", "Resting HR")
+	for _, s := range summaries {
+		sleep, restingHR := "-", "-"
+		if s.AvgSleepHours > 0 {
+			sleep = fmt.Sprintf("%.1f h", s.AvgSleepHours)
+		}
+		if s.AvgRestingHR > 0 {
+			restingHR = fmt.Sprintf("%.0f", s.AvgRestingHR)
+		}
+		fmt.Printf("%-9s %5d %10d %8.2f km %9d %7s %10s\n",
+			s.PeriodKey, s.Days, s.Steps, s.Distance, s.Calories, sleep, restingHR)
+	}
+}
@@ -0,0 +1,344 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// GarminActivityDetail represents the activity response from Garmin Connect
+type GarminActivityDetail struct {
+	ActivityID      int    `json:"activityId"`
+	ActivityName    string `json:"activityName"`
+	Description     string `json:"description"`
+	ActivityTypeDTO struct {
+		TypeKey string `json:"typeKey"`
+	} `json:"activityTypeDTO"`
+	AccessControlRuleDTO struct {
+		TypeKey string `json:"typeKey"`
+	} `json:"accessControlRuleDTO"`
+	SummaryDTO GarminActivitySummary `json:"summaryDTO"`
+}
+
+// GarminActivitySummary represents the summary DTO of an activity
+type GarminActivitySummary struct {
+	StartTimeLocal          string  `json:"startTimeLocal"`
+	Duration                float64 `json:"duration"`
+	MovingDuration          float64 `json:"movingDuration"`
+	Distance                float64 `json:"distance"`
+	Calories                float64 `json:"calories"`
+	AverageHR               float64 `json:"averageHR"`
+	MaxHR                   float64 `json:"maxHR"`
+	ElevationGain           float64 `json:"elevationGain"`
+	ElevationLoss           float64 `json:"elevationLoss"`
+	AverageSpeed            float64 `json:"averageSpeed"`
+	MaxSpeed                float64 `json:"maxSpeed"`
+	AveragePower            float64 `json:"averagePower"`
+	MaxPower                float64 `json:"maxPower"`
+	AverageRunCadence       float64 `json:"averageRunCadence"`
+	AverageBikeCadence      float64 `json:"averageBikeCadence"`
+	TrainingEffect          float64 `json:"trainingEffect"`
+	AnaerobicTrainingEffect float64 `json:"anaerobicTrainingEffect"`
+}
+
+// GarminSplits represents the splits response of an activity
+type GarminSplits struct {
+	LapDTOs []struct {
+		LapIndex      int     `json:"lapIndex"`
+		StartTimeGMT  string  `json:"startTimeGMT"`
+		Duration      float64 `json:"duration"`
+		Distance      float64 `json:"distance"`
+		AverageSpeed  float64 `json:"averageSpeed"`
+		AverageHR     float64 `json:"averageHR"`
+		MaxHR         float64 `json:"maxHR"`
+		ElevationGain float64 `json:"elevationGain"`
+		ElevationLoss float64 `json:"elevationLoss"`
+		Calories      float64 `json:"calories"`
+	} `json:"lapDTOs"`
+}
+
+// GarminZoneTime represents the time spent in one HR or power zone
+type GarminZoneTime struct {
+	ZoneNumber      int     `json:"zoneNumber"`
+	SecsInZone      float64 `json:"secsInZone"`
+	ZoneLowBoundary float64 `json:"zoneLowBoundary"`
+}
+
+// GarminWeather represents the weather of an activity; temperatures are in
+// Fahrenheit and wind speeds in mph
+type GarminWeather struct {
+	IssueDate                 string  `json:"issueDate"`
+	Temp                      float64 `json:"temp"`
+	ApparentTemp              float64 `json:"apparentTemp"`
+	DewPoint                  float64 `json:"dewPoint"`
+	RelativeHumidity          int     `json:"relativeHumidity"`
+	WindDirectionCompassPoint string  `json:"windDirectionCompassPoint"`
+	WindSpeed                 float64 `json:"windSpeed"`
+	WindGust                  float64 `json:"windGust"`
+	WeatherTypeDTO            struct {
+		Desc string `json:"desc"`
+	} `json:"weatherTypeDTO"`
+}
+
+// GetActivityDetails retrieves the full summary, splits, time in HR and power
+// zones and weather of an activity. Splits, zones and weather are optional and
+// left empty when Connect has none for the activity.
+func (gc *GarminConnect) GetActivityDetails(ctx context.Context, activityID int) (*gormin.ActivityDetails, error) {
+	basePath := fmt.Sprintf("/modern/proxy/activity-service/activity/%d", activityID)
+
+	var body, splits, hrZones, powerZones, weather json.RawMessage
+	if err := gc.getJSON(ctx, basePath, &body); err != nil {
+		return nil, fmt.Errorf("failed to get activity %d: %w", activityID, err)
+	}
+	details, err := ParseActivityDetails(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gc.getOptionalJSON(ctx, basePath+"/splits", &splits); err != nil {
+		return nil, fmt.Errorf("failed to get splits of activity %d: %w", activityID, err)
+	}
+	if details.Splits, err = ParseSplits(splits); err != nil {
+		return nil, err
+	}
+
+	if err := gc.getOptionalJSON(ctx, basePath+"/hrTimeInZones", &hrZones); err != nil {
+		return nil, fmt.Errorf("failed to get HR zones of activity %d: %w", activityID, err)
+	}
+	if details.HRZones, err = ParseZoneTimes(hrZones); err != nil {
+		return nil, err
+	}
+	if err := gc.getOptionalJSON(ctx, basePath+"/powerTimeInZones", &powerZones); err != nil {
+		return nil, fmt.Errorf("failed to get power zones of activity %d: %w", activityID, err)
+	}
+	if details.PowerZones, err = ParseZoneTimes(powerZones); err != nil {
+		return nil, err
+	}
+
+	if err := gc.getOptionalJSON(ctx, basePath+"/weather", &weather); err != nil {
+		return nil, fmt.Errorf("failed to get weather of activity %d: %w", activityID, err)
+	}
+	if details.Weather, err = ParseWeather(weather); err != nil {
+		return nil, err
+	}
+
+	return details, nil
+}
+
+// ParseActivityDetails converts an activity response, without its splits,
+// zones and weather
+func ParseActivityDetails(body []byte) (*gormin.ActivityDetails, error) {
+	var garminActivity GarminActivityDetail
+	if err := unmarshalBody(body, &garminActivity); err != nil {
+		return nil, fmt.Errorf("failed to parse activity: %w", err)
+	}
+
+	summary := garminActivity.SummaryDTO
+	cadence := summary.AverageRunCadence
+	if cadence == 0 {
+		cadence = summary.AverageBikeCadence
+	}
+
+	// Convert to our ActivityDetails struct
+	return &gormin.ActivityDetails{
+		Activity: gormin.Activity{
+			ID:            garminActivity.ActivityID,
+			Name:          garminActivity.ActivityName,
+			Type:          garminActivity.ActivityTypeDTO.TypeKey,
+			StartTime:     formatGMT(summary.StartTimeLocal),
+			Duration:      int(summary.Duration),
+			Distance:      summary.Distance / 1000.0, // Convert meters to km
+			Calories:      int(summary.Calories),
+			AvgHR:         int(summary.AverageHR),
+			MaxHR:         int(summary.MaxHR),
+			ElevationGain: int(summary.ElevationGain),
+		},
+		Description:    garminActivity.Description,
+		Privacy:        garminActivity.AccessControlRuleDTO.TypeKey,
+		MovingDuration: int(summary.MovingDuration),
+		ElevationLoss:  int(summary.ElevationLoss),
+		AvgSpeed:       summary.AverageSpeed,
+		MaxSpeed:       summary.MaxSpeed,
+		AvgPower:       int(summary.AveragePower),
+		MaxPower:       int(summary.MaxPower),
+		AvgCadence:     cadence,
+		AerobicTE:      summary.TrainingEffect,
+		AnaerobicTE:    summary.AnaerobicTrainingEffect,
+	}, nil
+}
+
+// ParseSplits converts a splits response; an empty body has no splits
+func ParseSplits(body []byte) ([]gormin.ActivitySplit, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	var splits GarminSplits
+	if err := json.Unmarshal(body, &splits); err != nil {
+		return nil, fmt.Errorf("failed to parse splits: %w", err)
+	}
+
+	var result []gormin.ActivitySplit
+	for _, lap := range splits.LapDTOs {
+		result = append(result, gormin.ActivitySplit{
+			Index:         lap.LapIndex,
+			StartTime:     formatGMT(lap.StartTimeGMT),
+			Duration:      int(lap.Duration),
+			Distance:      lap.Distance / 1000.0,
+			AvgSpeed:      lap.AverageSpeed,
+			AvgHR:         int(lap.AverageHR),
+			MaxHR:         int(lap.MaxHR),
+			ElevationGain: int(lap.ElevationGain),
+			ElevationLoss: int(lap.ElevationLoss),
+			Calories:      int(lap.Calories),
+		})
+	}
+	return result, nil
+}
+
+// ParseZoneTimes converts a time in HR or power zones response; an empty body
+// has no zones
+func ParseZoneTimes(body []byte) ([]gormin.ZoneTime, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	var zones []GarminZoneTime
+	if err := json.Unmarshal(body, &zones); err != nil {
+		return nil, fmt.Errorf("failed to parse zones: %w", err)
+	}
+	return zoneTimes(zones), nil
+}
+
+// ParseWeather converts a weather response, returning nil when Connect has no
+// weather for the activity
+func ParseWeather(body []byte) (*gormin.ActivityWeather, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	var weather *GarminWeather
+	if err := json.Unmarshal(body, &weather); err != nil {
+		return nil, fmt.Errorf("failed to parse weather: %w", err)
+	}
+	if weather == nil || weather.IssueDate == "" {
+		return nil, nil
+	}
+
+	return &gormin.ActivityWeather{
+		Temperature:         fahrenheitToCelsius(weather.Temp),
+		ApparentTemperature: fahrenheitToCelsius(weather.ApparentTemp),
+		DewPoint:            fahrenheitToCelsius(weather.DewPoint),
+		Humidity:            weather.RelativeHumidity,
+		WindSpeed:           weather.WindSpeed * 1.609344, // Convert mph to km/h
+		WindGust:            weather.WindGust * 1.609344,
+		WindDirection:       weather.WindDirectionCompassPoint,
+		Condition:           weather.WeatherTypeDTO.Desc,
+		IssuedAt:            formatGMT(weather.IssueDate),
+	}, nil
+}
+
+// getOptionalJSON behaves like getJSON but treats a missing resource as empty
+func (gc *GarminConnect) getOptionalJSON(ctx context.Context, path string, v interface{}) error {
+	err := gc.getJSON(ctx, path, v)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// zoneTimes converts Connect zone times to our ZoneTime struct
+func zoneTimes(zones []GarminZoneTime) []gormin.ZoneTime {
+	var result []gormin.ZoneTime
+	for _, z := range zones {
+		result = append(result, gormin.ZoneTime{
+			Zone:        z.ZoneNumber,
+			Seconds:     int(z.SecsInZone),
+			LowBoundary: z.ZoneLowBoundary,
+		})
+	}
+	return result
+}
+
+// fahrenheitToCelsius converts a temperature from Fahrenheit to Celsius
+func fahrenheitToCelsius(f float64) float64 {
+	return (f - 32) * 5 / 9
+}
+
+// ActivityUpdate holds the activity metadata to change; nil fields are left as they are
+type ActivityUpdate struct {
+	Name        *string
+	Type        *string
+	Description *string
+	Privacy     *string // "public", "subscribers" or "private"
+}
+
+// garminActivityUpdate is the body of an activity update request
+type garminActivityUpdate struct {
+	ActivityID      int            `json:"activityId"`
+	ActivityName    *string        `json:"activityName,omitempty"`
+	Description     *string        `json:"description,omitempty"`
+	ActivityTypeDTO *garminTypeKey `json:"activityTypeDTO,omitempty"`
+	AccessControl   *garminTypeKey `json:"accessControlRuleDTO,omitempty"`
+}
+
+// garminTypeKey is a Connect DTO identified by its type key
+type garminTypeKey struct {
+	TypeKey string `json:"typeKey"`
+}
+
+// activityPrivacy lists the privacy settings accepted by Connect
+var activityPrivacy = map[string]bool{
+	"public":      true,
+	"subscribers": true,
+	"private":     true,
+}
+
+// ValidPrivacy reports whether privacy is a setting accepted by Connect
+func ValidPrivacy(privacy string) bool {
+	return activityPrivacy[privacy]
+}
+
+// UpdateActivity changes the name, type, description or privacy of an activity
+func (gc *GarminConnect) UpdateActivity(ctx context.Context, activityID int, update ActivityUpdate) error {
+	body := garminActivityUpdate{
+		ActivityID:   activityID,
+		ActivityName: update.Name,
+		Description:  update.Description,
+	}
+	if update.Type != nil {
+		body.ActivityTypeDTO = &garminTypeKey{TypeKey: *update.Type}
+	}
+	if update.Privacy != nil {
+		if !activityPrivacy[*update.Privacy] {
+			return fmt.Errorf("invalid privacy %q: expected public, subscribers or private", *update.Privacy)
+		}
+		body.AccessControl = &garminTypeKey{TypeKey: *update.Privacy}
+	}
+
+	path := fmt.Sprintf("/modern/proxy/activity-service/activity/%d", activityID)
+	if err := gc.sendJSON(ctx, "PUT", path, body, nil); err != nil {
+		return fmt.Errorf("failed to update activity %d: %w", activityID, err)
+	}
+	return nil
+}
+
+// SetActivityName renames an activity
+func (gc *GarminConnect) SetActivityName(ctx context.Context, activityID int, name string) error {
+	return gc.UpdateActivity(ctx, activityID, ActivityUpdate{Name: &name})
+}
+
+// SetActivityType changes the type of an activity, e.g. "cycling" or "commuting"
+func (gc *GarminConnect) SetActivityType(ctx context.Context, activityID int, typeKey string) error {
+	return gc.UpdateActivity(ctx, activityID, ActivityUpdate{Type: &typeKey})
+}
+
+// SetActivityDescription changes the description of an activity
+func (gc *GarminConnect) SetActivityDescription(ctx context.Context, activityID int, description string) error {
+	return gc.UpdateActivity(ctx, activityID, ActivityUpdate{Description: &description})
+}
+
+// SetActivityPrivacy changes who can see an activity
+func (gc *GarminConnect) SetActivityPrivacy(ctx context.Context, activityID int, privacy string) error {
+	return gc.UpdateActivity(ctx, activityID, ActivityUpdate{Privacy: &privacy})
+}
@@ -0,0 +1,92 @@
+package connect
+
+import (
+	"net/url"
+	"regexp"
+)
+
+// ResponseKind identifies what a recorded response holds, so archived
+// responses can be parsed again with the matching Parse function
+type ResponseKind string
+
+// Kinds of recorded responses besides EndpointActivities and EndpointDailyStats
+const (
+	ResponseActivityDetails ResponseKind = "activity_details"
+	ResponseSplits          ResponseKind = "splits"
+	ResponseHRZones         ResponseKind = "hr_zones"
+	ResponsePowerZones      ResponseKind = "power_zones"
+	ResponseWeather         ResponseKind = "weather"
+	ResponseGear            ResponseKind = "gear"
+	ResponseActivityGear    ResponseKind = "activity_gear"
+	ResponseGearStats       ResponseKind = "gear_stats"
+	ResponseDevices         ResponseKind = "devices"
+	ResponseWorkout         ResponseKind = "workout"
+	ResponseCourse          ResponseKind = "course"
+	ResponsePersonalRecords ResponseKind = "personal_records"
+	ResponseHeartRate       ResponseKind = "heart_rate"
+	ResponseSleep           ResponseKind = "sleep"
+	ResponseHRV             ResponseKind = "hrv"
+	ResponsePulseOx         ResponseKind = "pulse_ox"
+	ResponseBodyBattery     ResponseKind = "body_battery"
+	ResponseStress          ResponseKind = "stress"
+	ResponseRespiration     ResponseKind = "respiration"
+	ResponseWeight          ResponseKind = "weight"
+)
+
+// responsePatterns match the request paths responses are recorded under. The
+// first submatch, if any, is the id or date the response belongs to.
+var responsePatterns = []struct {
+	kind    ResponseKind
+	pattern *regexp.Regexp
+}{
+	{ResponseActivityDetails, regexp.MustCompile(`^/modern/proxy/activity-service/activity/(\d+)$`)},
+	{ResponseSplits, regexp.MustCompile(`^/modern/proxy/activity-service/activity/(\d+)/splits$`)},
+	{ResponseHRZones, regexp.MustCompile(`^/modern/proxy/activity-service/activity/(\d+)/hrTimeInZones$`)},
+	{ResponsePowerZones, regexp.MustCompile(`^/modern/proxy/activity-service/activity/(\d+)/powerTimeInZones$`)},
+	{ResponseWeather, regexp.MustCompile(`^/modern/proxy/activity-service/activity/(\d+)/weather$`)},
+	{ResponseGear, regexp.MustCompile(`^/modern/proxy/gear-service/gear/filterGear$`)},
+	{ResponseGearStats, regexp.MustCompile(`^/modern/proxy/gear-service/gear/stats/(.+)$`)},
+	{ResponseDevices, regexp.MustCompile(`^/modern/proxy/device-service/deviceregistration/devices$`)},
+	{ResponseWorkout, regexp.MustCompile(`^/modern/proxy/workout-service/workout/(\d+)$`)},
+	{ResponseCourse, regexp.MustCompile(`^/modern/proxy/course-service/course/(\d+)$`)},
+	{ResponsePersonalRecords, regexp.MustCompile(`^/modern/proxy/personalrecord-service/personalrecord/prs/[^/]+$`)},
+	{ResponseHeartRate, regexp.MustCompile(`^/modern/proxy/wellness-service/wellness/dailyHeartRate/[^/]+$`)},
+	{ResponseSleep, regexp.MustCompile(`^/modern/proxy/wellness-service/wellness/dailySleepData/[^/]+$`)},
+	{ResponseHRV, regexp.MustCompile(`^/modern/proxy/hrv-service/hrv/([\d-]+)$`)},
+	{ResponsePulseOx, regexp.MustCompile(`^/modern/proxy/wellness-service/wellness/daily/spo2/([\d-]+)$`)},
+	{ResponseBodyBattery, regexp.MustCompile(`^/modern/proxy/wellness-service/wellness/bodyBattery/reports/daily$`)},
+	{ResponseStress, regexp.MustCompile(`^/modern/proxy/wellness-service/wellness/dailyStress/([\d-]+)$`)},
+	{ResponseRespiration, regexp.MustCompile(`^/modern/proxy/wellness-service/wellness/daily/respiration/([\d-]+)$`)},
+	{ResponseWeight, regexp.MustCompile(`^/modern/proxy/weight-service/weight/dateRange$`)},
+}
+
+// ClassifyResponse returns the kind of a response recorded under endpoint and
+// key, and the activity id, gear UUID, workout or course id or date it
+// belongs to. Lists that are only used to find other responses, such as the
+// profile and the workout and course lists, return an empty kind.
+func ClassifyResponse(endpoint, key string) (kind ResponseKind, id string) {
+	for _, p := range responsePatterns {
+		match := p.pattern.FindStringSubmatch(endpoint)
+		if match == nil {
+			continue
+		}
+		if len(match) > 1 {
+			id = match[1]
+		}
+
+		query, _ := url.ParseQuery(key)
+		switch p.kind {
+		case ResponseGear:
+			// The same path lists the user's gear and the gear of an activity
+			if activityID := query.Get("activityId"); activityID != "" {
+				return ResponseActivityGear, activityID
+			}
+		case ResponseHeartRate, ResponseSleep:
+			id = query.Get("date")
+		case ResponseBodyBattery:
+			id = query.Get("startDate")
+		}
+		return p.kind, id
+	}
+	return "", ""
+}
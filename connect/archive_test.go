@@ -0,0 +1,35 @@
+package connect
+
+import "testing"
+
+func TestClassifyResponse(t *testing.T) {
+	tests := []struct {
+		endpoint, key string
+		kind          ResponseKind
+		id            string
+	}{
+		{"/modern/proxy/activity-service/activity/9000000001", "", ResponseActivityDetails, "9000000001"},
+		{"/modern/proxy/activity-service/activity/9000000001/splits", "", ResponseSplits, "9000000001"},
+		{"/modern/proxy/activity-service/activity/9000000001/powerTimeInZones", "", ResponsePowerZones, "9000000001"},
+		{"/modern/proxy/gear-service/gear/filterGear", "userProfilePk=42", ResponseGear, ""},
+		{"/modern/proxy/gear-service/gear/filterGear", "activityId=9000000001", ResponseActivityGear, "9000000001"},
+		{"/modern/proxy/gear-service/gear/stats/shoe-1", "", ResponseGearStats, "shoe-1"},
+		{"/modern/proxy/workout-service/workout/11", "", ResponseWorkout, "11"},
+		{"/modern/proxy/wellness-service/wellness/dailySleepData/runner", "date=2024-03-04&nonSleepBufferMinutes=60", ResponseSleep, "2024-03-04"},
+		{"/modern/proxy/wellness-service/wellness/dailyHeartRate/runner", "date=2024-03-04", ResponseHeartRate, "2024-03-04"},
+		{"/modern/proxy/wellness-service/wellness/bodyBattery/reports/daily", "startDate=2024-03-04&endDate=2024-03-04", ResponseBodyBattery, "2024-03-04"},
+		{"/modern/proxy/hrv-service/hrv/2024-03-04", "", ResponseHRV, "2024-03-04"},
+		{"/modern/proxy/weight-service/weight/dateRange", "startDate=2024-03-01&endDate=2024-03-04", ResponseWeight, ""},
+		// Lists only used to find other responses
+		{"/modern/proxy/userprofile-service/socialProfile", "", "", ""},
+		{"/modern/proxy/workout-service/workouts", "start=1&limit=999", "", ""},
+		{"/modern/proxy/course-service/course", "", "", ""},
+		{"/modern/proxy/activitylist-service/activities/search/activities", "limit=100&start=0", "", ""},
+	}
+	for _, tt := range tests {
+		kind, id := ClassifyResponse(tt.endpoint, tt.key)
+		if kind != tt.kind || id != tt.id {
+			t.Errorf("ClassifyResponse(%q, %q) = %q, %q; want %q, %q", tt.endpoint, tt.key, kind, id, tt.kind, tt.id)
+		}
+	}
+}
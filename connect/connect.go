@@ -0,0 +1,591 @@
+// Package connect is a client for the Garmin Connect web API. It logs in with
+// a username and password, including the MFA step, and retries and rate
+// limits its requests.
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+)
+
+var (
+	csrfPattern      = regexp.MustCompile(`name="_csrf"\s+value="([^"]+)"`)
+	pageTitlePattern = regexp.MustCompile(`<title>([^<]*)</title>`)
+)
+
+// MFACodeProvider returns the verification code for a two-factor login
+type MFACodeProvider func() (string, error)
+
+// ResponseRecorder receives the raw body of every successful Connect API
+// response, under an endpoint and a key identifying the response within it
+type ResponseRecorder func(endpoint, key string, body []byte) error
+
+// Endpoints of the recorded responses that can be parsed again. Activities
+// are keyed by activity id and daily stats by date; other responses are
+// recorded under their request path and query.
+const (
+	EndpointActivities = "activities"
+	EndpointDailyStats = "daily_stats"
+)
+
+// GarminConnect handles communication with Garmin Connect. Create one with
+// NewGarminConnect and call Login before any other method. Every method takes
+// a context that cancels the request, including its retries.
+type GarminConnect struct {
+	client     *http.Client
+	username   string
+	password   string
+	baseURL    string
+	loggedIn   bool
+	mfaCode    MFACodeProvider
+	recorder   ResponseRecorder
+	limiter    *rateLimiter
+	maxRetries int
+
+	profile *socialProfile
+}
+
+// LoginResponse represents the login response from Garmin Connect
+type LoginResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ActivityListResponse represents the activity list response
+type ActivityListResponse struct {
+	Activities []gormin.Activity `json:"activities"`
+}
+
+// GarminActivity represents an activity from Garmin Connect API
+type GarminActivity struct {
+	ActivityID      int     `json:"activityId"`
+	ActivityName    string  `json:"activityName"`
+	ActivityTypeKey string  `json:"activityTypeKey"`
+	StartTimeLocal  string  `json:"startTimeLocal"`
+	Duration        float64 `json:"duration"`
+	Distance        float64 `json:"distance"`
+	Calories        float64 `json:"calories"`
+	AverageHR       float64 `json:"averageHR"`
+	MaxHR           float64 `json:"maxHR"`
+	ElevationGain   float64 `json:"elevationGain"`
+}
+
+// GarminDailyStats represents daily statistics from Garmin Connect
+type GarminDailyStats struct {
+	CalendarDate   string  `json:"calendarDate"`
+	TotalSteps     int     `json:"totalSteps"`
+	TotalDistance  float64 `json:"totalDistance"`
+	ActiveCalories int     `json:"activeCalories"`
+	RestingHR      int     `json:"restingHeartRate"`
+	SleepDuration  int     `json:"sleepDuration"`
+	BodyWeight     float64 `json:"bodyWeight"`
+	BodyFatPercent float64 `json:"bodyFatPercent"`
+
+	BodyBatteryLowestValue    int     `json:"bodyBatteryLowestValue"`
+	BodyBatteryHighestValue   int     `json:"bodyBatteryHighestValue"`
+	BodyBatteryChargedValue   int     `json:"bodyBatteryChargedValue"`
+	BodyBatteryDrainedValue   int     `json:"bodyBatteryDrainedValue"`
+	AverageStressLevel        int     `json:"averageStressLevel"`
+	MaxStressLevel            int     `json:"maxStressLevel"`
+	LowestRespirationValue    float64 `json:"lowestRespirationValue"`
+	HighestRespirationValue   float64 `json:"highestRespirationValue"`
+	AvgWakingRespirationValue float64 `json:"avgWakingRespirationValue"`
+}
+
+// NewGarminConnect creates a new Garmin Connect client
+func NewGarminConnect(username, password string) *GarminConnect {
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{
+		Jar:     jar,
+		Timeout: 30 * time.Second,
+	}
+
+	return &GarminConnect{
+		client:     client,
+		username:   username,
+		password:   password,
+		baseURL:    "https://connect.garmin.com",
+		loggedIn:   false,
+		limiter:    newRateLimiter(defaultRequestsPerMinute),
+		maxRetries: defaultMaxRetries,
+	}
+}
+
+// SetRequestsPerMinute limits how many API requests the client makes per minute
+func (gc *GarminConnect) SetRequestsPerMinute(perMinute int) {
+	gc.limiter = newRateLimiter(perMinute)
+}
+
+// SetMaxRetries sets how many times a failed request is retried
+func (gc *GarminConnect) SetMaxRetries(maxRetries int) {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	gc.maxRetries = maxRetries
+}
+
+// SetHTTPClient replaces the HTTP client requests are sent with, e.g. to go
+// through a proxy or to a test server. The client should keep cookies, as the
+// login session lives in them.
+func (gc *GarminConnect) SetHTTPClient(client *http.Client) {
+	gc.client = client
+}
+
+// SetMFACodeProvider sets the function used to obtain the verification code
+// when the account has two-factor authentication enabled
+func (gc *GarminConnect) SetMFACodeProvider(provider MFACodeProvider) {
+	gc.mfaCode = provider
+}
+
+// SetResponseRecorder sets the function every raw API response is passed to,
+// for archiving responses before they are mapped to our types
+func (gc *GarminConnect) SetResponseRecorder(recorder ResponseRecorder) {
+	gc.recorder = recorder
+}
+
+// record passes a response body to the recorder, if one is set
+func (gc *GarminConnect) record(endpoint, key string, body []byte) error {
+	if gc.recorder == nil {
+		return nil
+	}
+	if err := gc.recorder(endpoint, key, body); err != nil {
+		return fmt.Errorf("failed to record %s response: %w", endpoint, err)
+	}
+	return nil
+}
+
+// Login authenticates with Garmin Connect
+func (gc *GarminConnect) Login(ctx context.Context) error {
+	fmt.Println("Logging into Garmin Connect...")
+
+	// Step 1: Get SSO login page
+	ssoURL := "https://sso.garmin.com/sso/signin"
+	params := url.Values{}
+	params.Set("service", "https://connect.garmin.com/modern/")
+	params.Set("webhost", "https://connect.garmin.com/modern/")
+	params.Set("source", "https://connect.garmin.com/signin/")
+	params.Set("redirectAfterAccountLoginUrl", "https://connect.garmin.com/modern/")
+	params.Set("redirectAfterAccountCreationUrl", "https://connect.garmin.com/modern/")
+	params.Set("gauthHost", "https://sso.garmin.com/sso")
+	params.Set("locale", "en_US")
+	params.Set("id", "gauth-widget")
+	params.Set("cssUrl", "https://static.garmincdn.com/com.garmin.connect/ui/css/gauth-custom-v1.2-min.css")
+	params.Set("privacyStatementUrl", "https://www.garmin.com/en-US/privacy/connect/")
+	params.Set("clientId", "GarminConnect")
+	params.Set("rememberMeShown", "true")
+	params.Set("rememberMeChecked", "false")
+	params.Set("createAccountShown", "true")
+	params.Set("openCreateAccount", "false")
+	params.Set("displayNameShown", "false")
+	params.Set("consumeServiceTicket", "false")
+	params.Set("initialFocus", "true")
+	params.Set("embedWidget", "false")
+	params.Set("generateExtraServiceTicket", "true")
+	params.Set("generateTwoExtraServiceTickets", "false")
+	params.Set("generateNoServiceTicket", "false")
+	params.Set("globalOptInShown", "true")
+	params.Set("globalOptInChecked", "false")
+	params.Set("mobile", "false")
+	params.Set("connectLegalTerms", "true")
+	params.Set("showTermsOfUse", "false")
+	params.Set("showPrivacyPolicy", "false")
+	params.Set("showConnectLegalAge", "false")
+	params.Set("locationPromptShown", "true")
+	params.Set("showPassword", "true")
+	params.Set("useCustomHeader", "false")
+
+	loginPageURL := ssoURL + "?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", loginPageURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create login page request: %w", err)
+	}
+
+	resp, err := gc.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get login page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	loginPage, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read login page: %w", err)
+	}
+
+	// Step 2: Submit login credentials
+	loginData := url.Values{}
+	loginData.Set("username", gc.username)
+	loginData.Set("password", gc.password)
+	loginData.Set("embed", "false")
+	loginData.Set("_eventId", "submit")
+	loginData.Set("displayNameRequired", "false")
+	if csrf := extractCSRF(string(loginPage)); csrf != "" {
+		loginData.Set("_csrf", csrf)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, "POST", ssoURL, strings.NewReader(loginData.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create login request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "GarminDB-Go/1.0")
+	req.Header.Set("Referer", loginPageURL)
+
+	resp, err = gc.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to submit login: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check if login was successful by looking for service tickets
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read login response: %w", err)
+	}
+
+	responseBody := string(body)
+	if strings.Contains(responseBody, "Invalid username or password") {
+		return fmt.Errorf("invalid username or password")
+	}
+
+	// Step 3: Answer the two-factor challenge if the account requires it
+	if isMFAChallenge(responseBody) {
+		responseBody, err = gc.submitMFACode(ctx, params, responseBody)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Extract service ticket from response
+	if !strings.Contains(responseBody, "ticket=") {
+		return fmt.Errorf("login failed: no service ticket found")
+	}
+
+	gc.loggedIn = true
+	fmt.Println("Successfully logged into Garmin Connect")
+	return nil
+}
+
+// submitMFACode asks the code provider for a verification code and posts it
+// to the SSO MFA endpoint, returning the body of the resulting page
+func (gc *GarminConnect) submitMFACode(ctx context.Context, params url.Values, challengePage string) (string, error) {
+	if gc.mfaCode == nil {
+		return "", fmt.Errorf("login requires an MFA code but no code provider is configured")
+	}
+
+	code, err := gc.mfaCode()
+	if err != nil {
+		return "", fmt.Errorf("failed to read MFA code: %w", err)
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return "", fmt.Errorf("empty MFA code")
+	}
+
+	mfaData := url.Values{}
+	mfaData.Set("mfa-verification-code", code)
+	mfaData.Set("embed", "false")
+	mfaData.Set("fromPage", "setupEnterMfaCode")
+	if csrf := extractCSRF(challengePage); csrf != "" {
+		mfaData.Set("_csrf", csrf)
+	}
+
+	mfaURL := "https://sso.garmin.com/sso/verifyMFA/loginEnterMfaCode?" + params.Encode()
+	req, err := http.NewRequestWithContext(ctx, "POST", mfaURL, strings.NewReader(mfaData.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create MFA request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "GarminDB-Go/1.0")
+	req.Header.Set("Referer", mfaURL)
+
+	resp, err := gc.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to submit MFA code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read MFA response: %w", err)
+	}
+
+	responseBody := string(body)
+	if isMFAChallenge(responseBody) {
+		return "", fmt.Errorf("invalid MFA code")
+	}
+
+	return responseBody, nil
+}
+
+// isMFAChallenge reports whether an SSO page is asking for a verification code
+func isMFAChallenge(page string) bool {
+	if strings.Contains(page, "verifyMFA") {
+		return true
+	}
+	match := pageTitlePattern.FindStringSubmatch(page)
+	return match != nil && strings.Contains(match[1], "MFA")
+}
+
+// extractCSRF returns the CSRF token embedded in an SSO form
+func extractCSRF(page string) string {
+	match := csrfPattern.FindStringSubmatch(page)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// socialProfile represents the parts of the user profile the client needs
+type socialProfile struct {
+	DisplayName string `json:"displayName"`
+	ProfileID   int64  `json:"profileId"`
+}
+
+// userProfile fetches the profile of the logged in user once and caches it
+func (gc *GarminConnect) userProfile(ctx context.Context) (*socialProfile, error) {
+	if gc.profile != nil {
+		return gc.profile, nil
+	}
+
+	var profile socialProfile
+	if err := gc.getJSON(ctx, "/modern/proxy/userprofile-service/socialProfile", &profile); err != nil {
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
+	}
+	if profile.DisplayName == "" {
+		return nil, fmt.Errorf("user profile has no display name")
+	}
+
+	gc.profile = &profile
+	return gc.profile, nil
+}
+
+// DisplayName returns the Connect display name of the logged in user, which
+// identifies the user in wellness endpoints
+func (gc *GarminConnect) DisplayName(ctx context.Context) (string, error) {
+	profile, err := gc.userProfile(ctx)
+	if err != nil {
+		return "", err
+	}
+	return profile.DisplayName, nil
+}
+
+// ProfileID returns the numeric profile id of the logged in user
+func (gc *GarminConnect) ProfileID(ctx context.Context) (int64, error) {
+	profile, err := gc.userProfile(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return profile.ProfileID, nil
+}
+
+// GetActivities retrieves activities from Garmin Connect
+func (gc *GarminConnect) GetActivities(ctx context.Context, limit, start int) ([]gormin.Activity, error) {
+	if !gc.loggedIn {
+		if err := gc.Login(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Printf("Fetching %d activities starting from %d...\n", limit, start)
+
+	url := fmt.Sprintf("%s/modern/proxy/activitylist-service/activities/search/activities?limit=%d&start=%d",
+		gc.baseURL, limit, start)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", "GarminDB-Go/1.0")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("NK", "NT") // Required for some Garmin Connect endpoints
+
+	resp, err := gc.doRequest(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get activities: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse activities: %w", err)
+	}
+
+	// Record each activity on its own, as pages shift when activities are added
+	var activities []gormin.Activity
+	for _, r := range raw {
+		activity, err := ParseActivity(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := gc.record(EndpointActivities, strconv.Itoa(activity.ID), r); err != nil {
+			return nil, err
+		}
+		activities = append(activities, *activity)
+	}
+
+	fmt.Printf("Retrieved %d activities\n", len(activities))
+	return activities, nil
+}
+
+// ParseActivity converts an activity of the activity list response
+func ParseActivity(body []byte) (*gormin.Activity, error) {
+	var ga GarminActivity
+	if err := json.Unmarshal(body, &ga); err != nil {
+		return nil, fmt.Errorf("failed to parse activity: %w", err)
+	}
+
+	return &gormin.Activity{
+		ID:            ga.ActivityID,
+		Name:          ga.ActivityName,
+		Type:          ga.ActivityTypeKey,
+		StartTime:     ga.StartTimeLocal,
+		Duration:      int(ga.Duration),
+		Distance:      ga.Distance / 1000.0, // Convert meters to km
+		Calories:      int(ga.Calories),
+		AvgHR:         int(ga.AverageHR),
+		MaxHR:         int(ga.MaxHR),
+		ElevationGain: int(ga.ElevationGain),
+	}, nil
+}
+
+// GetDailyStats retrieves daily statistics
+func (gc *GarminConnect) GetDailyStats(ctx context.Context, date time.Time) (*gormin.DailyStats, error) {
+	if !gc.loggedIn {
+		if err := gc.Login(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	dateStr := date.Format("2006-01-02")
+	url := fmt.Sprintf("%s/modern/proxy/userstats-service/wellness/daily/%s",
+		gc.baseURL, dateStr)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", "GarminDB-Go/1.0")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("NK", "NT")
+
+	resp, err := gc.doRequest(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gc.record(EndpointDailyStats, dateStr, body); err != nil {
+		return nil, err
+	}
+	return ParseDailyStats(body)
+}
+
+// ParseDailyStats converts a daily statistics response
+func ParseDailyStats(body []byte) (*gormin.DailyStats, error) {
+	var garminStats GarminDailyStats
+	if err := json.Unmarshal(body, &garminStats); err != nil {
+		return nil, fmt.Errorf("failed to parse daily stats: %w", err)
+	}
+
+	// Convert to our DailyStats struct
+	stats := &gormin.DailyStats{
+		Date:       garminStats.CalendarDate,
+		Steps:      garminStats.TotalSteps,
+		Distance:   garminStats.TotalDistance / 1000.0, // Convert meters to km
+		Calories:   garminStats.ActiveCalories,
+		SleepHours: float64(garminStats.SleepDuration) / 3600.0, // Convert seconds to hours
+		RestingHR:  garminStats.RestingHR,
+		Weight:     garminStats.BodyWeight,
+		BodyFat:    garminStats.BodyFatPercent,
+
+		BodyBatteryMin:     garminStats.BodyBatteryLowestValue,
+		BodyBatteryMax:     garminStats.BodyBatteryHighestValue,
+		BodyBatteryCharged: garminStats.BodyBatteryChargedValue,
+		BodyBatteryDrained: garminStats.BodyBatteryDrainedValue,
+		StressAvg:          garminStats.AverageStressLevel,
+		StressMax:          garminStats.MaxStressLevel,
+		RespirationMin:     garminStats.LowestRespirationValue,
+		RespirationMax:     garminStats.HighestRespirationValue,
+		RespirationAvg:     garminStats.AvgWakingRespirationValue,
+	}
+
+	return stats, nil
+}
+
+// DownloadFitFile downloads a FIT file for an activity
+func (gc *GarminConnect) DownloadFitFile(ctx context.Context, activityID int, outputPath string) error {
+	if !gc.loggedIn {
+		if err := gc.Login(ctx); err != nil {
+			return err
+		}
+	}
+
+	url := fmt.Sprintf("%s/modern/proxy/download-service/files/activity/%d",
+		gc.baseURL, activityID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("User-Agent", "GarminDB-Go/1.0")
+	req.Header.Set("NK", "NT")
+
+	resp, err := gc.doRequest(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to download FIT file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Write to a temporary file first so an interrupted download never
+	// leaves a truncated FIT file behind
+	file, err := os.CreateTemp(filepath.Dir(outputPath), filepath.Base(outputPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write FIT file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, outputPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
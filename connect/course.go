@@ -0,0 +1,95 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// GarminCourse represents a saved course from the Connect course service
+type GarminCourse struct {
+	CourseID           int                 `json:"courseId"`
+	CourseName         string              `json:"courseName"`
+	Description        string              `json:"description"`
+	DistanceMeter      float64             `json:"distanceMeter"`
+	ElevationGainMeter float64             `json:"elevationGainMeter"`
+	ElevationLossMeter float64             `json:"elevationLossMeter"`
+	ActivityType       GarminActivityType  `json:"activityType"`
+	GeoPoints          []GarminCoursePoint `json:"geoPoints"`
+}
+
+// GarminActivityType identifies the sport of a course
+type GarminActivityType struct {
+	TypeKey string `json:"typeKey"`
+}
+
+// GarminCoursePoint is a point of a Connect course track
+type GarminCoursePoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Elevation float64 `json:"elevation"`
+	Distance  float64 `json:"distance"`
+}
+
+// GetCourses retrieves the saved courses of the logged in user, including their tracks
+func (gc *GarminConnect) GetCourses(ctx context.Context) ([]gormin.Course, error) {
+	var list []GarminCourse
+	if err := gc.getJSON(ctx, "/modern/proxy/course-service/course", &list); err != nil {
+		return nil, fmt.Errorf("failed to get courses: %w", err)
+	}
+
+	var courses []gormin.Course
+	for _, c := range list {
+		course, err := gc.GetCourse(ctx, c.CourseID)
+		if err != nil {
+			return nil, err
+		}
+		courses = append(courses, *course)
+	}
+
+	return courses, nil
+}
+
+// GetCourse retrieves a single course with its track
+func (gc *GarminConnect) GetCourse(ctx context.Context, courseID int) (*gormin.Course, error) {
+	path := fmt.Sprintf("/modern/proxy/course-service/course/%d", courseID)
+
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, path, &body); err != nil {
+		return nil, fmt.Errorf("failed to get course %d: %w", courseID, err)
+	}
+
+	return ParseCourse(body)
+}
+
+// ParseCourse converts a course response
+func ParseCourse(body []byte) (*gormin.Course, error) {
+	var garminCourse GarminCourse
+	if err := unmarshalBody(body, &garminCourse); err != nil {
+		return nil, fmt.Errorf("failed to parse course: %w", err)
+	}
+
+	course := &gormin.Course{
+		GarminID:     garminCourse.CourseID,
+		Name:         garminCourse.CourseName,
+		Sport:        garminCourse.ActivityType.TypeKey,
+		Description:  garminCourse.Description,
+		Distance:     garminCourse.DistanceMeter / 1000.0, // Convert meters to km
+		TotalAscent:  int(garminCourse.ElevationGainMeter),
+		TotalDescent: int(garminCourse.ElevationLossMeter),
+		Source:       gormin.SourceConnect,
+	}
+	for _, p := range garminCourse.GeoPoints {
+		course.Points = append(course.Points, gormin.CoursePoint{
+			Latitude:  p.Latitude,
+			Longitude: p.Longitude,
+			Elevation: p.Elevation,
+			Distance:  p.Distance,
+		})
+	}
+
+	gormin.FinishCourse(course)
+	return course, nil
+}
@@ -0,0 +1,58 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// GarminDevice represents a registered device from the Connect device service
+type GarminDevice struct {
+	UnitID                 int64  `json:"unitId"`
+	ProductDisplayName     string `json:"productDisplayName"`
+	DisplayName            string `json:"displayName"`
+	PartNumber             string `json:"partNumber"`
+	CurrentFirmwareVersion string `json:"currentFirmwareVersion"`
+}
+
+// GetDevices retrieves the devices registered to the logged in user
+func (gc *GarminConnect) GetDevices(ctx context.Context) ([]gormin.Device, error) {
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, "/modern/proxy/device-service/deviceregistration/devices", &body); err != nil {
+		return nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+
+	return ParseDevices(body)
+}
+
+// ParseDevices converts a registered devices response
+func ParseDevices(body []byte) ([]gormin.Device, error) {
+	var garminDevices []GarminDevice
+	if err := unmarshalBody(body, &garminDevices); err != nil {
+		return nil, fmt.Errorf("failed to parse devices: %w", err)
+	}
+
+	// Convert to our Device struct; the unit id is the serial number found in FIT files
+	var devices []gormin.Device
+	for _, d := range garminDevices {
+		name := d.ProductDisplayName
+		if name == "" {
+			name = d.DisplayName
+		}
+		device := gormin.Device{
+			Manufacturer:    "garmin",
+			ProductName:     name,
+			SoftwareVersion: d.CurrentFirmwareVersion,
+			DeviceType:      "creator",
+			Source:          gormin.SourceConnect,
+		}
+		if d.UnitID != 0 {
+			device.SerialNumber = fmt.Sprintf("%d", d.UnitID)
+		}
+		devices = append(devices, device)
+	}
+
+	return devices, nil
+}
@@ -0,0 +1,112 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// GarminGear represents a gear item from the Connect gear service
+type GarminGear struct {
+	UUID            string  `json:"uuid"`
+	DisplayName     string  `json:"displayName"`
+	CustomMakeModel string  `json:"customMakeModel"`
+	GearTypeName    string  `json:"gearTypeName"`
+	GearStatusName  string  `json:"gearStatusName"`
+	DateBegin       string  `json:"dateBegin"`
+	MaximumMeters   float64 `json:"maximumMeters"`
+}
+
+// GarminGearStats represents the lifetime usage of a gear item
+type GarminGearStats struct {
+	UUID            string  `json:"uuid"`
+	TotalDistance   float64 `json:"totalDistance"`
+	TotalActivities int     `json:"totalActivities"`
+}
+
+// GetGear retrieves all gear of the logged in user
+func (gc *GarminConnect) GetGear(ctx context.Context) ([]gormin.Gear, error) {
+	profileID, err := gc.ProfileID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("/modern/proxy/gear-service/gear/filterGear?userProfilePk=%d", profileID)
+
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, path, &body); err != nil {
+		return nil, fmt.Errorf("failed to get gear: %w", err)
+	}
+
+	return ParseGear(body)
+}
+
+// GetActivityGear retrieves the gear used for an activity
+func (gc *GarminConnect) GetActivityGear(ctx context.Context, activityID int) ([]gormin.Gear, error) {
+	path := fmt.Sprintf("/modern/proxy/gear-service/gear/filterGear?activityId=%d", activityID)
+
+	var body json.RawMessage
+	if err := gc.getOptionalJSON(ctx, path, &body); err != nil {
+		return nil, fmt.Errorf("failed to get gear of activity %d: %w", activityID, err)
+	}
+
+	return ParseGear(body)
+}
+
+// GetGearStats fills in the lifetime distance and activity count of a gear
+// item, which cover its whole history rather than only the synced activities
+func (gc *GarminConnect) GetGearStats(ctx context.Context, gear *gormin.Gear) error {
+	path := fmt.Sprintf("/modern/proxy/gear-service/gear/stats/%s", gear.UUID)
+
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, path, &body); err != nil {
+		return fmt.Errorf("failed to get stats of gear %s: %w", gear.UUID, err)
+	}
+
+	return ParseGearStats(body, gear)
+}
+
+// ParseGearStats fills in the lifetime totals of gear from a gear stats response
+func ParseGearStats(body []byte, gear *gormin.Gear) error {
+	var stats GarminGearStats
+	if err := unmarshalBody(body, &stats); err != nil {
+		return fmt.Errorf("failed to parse gear stats: %w", err)
+	}
+
+	gear.TotalDistance = stats.TotalDistance / 1000.0 // Convert meters to km
+	gear.TotalActivities = stats.TotalActivities
+	return nil
+}
+
+// ParseGear converts a gear list response, of the user or of an activity; an
+// empty body has no gear
+func ParseGear(body []byte) ([]gormin.Gear, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	var garminGear []GarminGear
+	if err := json.Unmarshal(body, &garminGear); err != nil {
+		return nil, fmt.Errorf("failed to parse gear: %w", err)
+	}
+
+	var gear []gormin.Gear
+	for _, g := range garminGear {
+		name := g.DisplayName
+		if name == "" {
+			name = g.CustomMakeModel
+		}
+		gear = append(gear, gormin.Gear{
+			UUID:        g.UUID,
+			Name:        name,
+			MakeModel:   g.CustomMakeModel,
+			Type:        strings.ToLower(g.GearTypeName),
+			Status:      g.GearStatusName,
+			DateBegin:   formatGMT(g.DateBegin),
+			MaxDistance: g.MaximumMeters / 1000.0, // Convert meters to km
+		})
+	}
+	return gear, nil
+}
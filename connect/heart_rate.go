@@ -0,0 +1,69 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// GarminHeartRateDay represents the daily heart rate response from Garmin Connect
+type GarminHeartRateDay struct {
+	CalendarDate     string      `json:"calendarDate"`
+	RestingHeartRate int         `json:"restingHeartRate"`
+	MinHeartRate     int         `json:"minHeartRate"`
+	MaxHeartRate     int         `json:"maxHeartRate"`
+	HeartRateValues  [][]float64 `json:"heartRateValues"`
+}
+
+// GetHeartRate retrieves all-day heart rate values (2-minute resolution) and
+// resting heart rate for a day
+func (gc *GarminConnect) GetHeartRate(ctx context.Context, date time.Time) (*gormin.HeartRateDay, error) {
+	displayName, err := gc.DisplayName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dateStr := date.Format("2006-01-02")
+	path := fmt.Sprintf("/modern/proxy/wellness-service/wellness/dailyHeartRate/%s?date=%s",
+		url.PathEscape(displayName), dateStr)
+
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, path, &body); err != nil {
+		return nil, fmt.Errorf("failed to get heart rate: %w", err)
+	}
+
+	return ParseHeartRate(dateStr, body)
+}
+
+// ParseHeartRate converts the daily heart rate response of a day
+func ParseHeartRate(dateStr string, body []byte) (*gormin.HeartRateDay, error) {
+	var garminHR GarminHeartRateDay
+	if err := unmarshalBody(body, &garminHR); err != nil {
+		return nil, fmt.Errorf("failed to parse heart rate: %w", err)
+	}
+
+	// Convert to our HeartRateDay struct
+	day := &gormin.HeartRateDay{
+		Date:      dateStr,
+		RestingHR: garminHR.RestingHeartRate,
+		MinHR:     garminHR.MinHeartRate,
+		MaxHR:     garminHR.MaxHeartRate,
+	}
+
+	// Values are [timestamp in ms, bpm] pairs; bpm is null while the watch was off
+	for _, value := range garminHR.HeartRateValues {
+		if len(value) < 2 || value[1] <= 0 {
+			continue
+		}
+		day.Samples = append(day.Samples, gormin.HeartRateSample{
+			Timestamp: formatTimestampMillis(int64(value[0])),
+			HeartRate: int(value[1]),
+		})
+	}
+
+	return day, nil
+}
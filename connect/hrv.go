@@ -0,0 +1,109 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// GarminHRVResponse represents the HRV status response from Garmin Connect
+type GarminHRVResponse struct {
+	HRVSummary struct {
+		CalendarDate      string  `json:"calendarDate"`
+		WeeklyAvg         float64 `json:"weeklyAvg"`
+		LastNightAvg      float64 `json:"lastNightAvg"`
+		LastNight5MinHigh float64 `json:"lastNight5MinHigh"`
+		Status            string  `json:"status"`
+		Baseline          struct {
+			LowUpper      float64 `json:"lowUpper"`
+			BalancedLow   float64 `json:"balancedLow"`
+			BalancedUpper float64 `json:"balancedUpper"`
+		} `json:"baseline"`
+	} `json:"hrvSummary"`
+}
+
+// GarminPulseOx represents the daily pulse ox response from Garmin Connect
+type GarminPulseOx struct {
+	CalendarDate         string  `json:"calendarDate"`
+	AverageSpO2          float64 `json:"averageSpO2"`
+	LowestSpO2           float64 `json:"lowestSpO2"`
+	AvgSleepSpO2         float64 `json:"avgSleepSpO2"`
+	LastSevenDaysAvgSpO2 float64 `json:"lastSevenDaysAvgSpO2"`
+}
+
+// GetHRVStatus retrieves the overnight HRV summary and baseline for a day
+func (gc *GarminConnect) GetHRVStatus(ctx context.Context, date time.Time) (*gormin.HRVStatus, error) {
+	dateStr := date.Format("2006-01-02")
+	path := fmt.Sprintf("/modern/proxy/hrv-service/hrv/%s", dateStr)
+
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, path, &body); err != nil {
+		return nil, fmt.Errorf("failed to get HRV status: %w", err)
+	}
+
+	return ParseHRVStatus(dateStr, body)
+}
+
+// ParseHRVStatus converts the HRV response of a day. A day without a reading
+// returns ErrNotFound.
+func ParseHRVStatus(dateStr string, body []byte) (*gormin.HRVStatus, error) {
+	var garminHRV GarminHRVResponse
+	if err := unmarshalBody(body, &garminHRV); err != nil {
+		return nil, fmt.Errorf("failed to parse HRV status: %w", err)
+	}
+
+	summary := garminHRV.HRVSummary
+	if summary.LastNightAvg == 0 && summary.Status == "" {
+		return nil, fmt.Errorf("no HRV status for %s: %w", dateStr, ErrNotFound)
+	}
+
+	// Convert to our HRVStatus struct
+	return &gormin.HRVStatus{
+		Date:                  dateStr,
+		WeeklyAvg:             summary.WeeklyAvg,
+		LastNightAvg:          summary.LastNightAvg,
+		LastNight5MinHigh:     summary.LastNight5MinHigh,
+		BaselineLowUpper:      summary.Baseline.LowUpper,
+		BaselineBalancedLow:   summary.Baseline.BalancedLow,
+		BaselineBalancedUpper: summary.Baseline.BalancedUpper,
+		Status:                summary.Status,
+	}, nil
+}
+
+// GetPulseOx retrieves the SpO2 averages for a day
+func (gc *GarminConnect) GetPulseOx(ctx context.Context, date time.Time) (*gormin.PulseOx, error) {
+	dateStr := date.Format("2006-01-02")
+	path := fmt.Sprintf("/modern/proxy/wellness-service/wellness/daily/spo2/%s", dateStr)
+
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, path, &body); err != nil {
+		return nil, fmt.Errorf("failed to get pulse ox: %w", err)
+	}
+
+	return ParsePulseOx(dateStr, body)
+}
+
+// ParsePulseOx converts the pulse ox response of a day. A day without a
+// reading returns ErrNotFound.
+func ParsePulseOx(dateStr string, body []byte) (*gormin.PulseOx, error) {
+	var garminSpO2 GarminPulseOx
+	if err := unmarshalBody(body, &garminSpO2); err != nil {
+		return nil, fmt.Errorf("failed to parse pulse ox: %w", err)
+	}
+
+	if garminSpO2.AverageSpO2 == 0 && garminSpO2.AvgSleepSpO2 == 0 {
+		return nil, fmt.Errorf("no pulse ox data for %s: %w", dateStr, ErrNotFound)
+	}
+
+	// Convert to our PulseOx struct
+	return &gormin.PulseOx{
+		Date:             dateStr,
+		AvgSpO2:          garminSpO2.AverageSpO2,
+		LowestSpO2:       garminSpO2.LowestSpO2,
+		AvgSleepSpO2:     garminSpO2.AvgSleepSpO2,
+		LastSevenDaysAvg: garminSpO2.LastSevenDaysAvgSpO2,
+	}, nil
+}
@@ -0,0 +1,80 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// GarminPersonalRecord represents a personal record from the Connect
+// personal record service
+type GarminPersonalRecord struct {
+	TypeID         int     `json:"typeId"`
+	ActivityID     int     `json:"activityId"`
+	Value          float64 `json:"value"`
+	PRStartTimeGmt int64   `json:"prStartTimeGmt"`
+}
+
+// personalRecordTypes maps Connect personal record type ids to record types
+// shared with the local best effort engine
+var personalRecordTypes = map[int]string{
+	1:  "1k",
+	2:  "1mi",
+	3:  "5k",
+	4:  "10k",
+	5:  "half_marathon",
+	6:  "marathon",
+	7:  "longest_run",
+	8:  "longest_ride",
+	9:  "most_ascent_ride",
+	10: "max_power_20min",
+	11: "fastest_40k_ride",
+	12: "most_steps_day",
+	13: "most_steps_week",
+	14: "most_steps_month",
+	15: "longest_goal_streak",
+}
+
+// GetPersonalRecords retrieves the personal records of the logged in user
+func (gc *GarminConnect) GetPersonalRecords(ctx context.Context) ([]gormin.PersonalRecord, error) {
+	displayName, err := gc.DisplayName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("/modern/proxy/personalrecord-service/personalrecord/prs/%s", displayName)
+
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, path, &body); err != nil {
+		return nil, fmt.Errorf("failed to get personal records: %w", err)
+	}
+
+	return ParsePersonalRecords(body)
+}
+
+// ParsePersonalRecords converts a personal records response
+func ParsePersonalRecords(body []byte) ([]gormin.PersonalRecord, error) {
+	var garminRecords []GarminPersonalRecord
+	if err := unmarshalBody(body, &garminRecords); err != nil {
+		return nil, fmt.Errorf("failed to parse personal records: %w", err)
+	}
+
+	var records []gormin.PersonalRecord
+	for _, r := range garminRecords {
+		recordType, ok := personalRecordTypes[r.TypeID]
+		if !ok {
+			recordType = fmt.Sprintf("type_%d", r.TypeID)
+		}
+		records = append(records, gormin.PersonalRecord{
+			RecordType:       recordType,
+			Source:           gormin.SourceConnect,
+			Value:            r.Value,
+			GarminActivityID: r.ActivityID,
+			AchievedAt:       formatTimestampMillis(r.PRStartTimeGmt),
+		})
+	}
+
+	return records, nil
+}
@@ -0,0 +1,355 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by Garmin Connect requests, wrapped in an *APIError
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrNotFound     = errors.New("not found")
+)
+
+// Retry defaults for Garmin Connect requests
+const (
+	defaultMaxRetries        = 4
+	defaultRequestsPerMinute = 60
+	maxErrorBodySize         = 64 * 1024
+
+	// maxRateLimitWaits is how often Retry waits out a rate limit before giving up
+	maxRateLimitWaits = 3
+)
+
+// Delays between retries, which tests shorten
+var (
+	retryBaseDelay = 1 * time.Second
+	retryMaxDelay  = 60 * time.Second
+)
+
+// APIError represents a non-successful response from Garmin Connect
+type APIError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("status %d (retry after %s)", e.StatusCode, e.RetryAfter)
+	}
+	return fmt.Sprintf("status %d", e.StatusCode)
+}
+
+// Unwrap maps the status code to one of the typed errors
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusNotFound:
+		return ErrNotFound
+	}
+	return nil
+}
+
+// rateLimiter is a token bucket limiting the number of requests per minute
+type rateLimiter struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+// newRateLimiter creates a limiter allowing perMinute requests per minute
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		perMinute = defaultRequestsPerMinute
+	}
+	return &rateLimiter{
+		tokens:   float64(perMinute),
+		capacity: float64(perMinute),
+		rate:     float64(perMinute) / 60.0,
+		last:     time.Now(),
+	}
+}
+
+// Wait blocks until a request may be made or the context is done. The token
+// is taken up front, so waiting callers queue behind each other without
+// holding the lock while they sleep.
+func (rl *rateLimiter) Wait(ctx context.Context) error {
+	rl.mu.Lock()
+	now := time.Now()
+	rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+	if rl.tokens > rl.capacity {
+		rl.tokens = rl.capacity
+	}
+	rl.last = now
+	rl.tokens--
+	wait := time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+	rl.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		// Give the token back for the callers queued behind
+		rl.mu.Lock()
+		rl.tokens++
+		rl.mu.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// doRequest sends a request to Garmin Connect, waiting for the rate limiter and
+// retrying network errors, 429 and 5xx responses with exponential backoff.
+// Any non-2xx response that is not retried is returned as an *APIError.
+func (gc *GarminConnect) doRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
+	var lastErr error
+	for attempt := 0; attempt <= gc.maxRetries; attempt++ {
+		if attempt > 0 {
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				req.Body = body
+			} else if req.Body != nil {
+				return nil, lastErr
+			}
+		}
+
+		if err := gc.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := gc.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			if err := gc.backoff(ctx, attempt, 0); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		resp.Body.Close()
+
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       body,
+		}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return nil, apiErr
+		}
+
+		lastErr = apiErr
+		if attempt < gc.maxRetries {
+			fmt.Printf("Request to %s failed (%v), retrying...\n", req.URL.Path, apiErr)
+			if err := gc.backoff(ctx, attempt, apiErr.RetryAfter); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, lastErr
+}
+
+// backoff sleeps before the next retry, honouring Retry-After when present
+func (gc *GarminConnect) backoff(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	if attempt >= gc.maxRetries {
+		return nil
+	}
+	return sleepContext(ctx, backoffDelay(attempt, retryAfter))
+}
+
+// sleepContext sleeps for d or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoffDelay returns the delay before retry attempt+1: exponential with full
+// jitter, capped at retryMaxDelay, or retryAfter if the server asked for it
+func backoffDelay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	delay := retryBaseDelay << uint(attempt)
+	if delay <= 0 || delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// getJSON performs an authenticated GET request against a Connect API path,
+// records the response and decodes it into v
+func (gc *GarminConnect) getJSON(ctx context.Context, path string, v interface{}) error {
+	if !gc.loggedIn {
+		if err := gc.Login(ctx); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", gc.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("User-Agent", "GarminDB-Go/1.0")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("NK", "NT")
+
+	resp, err := gc.doRequest(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	endpoint, query, _ := strings.Cut(path, "?")
+	if err := gc.record(endpoint, query, body); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// unmarshalBody decodes a response body into v. An empty body, as getJSON
+// leaves for 204 No Content, has no data and leaves v unchanged, so a day
+// without readings parses like an empty response.
+func unmarshalBody(body []byte, v interface{}) error {
+	if len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
+// sendJSON performs an authenticated request with a JSON body against a
+// Connect API path, decoding the JSON response into v unless v is nil
+func (gc *GarminConnect) sendJSON(ctx context.Context, method, path string, body, v interface{}) error {
+	if !gc.loggedIn {
+		if err := gc.Login(ctx); err != nil {
+			return err
+		}
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, gc.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("User-Agent", "GarminDB-Go/1.0")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("NK", "NT")
+
+	resp, err := gc.doRequest(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if v == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// Retry runs fn, logging in again when the session has expired and waiting
+// out rate limits that survived the client's own retries. It is meant for
+// long-running jobs such as sync that wrap several client calls.
+func (gc *GarminConnect) Retry(ctx context.Context, fn func() error) error {
+	relogged := false
+	waits := 0
+	for {
+		err := fn()
+
+		var apiErr *APIError
+		switch {
+		case errors.Is(err, ErrUnauthorized) && !relogged:
+			fmt.Println("Session expired, logging in again...")
+			if err := gc.Login(ctx); err != nil {
+				return err
+			}
+			relogged = true
+		case errors.Is(err, ErrRateLimited) && errors.As(err, &apiErr) && waits < maxRateLimitWaits:
+			wait := backoffDelay(waits+defaultMaxRetries, apiErr.RetryAfter)
+			fmt.Printf("Rate limited, waiting %s...\n", wait.Round(time.Second))
+			if err := sleepContext(ctx, wait); err != nil {
+				return err
+			}
+			waits++
+		default:
+			return err
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a logged in client talking to handler, retrying up
+// to maxRetries times with millisecond backoff
+func newTestClient(t *testing.T, maxRetries int, handler http.HandlerFunc) *GarminConnect {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	baseDelay, maxDelay := retryBaseDelay, retryMaxDelay
+	retryBaseDelay, retryMaxDelay = time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() { retryBaseDelay, retryMaxDelay = baseDelay, maxDelay })
+
+	gc := NewGarminConnect("user@example.com", "secret")
+	gc.baseURL = server.URL
+	gc.loggedIn = true
+	gc.SetRequestsPerMinute(60000)
+	gc.SetMaxRetries(maxRetries)
+	return gc
+}
+
+// serverTransport sends every request, including those to the SSO host, to a
+// test server
+type serverTransport struct {
+	server *url.URL
+}
+
+func (st serverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = st.server.Scheme, st.server.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"120", 120 * time.Second, 120 * time.Second},
+		{"-5", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), 28 * time.Second, 30 * time.Second},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %s, want between %s and %s", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	if got := backoffDelay(0, 7*time.Second); got != 7*time.Second {
+		t.Errorf("backoffDelay with Retry-After = %s, want 7s", got)
+	}
+	for attempt := 0; attempt < 100; attempt++ {
+		delay := retryBaseDelay << uint(min(attempt, 62))
+		if delay <= 0 || delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+		if got := backoffDelay(attempt, 0); got < delay/2 || got > delay {
+			t.Errorf("backoffDelay(%d) = %s, want between %s and %s", attempt, got, delay/2, delay)
+		}
+	}
+}
+
+func TestRateLimiterWaitReleasesLock(t *testing.T) {
+	rl := newRateLimiter(1)
+	if err := rl.Wait(t.Context()); err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+
+	// The next token is a minute away; a caller waiting for it must not keep
+	// others from giving up
+	waiting, cancelWaiting := context.WithCancel(t.Context())
+	defer cancelWaiting()
+	go rl.Wait(waiting)
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := rl.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wait returned after %s, want as soon as the context is done", elapsed)
+	}
+}
+
+func TestDoRequestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		min, max   time.Duration
+	}{
+		{"seconds", "90", 90 * time.Second, 90 * time.Second},
+		{"HTTP date", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), 59 * time.Minute, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc := newTestClient(t, 0, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", tt.retryAfter)
+				w.WriteHeader(http.StatusTooManyRequests)
+			})
+
+			var v map[string]interface{}
+			err := gc.getJSON(t.Context(), "/stats", &v)
+			var apiErr *APIError
+			if !errors.Is(err, ErrRateLimited) || !errors.As(err, &apiErr) {
+				t.Fatalf("getJSON error = %v, want ErrRateLimited", err)
+			}
+			if apiErr.RetryAfter < tt.min || apiErr.RetryAfter > tt.max {
+				t.Errorf("RetryAfter = %s, want between %s and %s", apiErr.RetryAfter, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestDoRequestHonoursRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	gc := newTestClient(t, 1, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"steps":12000}`)
+	})
+
+	start := time.Now()
+	var v struct{ Steps int }
+	if err := gc.getJSON(t.Context(), "/stats", &v); err != nil || v.Steps != 12000 {
+		t.Fatalf("getJSON = %+v, %v; want 12000 steps", v, err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want the 1s Retry-After", elapsed)
+	}
+}
+
+func TestDoRequestRetriesServerErrors(t *testing.T) {
+	var requests atomic.Int32
+	gc := newTestClient(t, 3, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"steps":12000}`)
+	})
+
+	var v struct{ Steps int }
+	if err := gc.getJSON(t.Context(), "/stats", &v); err != nil || v.Steps != 12000 {
+		t.Fatalf("getJSON = %+v, %v; want 12000 steps", v, err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("%d requests, want 3", n)
+	}
+}
+
+func TestDoRequestGivesUp(t *testing.T) {
+	var requests atomic.Int32
+	gc := newTestClient(t, 2, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	var v map[string]interface{}
+	err := gc.getJSON(t.Context(), "/stats", &v)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("getJSON error = %v, want status 503", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("%d requests, want the first and 2 retries", n)
+	}
+}
+
+func TestDoRequestCanceledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	var requests atomic.Int32
+	gc := newTestClient(t, 3, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	var v map[string]interface{}
+	if err := gc.getJSON(ctx, "/stats", &v); !errors.Is(err, context.Canceled) {
+		t.Errorf("getJSON error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("getJSON returned after %s, want as soon as the context is canceled", elapsed)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+}
+
+func TestDoRequestNotFound(t *testing.T) {
+	var requests atomic.Int32
+	gc := newTestClient(t, 3, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	})
+
+	var v map[string]interface{}
+	if err := gc.getJSON(t.Context(), "/stats", &v); !errors.Is(err, ErrNotFound) {
+		t.Errorf("getJSON error = %v, want ErrNotFound", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests, want 1 as 404 is not retried", n)
+	}
+}
+
+func TestRetryLogsInAgain(t *testing.T) {
+	var logins, requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/sso/signin" && r.Method == http.MethodPost:
+			logins.Add(1)
+			fmt.Fprint(w, `<a href="https://connect.garmin.com/modern/?ticket=ST-1">`)
+		case r.URL.Path == "/sso/signin":
+			fmt.Fprint(w, `<input name="_csrf" value="token">`)
+		case logins.Load() == 0:
+			requests.Add(1)
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			requests.Add(1)
+			fmt.Fprint(w, `{"steps":12000}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+
+	gc := NewGarminConnect("user@example.com", "secret")
+	gc.SetHTTPClient(&http.Client{Transport: serverTransport{serverURL}})
+	gc.baseURL = server.URL
+	gc.loggedIn = true
+	gc.SetMaxRetries(0)
+
+	var v struct{ Steps int }
+	err = gc.Retry(t.Context(), func() error {
+		return gc.getJSON(t.Context(), "/stats", &v)
+	})
+	if err != nil || v.Steps != 12000 {
+		t.Fatalf("Retry = %+v, %v; want 12000 steps", v, err)
+	}
+	if n := logins.Load(); n != 1 {
+		t.Errorf("%d logins, want 1", n)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+}
+
+func TestRetryGivesUpOnRepeatedUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/sso/") {
+			fmt.Fprint(w, `ticket=ST-1`)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(server.Close)
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+
+	gc := NewGarminConnect("user@example.com", "secret")
+	gc.SetHTTPClient(&http.Client{Transport: serverTransport{serverURL}})
+	gc.baseURL = server.URL
+	gc.loggedIn = true
+	gc.SetMaxRetries(0)
+
+	calls := 0
+	err = gc.Retry(t.Context(), func() error {
+		calls++
+		var v map[string]interface{}
+		return gc.getJSON(t.Context(), "/stats", &v)
+	})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Retry error = %v, want ErrUnauthorized", err)
+	}
+	if calls != 2 {
+		t.Errorf("%d calls, want 2: one before and one after logging in again", calls)
+	}
+}
+
+func TestRetryWaitsOutRateLimit(t *testing.T) {
+	var requests atomic.Int32
+	gc := newTestClient(t, 0, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= maxRateLimitWaits {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"steps":12000}`)
+	})
+
+	var v struct{ Steps int }
+	err := gc.Retry(t.Context(), func() error {
+		return gc.getJSON(t.Context(), "/stats", &v)
+	})
+	if err != nil || v.Steps != 12000 {
+		t.Fatalf("Retry = %+v, %v; want 12000 steps", v, err)
+	}
+	if n := requests.Load(); n != maxRateLimitWaits+1 {
+		t.Errorf("%d requests, want %d", n, maxRateLimitWaits+1)
+	}
+}
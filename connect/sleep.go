@@ -0,0 +1,125 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// Sleep stages as reported in the activityLevel of sleep intervals
+var sleepStages = map[int]string{
+	0: "deep",
+	1: "light",
+	2: "rem",
+	3: "awake",
+}
+
+// GarminSleepResponse represents the daily sleep response from Garmin Connect
+type GarminSleepResponse struct {
+	DailySleepDTO GarminSleepSummary `json:"dailySleepDTO"`
+	SleepLevels   []GarminSleepLevel `json:"sleepLevels"`
+}
+
+// GarminSleepSummary represents the nightly sleep summary
+type GarminSleepSummary struct {
+	CalendarDate           string           `json:"calendarDate"`
+	SleepTimeSeconds       int              `json:"sleepTimeSeconds"`
+	SleepStartTimestampGMT int64            `json:"sleepStartTimestampGMT"`
+	SleepEndTimestampGMT   int64            `json:"sleepEndTimestampGMT"`
+	DeepSleepSeconds       int              `json:"deepSleepSeconds"`
+	LightSleepSeconds      int              `json:"lightSleepSeconds"`
+	RemSleepSeconds        int              `json:"remSleepSeconds"`
+	AwakeSleepSeconds      int              `json:"awakeSleepSeconds"`
+	SleepScores            GarminSleepScore `json:"sleepScores"`
+}
+
+// GarminSleepScore holds the overall sleep score
+type GarminSleepScore struct {
+	Overall struct {
+		Value int `json:"value"`
+	} `json:"overall"`
+}
+
+// GarminSleepLevel represents one sleep stage interval
+type GarminSleepLevel struct {
+	StartGMT      string  `json:"startGMT"`
+	EndGMT        string  `json:"endGMT"`
+	ActivityLevel float64 `json:"activityLevel"`
+}
+
+// GetSleep retrieves the sleep summary and stage intervals for the night ending on date
+func (gc *GarminConnect) GetSleep(ctx context.Context, date time.Time) (*gormin.SleepData, error) {
+	displayName, err := gc.DisplayName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dateStr := date.Format("2006-01-02")
+	path := fmt.Sprintf("/modern/proxy/wellness-service/wellness/dailySleepData/%s?date=%s&nonSleepBufferMinutes=60",
+		url.PathEscape(displayName), dateStr)
+
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, path, &body); err != nil {
+		return nil, fmt.Errorf("failed to get sleep data: %w", err)
+	}
+
+	return ParseSleep(dateStr, body)
+}
+
+// ParseSleep converts the sleep response for the night ending on a day. A
+// night without sleep returns ErrNotFound.
+func ParseSleep(dateStr string, body []byte) (*gormin.SleepData, error) {
+	var garminSleep GarminSleepResponse
+	if err := unmarshalBody(body, &garminSleep); err != nil {
+		return nil, fmt.Errorf("failed to parse sleep data: %w", err)
+	}
+
+	summary := garminSleep.DailySleepDTO
+	if summary.SleepTimeSeconds == 0 {
+		return nil, fmt.Errorf("no sleep data for %s: %w", dateStr, ErrNotFound)
+	}
+
+	// Convert to our SleepData struct
+	sleep := &gormin.SleepData{
+		Date:       dateStr,
+		StartTime:  formatTimestampMillis(summary.SleepStartTimestampGMT),
+		EndTime:    formatTimestampMillis(summary.SleepEndTimestampGMT),
+		Duration:   summary.SleepTimeSeconds,
+		DeepSleep:  summary.DeepSleepSeconds,
+		LightSleep: summary.LightSleepSeconds,
+		RemSleep:   summary.RemSleepSeconds,
+		AwakeTime:  summary.AwakeSleepSeconds,
+		SleepScore: summary.SleepScores.Overall.Value,
+	}
+
+	for _, level := range garminSleep.SleepLevels {
+		sleep.Levels = append(sleep.Levels, gormin.SleepLevel{
+			StartTime: formatGMT(level.StartGMT),
+			EndTime:   formatGMT(level.EndGMT),
+			Stage:     sleepStages[int(level.ActivityLevel)],
+		})
+	}
+
+	return sleep, nil
+}
+
+// formatTimestampMillis converts a Connect millisecond timestamp to the database time format
+func formatTimestampMillis(ms int64) string {
+	if ms == 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).UTC().Format("2006-01-02 15:04:05")
+}
+
+// formatGMT converts a Connect GMT timestamp ("2006-01-02T15:04:05.0") to the database time format
+func formatGMT(value string) string {
+	t, err := time.Parse("2006-01-02T15:04:05", value)
+	if err != nil {
+		return value
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,193 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrDuplicateActivity is returned when Connect already has the uploaded activity
+var ErrDuplicateActivity = errors.New("duplicate activity")
+
+// Connect processes uploads in the background, answering 202 Accepted until
+// the activity is created; its status is polled for at most uploadTimeout
+var (
+	uploadPollInterval = 2 * time.Second
+	uploadTimeout      = 2 * time.Minute
+)
+
+// uploadFormats lists the file types accepted by the upload service
+var uploadFormats = map[string]bool{
+	".fit": true,
+	".gpx": true,
+	".tcx": true,
+}
+
+// GarminUploadResponse represents the upload service response
+type GarminUploadResponse struct {
+	DetailedImportResult struct {
+		UploadID  int64                `json:"uploadId"`
+		Successes []GarminUploadResult `json:"successes"`
+		Failures  []GarminUploadResult `json:"failures"`
+	} `json:"detailedImportResult"`
+}
+
+// GarminUploadResult represents one imported or rejected activity
+type GarminUploadResult struct {
+	InternalID int `json:"internalId"`
+	Messages   []struct {
+		Code    int    `json:"code"`
+		Content string `json:"content"`
+	} `json:"messages"`
+}
+
+// UploadActivity uploads a FIT, GPX or TCX file and returns the id of the new
+// activity, waiting for Connect to finish processing the file if needed. If
+// Connect already has the activity, its id is returned together with
+// ErrDuplicateActivity.
+func (gc *GarminConnect) UploadActivity(ctx context.Context, path string) (int, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if !uploadFormats[ext] {
+		return 0, fmt.Errorf("unsupported file type %q: expected .fit, .gpx or .tcx", ext)
+	}
+
+	if !gc.loggedIn {
+		if err := gc.Login(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+
+	url := fmt.Sprintf("%s/modern/proxy/upload-service/upload/%s", gc.baseURL, ext)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("User-Agent", "GarminDB-Go/1.0")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("NK", "NT")
+
+	var result GarminUploadResponse
+	resp, err := gc.doRequest(ctx, req)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+			if json.Unmarshal(apiErr.Body, &result) == nil {
+				for _, failure := range result.DetailedImportResult.Failures {
+					if failure.InternalID != 0 {
+						return failure.InternalID, ErrDuplicateActivity
+					}
+				}
+			}
+			return 0, ErrDuplicateActivity
+		}
+		return 0, fmt.Errorf("failed to upload %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to parse upload response: %w", err)
+	}
+
+	activityID, done, err := result.activityID()
+	if done || err != nil {
+		return activityID, err
+	}
+	return gc.waitForUpload(ctx, result.DetailedImportResult.UploadID)
+}
+
+// activityID returns the id of the activity an upload created. done is false
+// while Connect is still processing the upload.
+func (r *GarminUploadResponse) activityID() (id int, done bool, err error) {
+	for _, success := range r.DetailedImportResult.Successes {
+		if success.InternalID != 0 {
+			return success.InternalID, true, nil
+		}
+	}
+	for _, failure := range r.DetailedImportResult.Failures {
+		for _, message := range failure.Messages {
+			return 0, true, fmt.Errorf("upload rejected: %s", message.Content)
+		}
+	}
+	return 0, false, nil
+}
+
+// waitForUpload polls the status of an upload Connect is still processing
+// until it has created the activity, for at most uploadTimeout
+func (gc *GarminConnect) waitForUpload(ctx context.Context, uploadID int64) (int, error) {
+	if uploadID == 0 {
+		return 0, fmt.Errorf("upload accepted without an upload id to check its status")
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, uploadTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/modern/proxy/upload-service/upload/%d", gc.baseURL, uploadID)
+	for {
+		if err := sleepContext(waitCtx, uploadPollInterval); err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			return 0, fmt.Errorf("upload %d still processing after %s", uploadID, uploadTimeout)
+		}
+
+		req, err := http.NewRequestWithContext(waitCtx, "GET", url, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("User-Agent", "GarminDB-Go/1.0")
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("NK", "NT")
+
+		resp, err := gc.doRequest(waitCtx, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			if waitCtx.Err() != nil {
+				return 0, fmt.Errorf("upload %d still processing after %s", uploadID, uploadTimeout)
+			}
+			return 0, fmt.Errorf("failed to get status of upload %d: %w", uploadID, err)
+		}
+		var result GarminUploadResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse status of upload %d: %w", uploadID, err)
+		}
+
+		activityID, done, err := result.activityID()
+		if done || err != nil {
+			return activityID, err
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newUploadTestClient returns a logged in client talking to handler, polling
+// upload status without delay
+func newUploadTestClient(t *testing.T, handler http.HandlerFunc) *GarminConnect {
+	t.Helper()
+	interval, timeout := uploadPollInterval, uploadTimeout
+	uploadPollInterval, uploadTimeout = time.Millisecond, time.Second
+	t.Cleanup(func() { uploadPollInterval, uploadTimeout = interval, timeout })
+
+	return newTestClient(t, 0, handler)
+}
+
+// uploadTestFile writes an activity file to upload
+func uploadTestFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "run.fit")
+	if err := os.WriteFile(path, []byte("FIT data"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+const (
+	uploadAccepted = `{"detailedImportResult":{"uploadId":77,"successes":[],"failures":[]}}`
+	uploadDone     = `{"detailedImportResult":{"uploadId":77,"successes":[{"internalId":9000000001}],"failures":[]}}`
+)
+
+func TestUploadActivity(t *testing.T) {
+	gc := newUploadTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/modern/proxy/upload-service/upload/.fit" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, uploadDone)
+	})
+
+	id, err := gc.UploadActivity(t.Context(), uploadTestFile(t))
+	if err != nil || id != 9000000001 {
+		t.Errorf("UploadActivity = %d, %v; want 9000000001", id, err)
+	}
+}
+
+func TestUploadActivityWaitsForProcessing(t *testing.T) {
+	var polls atomic.Int32
+	gc := newUploadTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, uploadAccepted)
+		case r.URL.Path == "/modern/proxy/upload-service/upload/77":
+			if polls.Add(1) < 3 {
+				w.WriteHeader(http.StatusAccepted)
+				fmt.Fprint(w, uploadAccepted)
+				return
+			}
+			fmt.Fprint(w, uploadDone)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	id, err := gc.UploadActivity(t.Context(), uploadTestFile(t))
+	if err != nil || id != 9000000001 {
+		t.Errorf("UploadActivity = %d, %v; want 9000000001", id, err)
+	}
+	if n := polls.Load(); n != 3 {
+		t.Errorf("polled %d times, want 3", n)
+	}
+}
+
+func TestUploadActivityProcessingRejected(t *testing.T) {
+	gc := newUploadTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, uploadAccepted)
+			return
+		}
+		fmt.Fprint(w, `{"detailedImportResult":{"uploadId":77,"failures":[{"messages":[{"code":202,"content":"Invalid file"}]}]}}`)
+	})
+
+	_, err := gc.UploadActivity(t.Context(), uploadTestFile(t))
+	if err == nil || !strings.Contains(err.Error(), "Invalid file") {
+		t.Errorf("UploadActivity error = %v, want the rejection", err)
+	}
+}
+
+func TestUploadActivityTimesOut(t *testing.T) {
+	gc := newUploadTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, uploadAccepted)
+	})
+	uploadTimeout = 20 * time.Millisecond
+
+	_, err := gc.UploadActivity(t.Context(), uploadTestFile(t))
+	if err == nil || !strings.Contains(err.Error(), "still processing") {
+		t.Errorf("UploadActivity error = %v, want a timeout", err)
+	}
+}
+
+func TestUploadActivityCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	gc := newUploadTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// Cancel once Connect has accepted the upload
+		cancel()
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, uploadAccepted)
+	})
+
+	_, err := gc.UploadActivity(ctx, uploadTestFile(t))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("UploadActivity error = %v, want context.Canceled", err)
+	}
+}
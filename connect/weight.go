@@ -0,0 +1,92 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// GarminWeightRange represents the weight date range response from Garmin Connect
+type GarminWeightRange struct {
+	DateWeightList []GarminWeighIn `json:"dateWeightList"`
+}
+
+// GarminWeighIn represents a single weigh-in; masses are in grams
+type GarminWeighIn struct {
+	CalendarDate string  `json:"calendarDate"`
+	TimestampGMT int64   `json:"timestampGMT"`
+	Date         int64   `json:"date"`
+	Weight       float64 `json:"weight"`
+	BodyFat      float64 `json:"bodyFat"`
+	BodyWater    float64 `json:"bodyWater"`
+	BoneMass     float64 `json:"boneMass"`
+	MuscleMass   float64 `json:"muscleMass"`
+}
+
+// GarminWeightUpload represents a manual weigh-in sent to Garmin Connect
+type GarminWeightUpload struct {
+	DateTimestamp string  `json:"dateTimestamp"`
+	GMTTimestamp  string  `json:"gmtTimestamp"`
+	UnitKey       string  `json:"unitKey"`
+	Value         float64 `json:"value"`
+}
+
+// GetWeight retrieves every weigh-in between start and end (inclusive)
+func (gc *GarminConnect) GetWeight(ctx context.Context, start, end time.Time) ([]gormin.WeightEntry, error) {
+	path := fmt.Sprintf("/modern/proxy/weight-service/weight/dateRange?startDate=%s&endDate=%s",
+		start.Format("2006-01-02"), end.Format("2006-01-02"))
+
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, path, &body); err != nil {
+		return nil, fmt.Errorf("failed to get weight: %w", err)
+	}
+
+	return ParseWeight(body)
+}
+
+// ParseWeight converts a weight date range response
+func ParseWeight(body []byte) ([]gormin.WeightEntry, error) {
+	var garminWeight GarminWeightRange
+	if err := unmarshalBody(body, &garminWeight); err != nil {
+		return nil, fmt.Errorf("failed to parse weight: %w", err)
+	}
+
+	// Convert to our WeightEntry struct
+	var entries []gormin.WeightEntry
+	for _, w := range garminWeight.DateWeightList {
+		timestamp := w.TimestampGMT
+		if timestamp == 0 {
+			timestamp = w.Date
+		}
+		entries = append(entries, gormin.WeightEntry{
+			Date:            w.CalendarDate,
+			Timestamp:       formatTimestampMillis(timestamp),
+			Weight:          w.Weight / 1000.0, // Convert grams to kg
+			BodyFat:         w.BodyFat,
+			MuscleMass:      w.MuscleMass / 1000.0,
+			BoneMass:        w.BoneMass / 1000.0,
+			WaterPercentage: w.BodyWater,
+		})
+	}
+
+	return entries, nil
+}
+
+// AddWeight uploads a manual weigh-in. Connect only accepts the weight itself
+// for manual entries; body composition comes from scales.
+func (gc *GarminConnect) AddWeight(ctx context.Context, weight float64, at time.Time) error {
+	upload := GarminWeightUpload{
+		DateTimestamp: at.Local().Format("2006-01-02T15:04:05.00"),
+		GMTTimestamp:  at.UTC().Format("2006-01-02T15:04:05.00"),
+		UnitKey:       "kg",
+		Value:         weight,
+	}
+
+	if err := gc.sendJSON(ctx, "POST", "/modern/proxy/weight-service/user-weight", upload, nil); err != nil {
+		return fmt.Errorf("failed to upload weight: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package connect
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAddWeight(t *testing.T) {
+	var upload map[string]interface{}
+	gc := newTestClient(t, 0, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/modern/proxy/weight-service/user-weight" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&upload); err != nil {
+			t.Errorf("decode upload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	at := time.Date(2024, 3, 4, 7, 30, 0, 0, time.UTC)
+	if err := gc.AddWeight(t.Context(), 72.4, at); err != nil {
+		t.Fatalf("AddWeight: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"dateTimestamp": at.Local().Format("2006-01-02T15:04:05.00"),
+		"gmtTimestamp":  "2024-03-04T07:30:00.00",
+		"unitKey":       "kg",
+		"value":         72.4,
+	}
+	if len(upload) != len(want) {
+		t.Errorf("upload = %v, want %v", upload, want)
+	}
+	for key, value := range want {
+		if upload[key] != value {
+			t.Errorf("upload %s = %v, want %v", key, upload[key], value)
+		}
+	}
+}
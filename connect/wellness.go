@@ -0,0 +1,124 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// GarminBodyBatteryDay represents one day of the Body Battery report
+type GarminBodyBatteryDay struct {
+	Date                   string      `json:"date"`
+	Charged                int         `json:"charged"`
+	Drained                int         `json:"drained"`
+	BodyBatteryValuesArray [][]float64 `json:"bodyBatteryValuesArray"`
+}
+
+// GarminStressDay represents the daily stress response
+type GarminStressDay struct {
+	CalendarDate      string      `json:"calendarDate"`
+	MaxStressLevel    int         `json:"maxStressLevel"`
+	AvgStressLevel    int         `json:"avgStressLevel"`
+	StressValuesArray [][]float64 `json:"stressValuesArray"`
+}
+
+// GarminRespirationDay represents the daily respiration response
+type GarminRespirationDay struct {
+	CalendarDate            string      `json:"calendarDate"`
+	LowestRespirationValue  float64     `json:"lowestRespirationValue"`
+	HighestRespirationValue float64     `json:"highestRespirationValue"`
+	RespirationValuesArray  [][]float64 `json:"respirationValuesArray"`
+}
+
+// GetBodyBattery retrieves the Body Battery levels for a day
+func (gc *GarminConnect) GetBodyBattery(ctx context.Context, date time.Time) ([]gormin.WellnessSample, error) {
+	dateStr := date.Format("2006-01-02")
+	path := fmt.Sprintf("/modern/proxy/wellness-service/wellness/bodyBattery/reports/daily?startDate=%s&endDate=%s",
+		dateStr, dateStr)
+
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, path, &body); err != nil {
+		return nil, fmt.Errorf("failed to get body battery: %w", err)
+	}
+
+	return ParseBodyBattery(body)
+}
+
+// ParseBodyBattery converts a Body Battery report
+func ParseBodyBattery(body []byte) ([]gormin.WellnessSample, error) {
+	var days []GarminBodyBatteryDay
+	if err := unmarshalBody(body, &days); err != nil {
+		return nil, fmt.Errorf("failed to parse body battery: %w", err)
+	}
+
+	var samples []gormin.WellnessSample
+	for _, day := range days {
+		samples = append(samples, wellnessSamples(day.BodyBatteryValuesArray)...)
+	}
+	return samples, nil
+}
+
+// GetStress retrieves all-day stress levels for a day. Negative values, which
+// mark periods without a reading, are dropped.
+func (gc *GarminConnect) GetStress(ctx context.Context, date time.Time) ([]gormin.WellnessSample, error) {
+	path := fmt.Sprintf("/modern/proxy/wellness-service/wellness/dailyStress/%s", date.Format("2006-01-02"))
+
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, path, &body); err != nil {
+		return nil, fmt.Errorf("failed to get stress: %w", err)
+	}
+
+	return ParseStress(body)
+}
+
+// ParseStress converts a daily stress response
+func ParseStress(body []byte) ([]gormin.WellnessSample, error) {
+	var day GarminStressDay
+	if err := unmarshalBody(body, &day); err != nil {
+		return nil, fmt.Errorf("failed to parse stress: %w", err)
+	}
+
+	return wellnessSamples(day.StressValuesArray), nil
+}
+
+// GetRespiration retrieves respiration rate (breaths per minute) for a day
+func (gc *GarminConnect) GetRespiration(ctx context.Context, date time.Time) ([]gormin.WellnessSample, error) {
+	path := fmt.Sprintf("/modern/proxy/wellness-service/wellness/daily/respiration/%s", date.Format("2006-01-02"))
+
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, path, &body); err != nil {
+		return nil, fmt.Errorf("failed to get respiration: %w", err)
+	}
+
+	return ParseRespiration(body)
+}
+
+// ParseRespiration converts a daily respiration response
+func ParseRespiration(body []byte) ([]gormin.WellnessSample, error) {
+	var day GarminRespirationDay
+	if err := unmarshalBody(body, &day); err != nil {
+		return nil, fmt.Errorf("failed to parse respiration: %w", err)
+	}
+
+	return wellnessSamples(day.RespirationValuesArray), nil
+}
+
+// wellnessSamples converts [timestamp in ms, value] pairs into samples,
+// skipping missing and negative values
+func wellnessSamples(values [][]float64) []gormin.WellnessSample {
+	var samples []gormin.WellnessSample
+	for _, value := range values {
+		if len(value) < 2 || value[0] <= 0 || value[1] < 0 {
+			continue
+		}
+		samples = append(samples, gormin.WellnessSample{
+			Timestamp: formatTimestampMillis(int64(value[0])),
+			Value:     value[1],
+			Source:    gormin.SourceConnect,
+		})
+	}
+	return samples
+}
@@ -0,0 +1,326 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// Connect workout step types, end conditions, targets and sports as
+// (id, key) pairs, indexed by the names used in workout definitions
+var (
+	workoutStepTypes = map[string]garminWorkoutKey{
+		"warmup":   {1, "warmup"},
+		"cooldown": {2, "cooldown"},
+		"interval": {3, "interval"},
+		"recovery": {4, "recovery"},
+		"rest":     {5, "rest"},
+		"repeat":   {6, "repeat"},
+		"other":    {7, "other"},
+	}
+	workoutConditions = map[string]garminWorkoutKey{
+		"lap_button": {1, "lap.button"},
+		"time":       {2, "time"},
+		"distance":   {3, "distance"},
+		"iterations": {7, "iterations"},
+	}
+	workoutTargets = map[string]garminWorkoutKey{
+		"none":            {1, "no.target"},
+		"power":           {2, "power.zone"},
+		"power_zone":      {2, "power.zone"},
+		"cadence":         {3, "cadence"},
+		"heart_rate":      {4, "heart.rate.zone"},
+		"heart_rate_zone": {4, "heart.rate.zone"},
+		"speed":           {6, "pace.zone"},
+	}
+	// workoutTargetNames maps Connect target keys to the definition names for
+	// custom ranges and for zone numbers
+	workoutTargetNames = map[string][2]string{
+		"heart.rate.zone": {"heart_rate", "heart_rate_zone"},
+		"power.zone":      {"power", "power_zone"},
+		"cadence":         {"cadence", "cadence"},
+		"pace.zone":       {"speed", "speed"},
+		"speed.zone":      {"speed", "speed"},
+	}
+	workoutSports = map[string]garminWorkoutKey{
+		"running":           {1, "running"},
+		"cycling":           {2, "cycling"},
+		"other":             {3, "other"},
+		"swimming":          {4, "swimming"},
+		"strength_training": {5, "strength_training"},
+		"cardio_training":   {6, "cardio_training"},
+	}
+)
+
+// garminWorkoutKey is an id and key pair used throughout the workout service
+type garminWorkoutKey struct {
+	ID  int
+	Key string
+}
+
+// GarminWorkout represents a workout in the Connect workout service
+type GarminWorkout struct {
+	WorkoutID       int                    `json:"workoutId,omitempty"`
+	WorkoutName     string                 `json:"workoutName"`
+	Description     string                 `json:"description,omitempty"`
+	UpdatedDate     string                 `json:"updatedDate,omitempty"`
+	SportType       GarminSportType        `json:"sportType"`
+	WorkoutSegments []GarminWorkoutSegment `json:"workoutSegments,omitempty"`
+}
+
+// GarminSportType identifies the sport of a workout or segment
+type GarminSportType struct {
+	SportTypeID  int    `json:"sportTypeId"`
+	SportTypeKey string `json:"sportTypeKey"`
+}
+
+// GarminWorkoutSegment groups the steps of a workout
+type GarminWorkoutSegment struct {
+	SegmentOrder int                 `json:"segmentOrder"`
+	SportType    GarminSportType     `json:"sportType"`
+	WorkoutSteps []GarminWorkoutStep `json:"workoutSteps"`
+}
+
+// GarminWorkoutStep is either an executable step or a repeat group
+type GarminWorkoutStep struct {
+	Type      string `json:"type"`
+	StepOrder int    `json:"stepOrder"`
+	StepType  struct {
+		StepTypeID  int    `json:"stepTypeId"`
+		StepTypeKey string `json:"stepTypeKey"`
+	} `json:"stepType"`
+	Description  string `json:"description,omitempty"`
+	EndCondition struct {
+		ConditionTypeID  int    `json:"conditionTypeId"`
+		ConditionTypeKey string `json:"conditionTypeKey"`
+	} `json:"endCondition"`
+	EndConditionValue  float64              `json:"endConditionValue,omitempty"`
+	TargetType         *GarminWorkoutTarget `json:"targetType,omitempty"`
+	TargetValueOne     float64              `json:"targetValueOne,omitempty"`
+	TargetValueTwo     float64              `json:"targetValueTwo,omitempty"`
+	ZoneNumber         int                  `json:"zoneNumber,omitempty"`
+	NumberOfIterations int                  `json:"numberOfIterations,omitempty"`
+	WorkoutSteps       []GarminWorkoutStep  `json:"workoutSteps,omitempty"`
+}
+
+// GarminWorkoutTarget identifies the target of a workout step
+type GarminWorkoutTarget struct {
+	WorkoutTargetTypeID  int    `json:"workoutTargetTypeId"`
+	WorkoutTargetTypeKey string `json:"workoutTargetTypeKey"`
+}
+
+// GetWorkouts retrieves the workout library of the logged in user, including all steps
+func (gc *GarminConnect) GetWorkouts(ctx context.Context) ([]gormin.Workout, error) {
+	var list []GarminWorkout
+	if err := gc.getJSON(ctx, "/modern/proxy/workout-service/workouts?start=1&limit=999", &list); err != nil {
+		return nil, fmt.Errorf("failed to get workouts: %w", err)
+	}
+
+	var workouts []gormin.Workout
+	for _, w := range list {
+		workout, err := gc.GetWorkout(ctx, w.WorkoutID)
+		if err != nil {
+			return nil, err
+		}
+		workouts = append(workouts, *workout)
+	}
+
+	return workouts, nil
+}
+
+// GetWorkout retrieves a single workout with its steps
+func (gc *GarminConnect) GetWorkout(ctx context.Context, workoutID int) (*gormin.Workout, error) {
+	path := fmt.Sprintf("/modern/proxy/workout-service/workout/%d", workoutID)
+
+	var body json.RawMessage
+	if err := gc.getJSON(ctx, path, &body); err != nil {
+		return nil, fmt.Errorf("failed to get workout %d: %w", workoutID, err)
+	}
+
+	return ParseWorkout(body)
+}
+
+// ParseWorkout converts a workout response
+func ParseWorkout(body []byte) (*gormin.Workout, error) {
+	var garminWorkout GarminWorkout
+	if err := unmarshalBody(body, &garminWorkout); err != nil {
+		return nil, fmt.Errorf("failed to parse workout: %w", err)
+	}
+
+	return workoutFromGarmin(&garminWorkout), nil
+}
+
+// CreateWorkout uploads a workout as a new workout in the library and returns its id
+func (gc *GarminConnect) CreateWorkout(ctx context.Context, workout *gormin.Workout) (int, error) {
+	garminWorkout, err := workoutToGarmin(workout)
+	if err != nil {
+		return 0, err
+	}
+
+	var created GarminWorkout
+	if err := gc.sendJSON(ctx, "POST", "/modern/proxy/workout-service/workout", garminWorkout, &created); err != nil {
+		return 0, fmt.Errorf("failed to create workout: %w", err)
+	}
+
+	return created.WorkoutID, nil
+}
+
+// workoutFromGarmin converts a Connect workout to our Workout struct
+func workoutFromGarmin(gw *GarminWorkout) *gormin.Workout {
+	workout := &gormin.Workout{
+		ID:          gw.WorkoutID,
+		Name:        gw.WorkoutName,
+		Sport:       gw.SportType.SportTypeKey,
+		Description: gw.Description,
+	}
+	for _, segment := range gw.WorkoutSegments {
+		workout.Steps = append(workout.Steps, workoutStepsFromGarmin(segment.WorkoutSteps)...)
+	}
+	return workout
+}
+
+// workoutStepsFromGarmin converts Connect steps, recursing into repeat groups
+func workoutStepsFromGarmin(steps []GarminWorkoutStep) []gormin.WorkoutStep {
+	var result []gormin.WorkoutStep
+	for _, gs := range steps {
+		if gs.Type == "RepeatGroupDTO" {
+			result = append(result, gormin.WorkoutStep{
+				Type:   "repeat",
+				Repeat: gs.NumberOfIterations,
+				Steps:  workoutStepsFromGarmin(gs.WorkoutSteps),
+			})
+			continue
+		}
+
+		step := gormin.WorkoutStep{
+			Type:          workoutKeyName(workoutStepTypes, gs.StepType.StepTypeKey),
+			DurationType:  workoutKeyName(workoutConditions, gs.EndCondition.ConditionTypeKey),
+			DurationValue: gs.EndConditionValue,
+			Description:   gs.Description,
+		}
+		if gs.TargetType != nil && gs.TargetType.WorkoutTargetTypeKey != "no.target" {
+			names, ok := workoutTargetNames[gs.TargetType.WorkoutTargetTypeKey]
+			if !ok {
+				names = [2]string{gs.TargetType.WorkoutTargetTypeKey, gs.TargetType.WorkoutTargetTypeKey}
+			}
+			if gs.ZoneNumber > 0 {
+				step.TargetType = names[1]
+				step.Zone = gs.ZoneNumber
+			} else {
+				step.TargetType = names[0]
+				step.TargetLow = gs.TargetValueOne
+				step.TargetHigh = gs.TargetValueTwo
+			}
+		}
+		result = append(result, step)
+	}
+	return result
+}
+
+// workoutKeyName returns the definition name for a Connect key, or the key itself
+func workoutKeyName(keys map[string]garminWorkoutKey, key string) string {
+	for name, k := range keys {
+		if k.Key == key {
+			return name
+		}
+	}
+	return key
+}
+
+// workoutToGarmin converts a workout definition to a Connect workout
+func workoutToGarmin(workout *gormin.Workout) (*GarminWorkout, error) {
+	sport, ok := workoutSports[workout.Sport]
+	if !ok {
+		return nil, fmt.Errorf("unsupported workout sport %q", workout.Sport)
+	}
+	if workout.Name == "" {
+		return nil, fmt.Errorf("workout has no name")
+	}
+	if len(workout.Steps) == 0 {
+		return nil, fmt.Errorf("workout %q has no steps", workout.Name)
+	}
+
+	sportType := GarminSportType{SportTypeID: sport.ID, SportTypeKey: sport.Key}
+	order := 0
+	steps, err := workoutStepsToGarmin(workout.Steps, &order)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GarminWorkout{
+		WorkoutName: workout.Name,
+		Description: workout.Description,
+		SportType:   sportType,
+		WorkoutSegments: []GarminWorkoutSegment{{
+			SegmentOrder: 1,
+			SportType:    sportType,
+			WorkoutSteps: steps,
+		}},
+	}, nil
+}
+
+// workoutStepsToGarmin converts workout steps, numbering them in execution order
+func workoutStepsToGarmin(steps []gormin.WorkoutStep, order *int) ([]GarminWorkoutStep, error) {
+	var result []GarminWorkoutStep
+	for _, step := range steps {
+		*order++
+		var gs GarminWorkoutStep
+		gs.StepOrder = *order
+		gs.Description = step.Description
+
+		if step.Type == "repeat" {
+			if step.Repeat < 1 || len(step.Steps) == 0 {
+				return nil, fmt.Errorf("repeat step %d needs a repeat count and steps", *order)
+			}
+			children, err := workoutStepsToGarmin(step.Steps, order)
+			if err != nil {
+				return nil, err
+			}
+			gs.Type = "RepeatGroupDTO"
+			gs.StepType.StepTypeID, gs.StepType.StepTypeKey = workoutStepTypes["repeat"].ID, "repeat"
+			gs.EndCondition.ConditionTypeID, gs.EndCondition.ConditionTypeKey = workoutConditions["iterations"].ID, "iterations"
+			gs.EndConditionValue = float64(step.Repeat)
+			gs.NumberOfIterations = step.Repeat
+			gs.WorkoutSteps = children
+			result = append(result, gs)
+			continue
+		}
+
+		stepType, ok := workoutStepTypes[step.Type]
+		if !ok {
+			return nil, fmt.Errorf("unsupported step type %q", step.Type)
+		}
+		durationType := step.DurationType
+		if durationType == "" {
+			durationType = "lap_button"
+		}
+		condition, ok := workoutConditions[durationType]
+		if !ok || durationType == "iterations" {
+			return nil, fmt.Errorf("unsupported duration type %q", step.DurationType)
+		}
+		targetType := step.TargetType
+		if targetType == "" {
+			targetType = "none"
+		}
+		target, ok := workoutTargets[targetType]
+		if !ok {
+			return nil, fmt.Errorf("unsupported target type %q", step.TargetType)
+		}
+
+		gs.Type = "ExecutableStepDTO"
+		gs.StepType.StepTypeID, gs.StepType.StepTypeKey = stepType.ID, stepType.Key
+		gs.EndCondition.ConditionTypeID, gs.EndCondition.ConditionTypeKey = condition.ID, condition.Key
+		gs.EndConditionValue = step.DurationValue
+		gs.TargetType = &GarminWorkoutTarget{WorkoutTargetTypeID: target.ID, WorkoutTargetTypeKey: target.Key}
+		if step.Zone > 0 {
+			gs.ZoneNumber = step.Zone
+		} else {
+			gs.TargetValueOne = step.TargetLow
+			gs.TargetValueTwo = step.TargetHigh
+		}
+		result = append(result, gs)
+	}
+	return result, nil
+}
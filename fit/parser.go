@@ -0,0 +1,811 @@
+// Package fit decodes Garmin FIT files into activities, heart rate samples,
+// devices and courses, and encodes workouts as FIT files.
+package fit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// FIT file constants
+const (
+	FIT_HEADER_SIZE = 12
+	FIT_CRC_SIZE    = 2
+
+	// FIT_EPOCH_OFFSET is the number of seconds between the Unix epoch and
+	// the FIT epoch (1989-12-31 00:00:00 UTC)
+	FIT_EPOCH_OFFSET = 631065600
+)
+
+// FIT global message numbers
+const (
+	FIT_MESG_FILE_ID     = 0
+	FIT_MESG_SESSION     = 18
+	FIT_MESG_LAP         = 19
+	FIT_MESG_RECORD      = 20
+	FIT_MESG_DEVICE_INFO = 23
+	FIT_MESG_COURSE      = 31
+)
+
+// FIT field numbers shared by all messages
+const (
+	FIT_FIELD_TIMESTAMP = 253
+)
+
+// FIT record message fields
+const (
+	FIT_RECORD_POSITION_LAT      = 0
+	FIT_RECORD_POSITION_LONG     = 1
+	FIT_RECORD_ALTITUDE          = 2
+	FIT_RECORD_HEART_RATE        = 3
+	FIT_RECORD_CADENCE           = 4
+	FIT_RECORD_DISTANCE          = 5
+	FIT_RECORD_SPEED             = 6
+	FIT_RECORD_POWER             = 7
+	FIT_RECORD_ENHANCED_SPEED    = 73
+	FIT_RECORD_ENHANCED_ALTITUDE = 78
+)
+
+// FIT course message fields
+const (
+	FIT_COURSE_SPORT = 4
+	FIT_COURSE_NAME  = 5
+)
+
+// FIT file types in the file_id message
+const (
+	FIT_FILE_ACTIVITY = 4
+	FIT_FILE_WORKOUT  = 5
+	FIT_FILE_COURSE   = 6
+)
+
+// FIT file_id message fields
+const (
+	FIT_FILE_ID_TYPE          = 0
+	FIT_FILE_ID_MANUFACTURER  = 1
+	FIT_FILE_ID_PRODUCT       = 2
+	FIT_FILE_ID_SERIAL_NUMBER = 3
+)
+
+// FIT device_info message fields
+const (
+	FIT_DEVICE_INFO_DEVICE_INDEX     = 0
+	FIT_DEVICE_INFO_DEVICE_TYPE      = 1
+	FIT_DEVICE_INFO_MANUFACTURER     = 2
+	FIT_DEVICE_INFO_SERIAL_NUMBER    = 3
+	FIT_DEVICE_INFO_PRODUCT          = 4
+	FIT_DEVICE_INFO_SOFTWARE_VERSION = 5
+	FIT_DEVICE_INFO_HARDWARE_VERSION = 6
+	FIT_DEVICE_INFO_BATTERY_STATUS   = 11
+	FIT_DEVICE_INFO_PRODUCT_NAME     = 27
+)
+
+// FIT session message fields
+const (
+	FIT_SESSION_START_TIME         = 2
+	FIT_SESSION_SPORT              = 5
+	FIT_SESSION_TOTAL_ELAPSED_TIME = 7
+	FIT_SESSION_TOTAL_DISTANCE     = 9
+	FIT_SESSION_TOTAL_CALORIES     = 11
+	FIT_SESSION_AVG_HEART_RATE     = 16
+	FIT_SESSION_MAX_HEART_RATE     = 17
+	FIT_SESSION_TOTAL_ASCENT       = 22
+)
+
+// fitSports maps FIT sport enum values to activity types
+var fitSports = map[uint8]string{
+	0:  "generic",
+	1:  "running",
+	2:  "cycling",
+	4:  "fitness_equipment",
+	5:  "swimming",
+	10: "training",
+	11: "walking",
+	15: "rowing",
+	17: "hiking",
+}
+
+// fitManufacturers maps FIT manufacturer ids to names
+var fitManufacturers = map[uint16]string{
+	1:   "garmin",
+	15:  "dynastream",
+	23:  "suunto",
+	32:  "wahoo_fitness",
+	69:  "stages_cycling",
+	89:  "tacx",
+	123: "polar",
+	255: "development",
+	260: "zwift",
+}
+
+// fitDeviceTypes maps ANT+ device types to names
+var fitDeviceTypes = map[uint8]string{
+	11:  "bike_power",
+	120: "heart_rate",
+	121: "bike_speed_cadence",
+	122: "bike_cadence",
+	123: "bike_speed",
+	124: "stride_speed_distance",
+}
+
+// fitBatteryStatus maps FIT battery status values to names
+var fitBatteryStatus = map[uint8]string{
+	1: "new",
+	2: "good",
+	3: "ok",
+	4: "low",
+	5: "critical",
+	6: "charging",
+	7: "unknown",
+}
+
+// FitHeader represents the FIT file header
+type FitHeader struct {
+	HeaderSize      uint8
+	ProtocolVersion uint8
+	ProfileVersion  uint16
+	DataSize        uint32
+	DataType        [4]byte
+	CRC             uint16
+}
+
+// FitRecord represents a FIT data record
+type FitRecord struct {
+	Header     uint8
+	MessageNum uint16
+	Fields     map[uint8]interface{}
+	Timestamp  time.Time
+}
+
+// fitFieldDef describes one field of a FIT definition message
+type fitFieldDef struct {
+	Num      uint8
+	Size     uint8
+	BaseType uint8
+}
+
+// fitDefinition describes the layout of data messages for a local message type
+type fitDefinition struct {
+	MessageNum   uint16
+	ByteOrder    binary.ByteOrder
+	Fields       []fitFieldDef
+	DevFieldSize int
+}
+
+// FitParser handles FIT file parsing. Create one with NewFitParser, read the
+// messages with ParseRecords or the activity summary with ParseToActivity,
+// and Close it when done.
+type FitParser struct {
+	file   *os.File
+	header FitHeader
+}
+
+// NewFitParser creates a new FIT parser
+func NewFitParser(filename string) (*FitParser, error) {
+	fmt.Printf("[NewFitParser] Opening file: %s\n", filename)
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("[NewFitParser] Failed to open file: %w", err)
+	}
+
+	parser := &FitParser{file: file}
+	if err := parser.parseHeader(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("[NewFitParser] Failed to parse header: %w", err)
+	}
+
+	fmt.Printf("[NewFitParser] Header parsed successfully: %+v\n", parser.header)
+	return parser, nil
+}
+
+// parseHeader parses the FIT file header
+func (fp *FitParser) parseHeader() error {
+	headerBytes := make([]byte, FIT_HEADER_SIZE)
+	if _, err := fp.file.Read(headerBytes); err != nil {
+		return err
+	}
+
+	buf := bytes.NewReader(headerBytes)
+
+	if err := binary.Read(buf, binary.LittleEndian, &fp.header.HeaderSize); err != nil {
+		return err
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &fp.header.ProtocolVersion); err != nil {
+		return err
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &fp.header.ProfileVersion); err != nil {
+		return err
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &fp.header.DataSize); err != nil {
+		return err
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &fp.header.DataType); err != nil {
+		return err
+	}
+
+	// Verify data type is ".FIT"
+	if string(fp.header.DataType[:]) != ".FIT" {
+		return fmt.Errorf("invalid FIT file: expected .FIT, got %s", string(fp.header.DataType[:]))
+	}
+
+	return nil
+}
+
+// ParseRecords parses all data records from the FIT file
+func (fp *FitParser) ParseRecords() ([]FitRecord, error) {
+	fmt.Println("[ParseRecords] Starting record parsing")
+
+	var records []FitRecord
+
+	// Skip to data section
+	if _, err := fp.file.Seek(int64(fp.header.HeaderSize), 0); err != nil {
+		return nil, fmt.Errorf("[ParseRecords] Seek error: %w", err)
+	}
+
+	// Read data section
+	dataBytes := make([]byte, fp.header.DataSize)
+	if _, err := io.ReadFull(fp.file, dataBytes); err != nil {
+		return nil, fmt.Errorf("[ParseRecords] Read error: %w", err)
+	}
+
+	definitions := make(map[uint8]*fitDefinition)
+	var lastTimestamp uint32
+
+	offset := 0
+	for offset < len(dataBytes) {
+		header := dataBytes[offset]
+		offset++
+
+		var localType uint8
+		timeOffset := -1
+
+		switch {
+		case header&0x80 != 0: // Compressed timestamp header
+			localType = (header >> 5) & 0x03
+			timeOffset = int(header & 0x1F)
+		case header&0x40 != 0: // Definition message
+			def, n, err := parseDefinition(dataBytes[offset:], header&0x20 != 0)
+			if err != nil {
+				return nil, fmt.Errorf("[ParseRecords] Definition at offset %d: %w", offset, err)
+			}
+			definitions[header&0x0F] = def
+			offset += n
+			continue
+		default: // Normal data message
+			localType = header & 0x0F
+		}
+
+		def, ok := definitions[localType]
+		if !ok {
+			return nil, fmt.Errorf("[ParseRecords] Data message for undefined local type %d at offset %d", localType, offset)
+		}
+
+		record := FitRecord{
+			Header:     header,
+			MessageNum: def.MessageNum,
+			Fields:     make(map[uint8]interface{}),
+		}
+
+		for _, field := range def.Fields {
+			if offset+int(field.Size) > len(dataBytes) {
+				return nil, fmt.Errorf("[ParseRecords] Truncated message at offset %d", offset)
+			}
+			if value, ok := decodeFitValue(dataBytes[offset:offset+int(field.Size)], field.BaseType, def.ByteOrder); ok {
+				record.Fields[field.Num] = value
+			}
+			offset += int(field.Size)
+		}
+		offset += def.DevFieldSize
+
+		// Resolve the record timestamp, expanding compressed timestamps
+		// relative to the last full timestamp seen
+		if ts, ok := record.Fields[FIT_FIELD_TIMESTAMP].(uint32); ok {
+			lastTimestamp = ts
+		} else if timeOffset >= 0 {
+			ts := (lastTimestamp &^ 0x1F) + uint32(timeOffset)
+			if uint32(timeOffset) < lastTimestamp&0x1F {
+				ts += 0x20
+			}
+			lastTimestamp = ts
+		}
+		if lastTimestamp != 0 {
+			record.Timestamp = fitTime(lastTimestamp)
+		}
+
+		records = append(records, record)
+	}
+
+	fmt.Printf("[ParseRecords] Parsed %d records\n", len(records))
+	return records, nil
+}
+
+// parseDefinition parses a definition message, returning it and its length in bytes
+func parseDefinition(data []byte, hasDevFields bool) (*fitDefinition, int, error) {
+	if len(data) < 5 {
+		return nil, 0, fmt.Errorf("truncated definition")
+	}
+
+	def := &fitDefinition{ByteOrder: binary.LittleEndian}
+	if data[1] == 1 {
+		def.ByteOrder = binary.BigEndian
+	}
+	def.MessageNum = def.ByteOrder.Uint16(data[2:4])
+
+	numFields := int(data[4])
+	offset := 5
+	if len(data) < offset+numFields*3 {
+		return nil, 0, fmt.Errorf("truncated field definitions")
+	}
+	for i := 0; i < numFields; i++ {
+		def.Fields = append(def.Fields, fitFieldDef{
+			Num:      data[offset],
+			Size:     data[offset+1],
+			BaseType: data[offset+2],
+		})
+		offset += 3
+	}
+
+	if hasDevFields {
+		if len(data) < offset+1 {
+			return nil, 0, fmt.Errorf("truncated developer field definitions")
+		}
+		numDevFields := int(data[offset])
+		offset++
+		if len(data) < offset+numDevFields*3 {
+			return nil, 0, fmt.Errorf("truncated developer field definitions")
+		}
+		for i := 0; i < numDevFields; i++ {
+			def.DevFieldSize += int(data[offset+1])
+			offset += 3
+		}
+	}
+
+	return def, offset, nil
+}
+
+// decodeFitValue decodes a single field value, reporting false for values
+// marked invalid by the FIT protocol and for arrays, which are not decoded
+func decodeFitValue(data []byte, baseType uint8, order binary.ByteOrder) (interface{}, bool) {
+	switch baseType & 0x1F {
+	case 0x07: // string
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			data = data[:i]
+		}
+		return string(data), len(data) > 0
+	case 0x00, 0x02, 0x0A, 0x0D: // enum, uint8, uint8z, byte
+		if len(data) != 1 {
+			return nil, false
+		}
+		v := data[0]
+		return v, v != 0xFF && !(baseType&0x1F == 0x0A && v == 0)
+	case 0x01: // sint8
+		if len(data) != 1 {
+			return nil, false
+		}
+		return int8(data[0]), data[0] != 0x7F
+	case 0x03: // sint16
+		if len(data) != 2 {
+			return nil, false
+		}
+		v := order.Uint16(data)
+		return int16(v), v != 0x7FFF
+	case 0x04, 0x0B: // uint16, uint16z
+		if len(data) != 2 {
+			return nil, false
+		}
+		v := order.Uint16(data)
+		return v, v != 0xFFFF && !(baseType&0x1F == 0x0B && v == 0)
+	case 0x05: // sint32
+		if len(data) != 4 {
+			return nil, false
+		}
+		v := order.Uint32(data)
+		return int32(v), v != 0x7FFFFFFF
+	case 0x06, 0x0C: // uint32, uint32z
+		if len(data) != 4 {
+			return nil, false
+		}
+		v := order.Uint32(data)
+		return v, v != 0xFFFFFFFF && !(baseType&0x1F == 0x0C && v == 0)
+	case 0x08: // float32
+		if len(data) != 4 {
+			return nil, false
+		}
+		v := order.Uint32(data)
+		return math.Float32frombits(v), v != 0xFFFFFFFF
+	case 0x09: // float64
+		if len(data) != 8 {
+			return nil, false
+		}
+		v := order.Uint64(data)
+		return math.Float64frombits(v), v != 0xFFFFFFFFFFFFFFFF
+	case 0x0E: // sint64
+		if len(data) != 8 {
+			return nil, false
+		}
+		v := order.Uint64(data)
+		return int64(v), v != 0x7FFFFFFFFFFFFFFF
+	case 0x0F, 0x10: // uint64, uint64z
+		if len(data) != 8 {
+			return nil, false
+		}
+		v := order.Uint64(data)
+		return v, v != 0xFFFFFFFFFFFFFFFF && !(baseType&0x1F == 0x10 && v == 0)
+	}
+	return nil, false
+}
+
+// fitTime converts a FIT timestamp to time.Time
+func fitTime(ts uint32) time.Time {
+	return time.Unix(int64(ts)+FIT_EPOCH_OFFSET, 0).UTC()
+}
+
+// Float returns a numeric field as float64
+func (r FitRecord) Float(field uint8) (float64, bool) {
+	switch v := r.Fields[field].(type) {
+	case uint8:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// String returns a string field
+func (r FitRecord) String(field uint8) (string, bool) {
+	v, ok := r.Fields[field].(string)
+	return v, ok
+}
+
+// ParseToActivity converts FIT records to Activity struct
+func (fp *FitParser) ParseToActivity() (*gormin.Activity, error) {
+	records, err := fp.ParseRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	return ActivityFromRecords(records), nil
+}
+
+// ActivityFromRecords builds an activity from the session message of a FIT
+// file, falling back to totals computed from record messages
+func ActivityFromRecords(records []FitRecord) *gormin.Activity {
+	activity := &gormin.Activity{
+		Name: "FIT Activity",
+		Type: "unknown",
+	}
+
+	var first, last time.Time
+	var maxDistance float64
+	var maxHR, hrSum, hrCount int
+	var session *FitRecord
+
+	for i, record := range records {
+		switch record.MessageNum {
+		case FIT_MESG_SESSION:
+			if session == nil {
+				session = &records[i]
+			}
+		case FIT_MESG_RECORD:
+			if !record.Timestamp.IsZero() {
+				if first.IsZero() {
+					first = record.Timestamp
+				}
+				last = record.Timestamp
+			}
+			if d, ok := record.Float(FIT_RECORD_DISTANCE); ok && d/100.0 > maxDistance {
+				maxDistance = d / 100.0
+			}
+			if hr, ok := record.Float(FIT_RECORD_HEART_RATE); ok {
+				if int(hr) > maxHR {
+					maxHR = int(hr)
+				}
+				hrSum += int(hr)
+				hrCount++
+			}
+		}
+	}
+
+	// Totals computed from record messages
+	if !first.IsZero() {
+		activity.StartTime = first.Local().Format("2006-01-02 15:04:05")
+		activity.Duration = int(last.Sub(first).Seconds())
+	}
+	activity.Distance = maxDistance / 1000.0
+	activity.MaxHR = maxHR
+	if hrCount > 0 {
+		activity.AvgHR = hrSum / hrCount
+	}
+
+	// Prefer the device-computed session summary when present
+	if session != nil {
+		if v, ok := session.Float(FIT_SESSION_START_TIME); ok {
+			activity.StartTime = fitTime(uint32(v)).Local().Format("2006-01-02 15:04:05")
+		}
+		if v, ok := session.Fields[FIT_SESSION_SPORT].(uint8); ok {
+			if sport, ok := fitSports[v]; ok {
+				activity.Type = sport
+			}
+		}
+		if v, ok := session.Float(FIT_SESSION_TOTAL_ELAPSED_TIME); ok {
+			activity.Duration = int(v / 1000.0)
+		}
+		if v, ok := session.Float(FIT_SESSION_TOTAL_DISTANCE); ok {
+			activity.Distance = v / 100.0 / 1000.0
+		}
+		if v, ok := session.Float(FIT_SESSION_TOTAL_CALORIES); ok {
+			activity.Calories = int(v)
+		}
+		if v, ok := session.Float(FIT_SESSION_AVG_HEART_RATE); ok {
+			activity.AvgHR = int(v)
+		}
+		if v, ok := session.Float(FIT_SESSION_MAX_HEART_RATE); ok {
+			activity.MaxHR = int(v)
+		}
+		if v, ok := session.Float(FIT_SESSION_TOTAL_ASCENT); ok {
+			activity.ElevationGain = int(v)
+		}
+	}
+
+	if activity.StartTime == "" {
+		activity.StartTime = time.Now().Format("2006-01-02 15:04:05")
+	}
+	if activity.Type != "unknown" {
+		activity.Name = fmt.Sprintf("FIT %s", activity.Type)
+	}
+
+	return activity
+}
+
+// DevicesFromRecords extracts the devices described by device_info messages,
+// keeping the latest message for each device index. The creator device also
+// takes its identity from the file_id message when device_info lacks it.
+func DevicesFromRecords(records []FitRecord) []gormin.Device {
+	var creator gormin.Device
+	devices := make(map[uint8]*gormin.Device)
+	var order []uint8
+
+	for _, record := range records {
+		switch record.MessageNum {
+		case FIT_MESG_FILE_ID:
+			if v, ok := record.Float(FIT_FILE_ID_MANUFACTURER); ok {
+				creator.Manufacturer = fitManufacturerName(uint16(v))
+			}
+			if v, ok := record.Float(FIT_FILE_ID_PRODUCT); ok {
+				creator.Product = fmt.Sprintf("%d", int(v))
+			}
+			if v, ok := record.Float(FIT_FILE_ID_SERIAL_NUMBER); ok {
+				creator.SerialNumber = fmt.Sprintf("%d", uint32(v))
+			}
+		case FIT_MESG_DEVICE_INFO:
+			index := uint8(0)
+			if v, ok := record.Fields[FIT_DEVICE_INFO_DEVICE_INDEX].(uint8); ok {
+				index = v
+			}
+			device, ok := devices[index]
+			if !ok {
+				device = &gormin.Device{Source: gormin.SourceFit}
+				devices[index] = device
+				order = append(order, index)
+			}
+
+			if index == 0 {
+				device.DeviceType = "creator"
+			} else if v, ok := record.Fields[FIT_DEVICE_INFO_DEVICE_TYPE].(uint8); ok {
+				if name, ok := fitDeviceTypes[v]; ok {
+					device.DeviceType = name
+				} else {
+					device.DeviceType = fmt.Sprintf("type_%d", v)
+				}
+			}
+			if v, ok := record.Float(FIT_DEVICE_INFO_MANUFACTURER); ok {
+				device.Manufacturer = fitManufacturerName(uint16(v))
+			}
+			if v, ok := record.Float(FIT_DEVICE_INFO_PRODUCT); ok {
+				device.Product = fmt.Sprintf("%d", int(v))
+			}
+			if v, ok := record.String(FIT_DEVICE_INFO_PRODUCT_NAME); ok {
+				device.ProductName = v
+			}
+			if v, ok := record.Float(FIT_DEVICE_INFO_SERIAL_NUMBER); ok {
+				device.SerialNumber = fmt.Sprintf("%d", uint32(v))
+			}
+			if v, ok := record.Float(FIT_DEVICE_INFO_SOFTWARE_VERSION); ok {
+				device.SoftwareVersion = fmt.Sprintf("%.2f", v/100.0)
+			}
+			if v, ok := record.Float(FIT_DEVICE_INFO_HARDWARE_VERSION); ok {
+				device.HardwareVersion = fmt.Sprintf("%d", int(v))
+			}
+			if v, ok := record.Fields[FIT_DEVICE_INFO_BATTERY_STATUS].(uint8); ok {
+				device.BatteryStatus = fitBatteryStatus[v]
+			}
+		}
+	}
+
+	if c, ok := devices[0]; ok {
+		if c.Manufacturer == "" {
+			c.Manufacturer = creator.Manufacturer
+		}
+		if c.Product == "" {
+			c.Product = creator.Product
+		}
+		if c.SerialNumber == "" {
+			c.SerialNumber = creator.SerialNumber
+		}
+	} else if creator.Manufacturer != "" || creator.SerialNumber != "" {
+		creator.DeviceType = "creator"
+		creator.Source = gormin.SourceFit
+		devices[0] = &creator
+		order = append([]uint8{0}, order...)
+	}
+
+	var result []gormin.Device
+	for _, index := range order {
+		result = append(result, *devices[index])
+	}
+	return result
+}
+
+// fitManufacturerName returns the name of a FIT manufacturer id
+func fitManufacturerName(id uint16) string {
+	if name, ok := fitManufacturers[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("manufacturer_%d", id)
+}
+
+// HeartRateFromRecords extracts timestamped heart rate samples from record messages
+func HeartRateFromRecords(records []FitRecord) []gormin.HeartRateSample {
+	var samples []gormin.HeartRateSample
+	for _, record := range records {
+		if record.MessageNum != FIT_MESG_RECORD || record.Timestamp.IsZero() {
+			continue
+		}
+		if hr, ok := record.Float(FIT_RECORD_HEART_RATE); ok && hr > 0 {
+			samples = append(samples, gormin.HeartRateSample{
+				Timestamp: record.Timestamp.Format("2006-01-02 15:04:05"),
+				HeartRate: int(hr),
+			})
+		}
+	}
+	return samples
+}
+
+// fitSemicircles converts FIT semicircles to degrees
+const fitSemicircles = 180.0 / (1 << 31)
+
+// fitPosition returns the position of a record message in degrees
+func fitPosition(record FitRecord) (lat, long float64, ok bool) {
+	latValue, latOK := record.Float(FIT_RECORD_POSITION_LAT)
+	longValue, longOK := record.Float(FIT_RECORD_POSITION_LONG)
+	if !latOK || !longOK {
+		return 0, 0, false
+	}
+	return latValue * fitSemicircles, longValue * fitSemicircles, true
+}
+
+// fitAltitude returns the altitude of a record message in meters
+func fitAltitude(record FitRecord) (float64, bool) {
+	v, ok := record.Float(FIT_RECORD_ENHANCED_ALTITUDE)
+	if !ok {
+		v, ok = record.Float(FIT_RECORD_ALTITUDE)
+	}
+	if !ok {
+		return 0, false
+	}
+	return v/5.0 - 500.0, true
+}
+
+// ActivityRecordsFromRecords extracts the timestamped samples of record messages
+func ActivityRecordsFromRecords(records []FitRecord) []gormin.ActivityRecord {
+	var samples []gormin.ActivityRecord
+	for _, record := range records {
+		if record.MessageNum != FIT_MESG_RECORD || record.Timestamp.IsZero() {
+			continue
+		}
+
+		sample := gormin.ActivityRecord{Timestamp: record.Timestamp.Format("2006-01-02 15:04:05")}
+		if lat, long, ok := fitPosition(record); ok {
+			sample.Latitude, sample.Longitude = lat, long
+		}
+		if v, ok := fitAltitude(record); ok {
+			sample.Altitude = v
+		}
+		if v, ok := record.Float(FIT_RECORD_DISTANCE); ok {
+			sample.Distance = v / 100.0
+		}
+		if v, ok := record.Float(FIT_RECORD_HEART_RATE); ok {
+			sample.HeartRate = int(v)
+		}
+		if v, ok := record.Float(FIT_RECORD_ENHANCED_SPEED); ok {
+			sample.Speed = v / 1000.0
+		} else if v, ok := record.Float(FIT_RECORD_SPEED); ok {
+			sample.Speed = v / 1000.0
+		}
+		if v, ok := record.Float(FIT_RECORD_CADENCE); ok {
+			sample.Cadence = int(v)
+		}
+		if v, ok := record.Float(FIT_RECORD_POWER); ok {
+			sample.Power = int(v)
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// FileType returns the file type from the file_id message
+func FileType(records []FitRecord) uint8 {
+	for _, record := range records {
+		if record.MessageNum == FIT_MESG_FILE_ID {
+			if v, ok := record.Fields[FIT_FILE_ID_TYPE].(uint8); ok {
+				return v
+			}
+		}
+	}
+	return FIT_FILE_ACTIVITY
+}
+
+// CourseFromRecords builds a course from the course and record messages of a
+// FIT course file
+func CourseFromRecords(records []FitRecord) *gormin.Course {
+	course := &gormin.Course{Name: "FIT Course", Sport: "generic", Source: gormin.SourceFit}
+
+	for _, record := range records {
+		switch record.MessageNum {
+		case FIT_MESG_COURSE:
+			if name, ok := record.String(FIT_COURSE_NAME); ok && name != "" {
+				course.Name = name
+			}
+			if v, ok := record.Fields[FIT_COURSE_SPORT].(uint8); ok {
+				if sport, ok := fitSports[v]; ok {
+					course.Sport = sport
+				}
+			}
+		case FIT_MESG_RECORD:
+			lat, long, ok := fitPosition(record)
+			if !ok {
+				continue
+			}
+			point := gormin.CoursePoint{Latitude: lat, Longitude: long}
+			if v, ok := fitAltitude(record); ok {
+				point.Elevation = v
+			}
+			if v, ok := record.Float(FIT_RECORD_DISTANCE); ok {
+				point.Distance = v / 100.0
+			}
+			course.Points = append(course.Points, point)
+		}
+	}
+
+	gormin.FinishCourse(course)
+	return course
+}
+
+// Close closes the FIT file
+func (fp *FitParser) Close() error {
+	return fp.file.Close()
+}
@@ -0,0 +1,316 @@
+package fit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// FIT base types used when encoding
+const (
+	FIT_BASE_ENUM    = 0x00
+	FIT_BASE_UINT8   = 0x02
+	FIT_BASE_UINT16  = 0x84
+	FIT_BASE_UINT32  = 0x86
+	FIT_BASE_STRING  = 0x07
+	FIT_BASE_UINT32Z = 0x8C
+)
+
+// FIT global message numbers used when encoding workouts
+const (
+	FIT_MESG_WORKOUT      = 26
+	FIT_MESG_WORKOUT_STEP = 27
+)
+
+// fitCRCTable is the nibble table of the FIT CRC-16
+var fitCRCTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+// fitCRC computes the FIT CRC-16 of data
+func fitCRC(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		tmp := fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[b&0xF]
+
+		tmp = fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[(b>>4)&0xF]
+	}
+	return crc
+}
+
+// FitField is a field of a FIT message to encode. Value must be a uint8,
+// uint16, uint32 or string matching the base type.
+type FitField struct {
+	Num      uint8
+	BaseType uint8
+	Value    interface{}
+}
+
+// FitMessage is a FIT data message to encode
+type FitMessage struct {
+	Num    uint16
+	Fields []FitField
+}
+
+// FitWriter encodes FIT messages into a FIT file
+type FitWriter struct {
+	data      bytes.Buffer
+	layouts   [16]string
+	nextLocal uint8
+}
+
+// NewFitWriter creates a new FIT writer
+func NewFitWriter() *FitWriter {
+	return &FitWriter{}
+}
+
+// Write appends a message, preceded by a definition message when its layout
+// has not been defined yet
+func (fw *FitWriter) Write(msg FitMessage) error {
+	var layout bytes.Buffer
+	binary.Write(&layout, binary.LittleEndian, msg.Num)
+	for _, field := range msg.Fields {
+		size, err := fitFieldSize(field)
+		if err != nil {
+			return fmt.Errorf("message %d field %d: %w", msg.Num, field.Num, err)
+		}
+		layout.Write([]byte{field.Num, size, field.BaseType})
+	}
+	key := layout.String()
+
+	// Reuse a local message type with the same layout, or define a new one
+	local := -1
+	for i, l := range fw.layouts {
+		if l == key {
+			local = i
+			break
+		}
+	}
+	if local < 0 {
+		local = int(fw.nextLocal)
+		fw.nextLocal = (fw.nextLocal + 1) % 16
+		fw.layouts[local] = key
+
+		fw.data.WriteByte(0x40 | uint8(local))
+		fw.data.Write([]byte{0, 0}) // reserved, little endian
+		binary.Write(&fw.data, binary.LittleEndian, msg.Num)
+		fw.data.WriteByte(uint8(len(msg.Fields)))
+		fw.data.Write(layout.Bytes()[2:])
+	}
+
+	fw.data.WriteByte(uint8(local))
+	for _, field := range msg.Fields {
+		switch v := field.Value.(type) {
+		case uint8:
+			fw.data.WriteByte(v)
+		case uint16:
+			binary.Write(&fw.data, binary.LittleEndian, v)
+		case uint32:
+			binary.Write(&fw.data, binary.LittleEndian, v)
+		case string:
+			fw.data.WriteString(v)
+			fw.data.WriteByte(0)
+		}
+	}
+	return nil
+}
+
+// Bytes returns the complete FIT file with header and CRCs
+func (fw *FitWriter) Bytes() []byte {
+	var out bytes.Buffer
+	header := make([]byte, 12)
+	header[0] = 14   // header size
+	header[1] = 0x20 // protocol version 2.0
+	binary.LittleEndian.PutUint16(header[2:4], 2132)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(fw.data.Len()))
+	copy(header[8:12], ".FIT")
+	out.Write(header)
+	binary.Write(&out, binary.LittleEndian, fitCRC(header))
+
+	out.Write(fw.data.Bytes())
+	binary.Write(&out, binary.LittleEndian, fitCRC(out.Bytes()))
+	return out.Bytes()
+}
+
+// fitFieldSize returns the encoded size of a field
+func fitFieldSize(field FitField) (uint8, error) {
+	switch v := field.Value.(type) {
+	case uint8:
+		return 1, nil
+	case uint16:
+		return 2, nil
+	case uint32:
+		return 4, nil
+	case string:
+		if len(v) > 254 {
+			return 0, fmt.Errorf("string too long")
+		}
+		return uint8(len(v) + 1), nil
+	}
+	return 0, fmt.Errorf("unsupported value type %T", field.Value)
+}
+
+// fitTimestamp converts a time to a FIT timestamp
+func fitTimestamp(t time.Time) uint32 {
+	return uint32(t.Unix() - FIT_EPOCH_OFFSET)
+}
+
+// FIT workout step duration and target types
+const (
+	fitDurationTime   = 0
+	fitDurationDist   = 1
+	fitDurationOpen   = 5
+	fitDurationRepeat = 6
+
+	fitTargetSpeed     = 0
+	fitTargetHeartRate = 1
+	fitTargetOpen      = 2
+	fitTargetCadence   = 3
+	fitTargetPower     = 4
+)
+
+// fitWorkoutSports maps workout sports to FIT sport values
+var fitWorkoutSports = map[string]uint8{
+	"other":             0,
+	"running":           1,
+	"cycling":           2,
+	"swimming":          5,
+	"strength_training": 10,
+	"cardio_training":   10,
+}
+
+// fitIntensities maps workout step types to FIT intensity values
+var fitIntensities = map[string]uint8{
+	"interval": 0,
+	"other":    0,
+	"rest":     1,
+	"warmup":   2,
+	"cooldown": 3,
+	"recovery": 4,
+}
+
+// WorkoutToFit encodes a workout as a FIT workout file for loading onto a watch
+func WorkoutToFit(workout *gormin.Workout) ([]byte, error) {
+	sport, ok := fitWorkoutSports[workout.Sport]
+	if !ok {
+		return nil, fmt.Errorf("unsupported workout sport %q", workout.Sport)
+	}
+
+	var steps []FitMessage
+	if err := appendFitWorkoutSteps(&steps, workout.Steps); err != nil {
+		return nil, err
+	}
+
+	fw := NewFitWriter()
+	messages := []FitMessage{
+		{Num: FIT_MESG_FILE_ID, Fields: []FitField{
+			{FIT_FILE_ID_TYPE, FIT_BASE_ENUM, uint8(FIT_FILE_WORKOUT)},
+			{1, FIT_BASE_UINT16, uint16(255)}, // development manufacturer
+			{2, FIT_BASE_UINT16, uint16(0)},
+			{3, FIT_BASE_UINT32Z, uint32(time.Now().Unix())},
+			{4, FIT_BASE_UINT32, fitTimestamp(time.Now())},
+		}},
+		{Num: FIT_MESG_WORKOUT, Fields: []FitField{
+			{4, FIT_BASE_ENUM, sport},
+			{6, FIT_BASE_UINT16, uint16(len(steps))},
+			{8, FIT_BASE_STRING, workout.Name},
+		}},
+	}
+	messages = append(messages, steps...)
+
+	for _, msg := range messages {
+		if err := fw.Write(msg); err != nil {
+			return nil, err
+		}
+	}
+	return fw.Bytes(), nil
+}
+
+// appendFitWorkoutSteps flattens workout steps into FIT workout_step messages.
+// A repeat group becomes its child steps followed by a repeat step pointing
+// back at the first child.
+func appendFitWorkoutSteps(out *[]FitMessage, steps []gormin.WorkoutStep) error {
+	for _, step := range steps {
+		if step.Type == "repeat" {
+			if step.Repeat < 1 || len(step.Steps) == 0 {
+				return fmt.Errorf("repeat step needs a repeat count and steps")
+			}
+			first := len(*out)
+			if err := appendFitWorkoutSteps(out, step.Steps); err != nil {
+				return err
+			}
+			*out = append(*out, FitMessage{Num: FIT_MESG_WORKOUT_STEP, Fields: []FitField{
+				{254, FIT_BASE_UINT16, uint16(len(*out))},
+				{1, FIT_BASE_ENUM, uint8(fitDurationRepeat)},
+				{2, FIT_BASE_UINT32, uint32(first)},
+				{3, FIT_BASE_ENUM, uint8(fitTargetOpen)},
+				{4, FIT_BASE_UINT32, uint32(step.Repeat)},
+			}})
+			continue
+		}
+
+		intensity, ok := fitIntensities[step.Type]
+		if !ok {
+			return fmt.Errorf("unsupported step type %q", step.Type)
+		}
+
+		var durationType uint8
+		var durationValue uint32
+		switch step.DurationType {
+		case "time":
+			durationType, durationValue = fitDurationTime, uint32(step.DurationValue*1000) // ms
+		case "distance":
+			durationType, durationValue = fitDurationDist, uint32(step.DurationValue*100) // cm
+		case "", "lap_button":
+			durationType = fitDurationOpen
+		default:
+			return fmt.Errorf("unsupported duration type %q", step.DurationType)
+		}
+
+		// Custom heart rate and power ranges are offset by 100 and 1000 to
+		// tell them apart from zone numbers
+		targetType := uint8(fitTargetOpen)
+		var targetValue, low, high uint32
+		switch step.TargetType {
+		case "", "none":
+		case "heart_rate":
+			targetType, low, high = fitTargetHeartRate, uint32(step.TargetLow)+100, uint32(step.TargetHigh)+100
+		case "heart_rate_zone":
+			targetType, targetValue = fitTargetHeartRate, uint32(step.Zone)
+		case "power":
+			targetType, low, high = fitTargetPower, uint32(step.TargetLow)+1000, uint32(step.TargetHigh)+1000
+		case "power_zone":
+			targetType, targetValue = fitTargetPower, uint32(step.Zone)
+		case "speed":
+			targetType, low, high = fitTargetSpeed, uint32(step.TargetLow*1000), uint32(step.TargetHigh*1000)
+		case "cadence":
+			targetType, low, high = fitTargetCadence, uint32(step.TargetLow), uint32(step.TargetHigh)
+		default:
+			return fmt.Errorf("unsupported target type %q", step.TargetType)
+		}
+
+		fields := []FitField{
+			{254, FIT_BASE_UINT16, uint16(len(*out))},
+			{1, FIT_BASE_ENUM, durationType},
+			{2, FIT_BASE_UINT32, durationValue},
+			{3, FIT_BASE_ENUM, targetType},
+			{4, FIT_BASE_UINT32, targetValue},
+			{5, FIT_BASE_UINT32, low},
+			{6, FIT_BASE_UINT32, high},
+			{7, FIT_BASE_ENUM, intensity},
+		}
+		if step.Description != "" {
+			fields = append(fields, FitField{8, FIT_BASE_STRING, step.Description})
+		}
+		*out = append(*out, FitMessage{Num: FIT_MESG_WORKOUT_STEP, Fields: fields})
+	}
+	return nil
+}
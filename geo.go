@@ -0,0 +1,69 @@
+package gormin
+
+import "math"
+
+// EarthRadius is the mean radius of the Earth in meters
+const EarthRadius = 6371000.0
+
+// elevationThreshold is the minimum climb or drop in meters counted towards
+// ascent and descent, filtering out GPS and barometer noise
+const elevationThreshold = 3.0
+
+// Haversine returns the distance in meters between two positions
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180.0
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadius * math.Asin(math.Sqrt(a))
+}
+
+// ElevationChange returns the total ascent and descent of an elevation
+// profile, ignoring changes smaller than elevationThreshold
+func ElevationChange(elevations []float64) (ascent, descent float64) {
+	if len(elevations) == 0 {
+		return 0, 0
+	}
+	ref := elevations[0]
+	for _, e := range elevations[1:] {
+		switch diff := e - ref; {
+		case diff >= elevationThreshold:
+			ascent += diff
+			ref = e
+		case diff <= -elevationThreshold:
+			descent -= diff
+			ref = e
+		}
+	}
+	return ascent, descent
+}
+
+// FinishCourse fills in point distances when the source lacked them and
+// derives the total distance, ascent and descent from the points
+func FinishCourse(course *Course) {
+	points := course.Points
+	if len(points) == 0 {
+		return
+	}
+
+	if len(points) > 1 && points[len(points)-1].Distance == 0 {
+		for i := 1; i < len(points); i++ {
+			points[i].Distance = points[i-1].Distance + Haversine(
+				points[i-1].Latitude, points[i-1].Longitude, points[i].Latitude, points[i].Longitude)
+		}
+	}
+	if course.Distance == 0 {
+		course.Distance = points[len(points)-1].Distance / 1000.0
+	}
+
+	if course.TotalAscent == 0 && course.TotalDescent == 0 {
+		elevations := make([]float64, len(points))
+		for i, p := range points {
+			elevations[i] = p.Elevation
+		}
+		ascent, descent := ElevationChange(elevations)
+		course.TotalAscent = int(math.Round(ascent))
+		course.TotalDescent = int(math.Round(descent))
+	}
+}
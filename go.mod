@@ -1,5 +1,9 @@
-module gormin
+module github.com/saram12saram2/gormin
 
 go 1.24.3
 
-require github.com/mattn/go-sqlite3 v1.14.28 // indirect
+require (
+	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.28
+	gopkg.in/yaml.v3 v3.0.1
+)
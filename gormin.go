@@ -0,0 +1,330 @@
+// Package gormin defines the activity and health data types shared by the
+// FIT decoder, the Garmin Connect client and the storage backends.
+package gormin
+
+// Sources of stored data, recorded alongside values that can come from more
+// than one place
+const (
+	// SourceConnect marks data downloaded from Garmin Connect
+	SourceConnect = "connect"
+	// SourceFit marks data imported from FIT files
+	SourceFit = "fit"
+	// SourceLocal marks personal records computed from local activity records
+	SourceLocal = "local"
+)
+
+// Activity is a fitness activity as listed by Garmin Connect or read from a
+// FIT file. ID is the Garmin Connect activity id, or zero for activities
+// that only exist locally. Distance is in kilometers and Duration in seconds;
+// StartTime is local time formatted as "2006-01-02 15:04:05".
+type Activity struct {
+	ID            int     `json:"id"`
+	Name          string  `json:"name"`
+	Type          string  `json:"type"`
+	StartTime     string  `json:"start_time"`
+	Duration      int     `json:"duration"`
+	Distance      float64 `json:"distance"`
+	Calories      int     `json:"calories"`
+	AvgHR         int     `json:"avg_hr"`
+	MaxHR         int     `json:"max_hr"`
+	ElevationGain int     `json:"elevation_gain"`
+}
+
+// ActivityDetails holds detailed activity data from Garmin Connect; speeds are in m/s
+type ActivityDetails struct {
+	Activity       Activity         `json:"activity"`
+	Description    string           `json:"description"`
+	Privacy        string           `json:"privacy"`
+	MovingDuration int              `json:"moving_duration"`
+	ElevationLoss  int              `json:"elevation_loss"`
+	AvgSpeed       float64          `json:"avg_speed"`
+	MaxSpeed       float64          `json:"max_speed"`
+	AvgPower       int              `json:"avg_power"`
+	MaxPower       int              `json:"max_power"`
+	AvgCadence     float64          `json:"avg_cadence"`
+	AerobicTE      float64          `json:"aerobic_te"`
+	AnaerobicTE    float64          `json:"anaerobic_te"`
+	Splits         []ActivitySplit  `json:"splits"`
+	HRZones        []ZoneTime       `json:"hr_zones"`
+	PowerZones     []ZoneTime       `json:"power_zones"`
+	Weather        *ActivityWeather `json:"weather"`
+}
+
+// ActivitySplit is a lap or split within an activity
+type ActivitySplit struct {
+	Index         int     `json:"index"`
+	StartTime     string  `json:"start_time"`
+	Duration      int     `json:"duration"`
+	Distance      float64 `json:"distance"`
+	AvgSpeed      float64 `json:"avg_speed"`
+	AvgHR         int     `json:"avg_hr"`
+	MaxHR         int     `json:"max_hr"`
+	ElevationGain int     `json:"elevation_gain"`
+	ElevationLoss int     `json:"elevation_loss"`
+	Calories      int     `json:"calories"`
+}
+
+// ZoneTime is the time spent in a heart rate or power zone
+type ZoneTime struct {
+	Zone        int     `json:"zone"`
+	Seconds     int     `json:"seconds"`
+	LowBoundary float64 `json:"low_boundary"`
+}
+
+// ActivityWeather is the weather during an activity; temperatures in Celsius, wind in km/h
+type ActivityWeather struct {
+	Temperature         float64 `json:"temperature"`
+	ApparentTemperature float64 `json:"apparent_temperature"`
+	DewPoint            float64 `json:"dew_point"`
+	Humidity            int     `json:"humidity"`
+	WindSpeed           float64 `json:"wind_speed"`
+	WindGust            float64 `json:"wind_gust"`
+	WindDirection       string  `json:"wind_direction"`
+	Condition           string  `json:"condition"`
+	IssuedAt            string  `json:"issued_at"`
+}
+
+// Gear is a shoe, bike or other equipment tracked in Garmin Connect
+type Gear struct {
+	UUID        string  `json:"uuid"`
+	Name        string  `json:"name"`
+	MakeModel   string  `json:"make_model"`
+	Type        string  `json:"type"`
+	Status      string  `json:"status"`
+	DateBegin   string  `json:"date_begin"`
+	MaxDistance float64 `json:"max_distance"`
+	// Lifetime totals from Connect, over every activity using the gear
+	TotalDistance   float64 `json:"total_distance"`
+	TotalActivities int     `json:"total_activities"`
+}
+
+// Device is a watch, bike computer or sensor that recorded data
+type Device struct {
+	Manufacturer    string `json:"manufacturer"`
+	Product         string `json:"product"`
+	ProductName     string `json:"product_name"`
+	SerialNumber    string `json:"serial_number"`
+	SoftwareVersion string `json:"software_version"`
+	HardwareVersion string `json:"hardware_version"`
+	BatteryStatus   string `json:"battery_status"`
+	DeviceType      string `json:"device_type"`
+	Source          string `json:"source"`
+}
+
+// Workout is a structured workout, also the format of workout definition files
+type Workout struct {
+	ID          int           `json:"id,omitempty" yaml:"id,omitempty"`
+	Name        string        `json:"name" yaml:"name"`
+	Sport       string        `json:"sport" yaml:"sport"`
+	Description string        `json:"description,omitempty" yaml:"description,omitempty"`
+	Steps       []WorkoutStep `json:"steps" yaml:"steps"`
+}
+
+// WorkoutStep is a workout step or repeat group. Durations are in seconds (time) or meters
+// (distance); targets are bpm, watts, m/s (speed) or rpm, or a zone number.
+type WorkoutStep struct {
+	Type          string        `json:"type" yaml:"type"`
+	DurationType  string        `json:"duration_type,omitempty" yaml:"duration_type,omitempty"`
+	DurationValue float64       `json:"duration_value,omitempty" yaml:"duration_value,omitempty"`
+	TargetType    string        `json:"target_type,omitempty" yaml:"target_type,omitempty"`
+	TargetLow     float64       `json:"target_low,omitempty" yaml:"target_low,omitempty"`
+	TargetHigh    float64       `json:"target_high,omitempty" yaml:"target_high,omitempty"`
+	Zone          int           `json:"zone,omitempty" yaml:"zone,omitempty"`
+	Description   string        `json:"description,omitempty" yaml:"description,omitempty"`
+	Repeat        int           `json:"repeat,omitempty" yaml:"repeat,omitempty"`
+	Steps         []WorkoutStep `json:"steps,omitempty" yaml:"steps,omitempty"`
+}
+
+// Course is a saved course or route; distance is in km, ascent and descent in meters
+type Course struct {
+	ID           int           `json:"id"`
+	GarminID     int           `json:"garmin_id"`
+	Name         string        `json:"name"`
+	Sport        string        `json:"sport"`
+	Description  string        `json:"description"`
+	Distance     float64       `json:"distance"`
+	TotalAscent  int           `json:"total_ascent"`
+	TotalDescent int           `json:"total_descent"`
+	Source       string        `json:"source"`
+	FilePath     string        `json:"file_path"`
+	Points       []CoursePoint `json:"points"`
+}
+
+// CoursePoint is a point along a course; distance is meters from the start
+type CoursePoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Elevation float64 `json:"elevation"`
+	Distance  float64 `json:"distance"`
+}
+
+// ActivityRecord is a sample recorded during an activity; distance is meters from the start,
+// speed is m/s and a zero position means no GPS fix
+type ActivityRecord struct {
+	Timestamp string  `json:"timestamp"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+	Distance  float64 `json:"distance"`
+	HeartRate int     `json:"heart_rate"`
+	Speed     float64 `json:"speed"`
+	Cadence   int     `json:"cadence"`
+	Power     int     `json:"power"`
+}
+
+// PersonalRecord is a personal record from Garmin Connect or computed locally; value is seconds
+// for fastest efforts and meters for longest efforts
+type PersonalRecord struct {
+	RecordType       string  `json:"record_type"`
+	Source           string  `json:"source"`
+	Value            float64 `json:"value"`
+	ActivityID       int     `json:"activity_id"`
+	GarminActivityID int     `json:"garmin_activity_id"`
+	AchievedAt       string  `json:"achieved_at"`
+}
+
+// BestEffort is the best effort within a single activity, in the units of PersonalRecord
+type BestEffort struct {
+	Effort    string  `json:"effort"`
+	Value     float64 `json:"value"`
+	StartTime string  `json:"start_time"`
+}
+
+// ActivitySummary totals the activities of one type over a week, month or year;
+// distance is in km, duration in seconds and AvgHR is zero without heart rate
+type ActivitySummary struct {
+	Period        string  `json:"period"`
+	PeriodKey     string  `json:"period_key"`
+	PeriodStart   string  `json:"period_start"`
+	Type          string  `json:"type"`
+	Activities    int     `json:"activities"`
+	Distance      float64 `json:"distance"`
+	Duration      int     `json:"duration"`
+	ElevationGain int     `json:"elevation_gain"`
+	Calories      int     `json:"calories"`
+	AvgHR         float64 `json:"avg_hr"`
+}
+
+// DailySummary totals the daily statistics of a week, month or year; sleep and
+// resting heart rate are averaged over the days that have them
+type DailySummary struct {
+	Period        string  `json:"period"`
+	PeriodKey     string  `json:"period_key"`
+	PeriodStart   string  `json:"period_start"`
+	Days          int     `json:"days"`
+	Steps         int     `json:"steps"`
+	Distance      float64 `json:"distance"`
+	Calories      int     `json:"calories"`
+	AvgSleepHours float64 `json:"avg_sleep_hours"`
+	AvgRestingHR  float64 `json:"avg_resting_hr"`
+}
+
+// RawResponse is an archived Garmin Connect API response; Body is the
+// uncompressed JSON as received
+type RawResponse struct {
+	Endpoint  string `json:"endpoint"`
+	Key       string `json:"key"`
+	FetchedAt string `json:"fetched_at"`
+	Body      []byte `json:"body"`
+}
+
+// DailyStats holds daily health statistics. BodyBatteryAvg and StressMin are
+// not in Connect's daily summary; they are derived from the day's time series
+// and nil while there are no samples.
+type DailyStats struct {
+	Date       string  `json:"date"`
+	Steps      int     `json:"steps"`
+	Distance   float64 `json:"distance"`
+	Calories   int     `json:"calories"`
+	SleepHours float64 `json:"sleep_hours"`
+	RestingHR  int     `json:"resting_hr"`
+	Weight     float64 `json:"weight"`
+	BodyFat    float64 `json:"body_fat"`
+
+	BodyBatteryMin     int     `json:"body_battery_min"`
+	BodyBatteryMax     int     `json:"body_battery_max"`
+	BodyBatteryAvg     *int    `json:"body_battery_avg,omitempty"`
+	BodyBatteryCharged int     `json:"body_battery_charged"`
+	BodyBatteryDrained int     `json:"body_battery_drained"`
+	StressMin          *int    `json:"stress_min,omitempty"`
+	StressAvg          int     `json:"stress_avg"`
+	StressMax          int     `json:"stress_max"`
+	RespirationMin     float64 `json:"respiration_min"`
+	RespirationMax     float64 `json:"respiration_max"`
+	RespirationAvg     float64 `json:"respiration_avg"`
+}
+
+// WellnessSample is a wellness time series measurement (Body Battery, stress, respiration)
+type WellnessSample struct {
+	Timestamp string  `json:"timestamp"`
+	Value     float64 `json:"value"`
+	Source    string  `json:"source"`
+}
+
+// HRVStatus is the nightly heart rate variability status
+type HRVStatus struct {
+	Date                  string  `json:"date"`
+	WeeklyAvg             float64 `json:"weekly_avg"`
+	LastNightAvg          float64 `json:"last_night_avg"`
+	LastNight5MinHigh     float64 `json:"last_night_5min_high"`
+	BaselineLowUpper      float64 `json:"baseline_low_upper"`
+	BaselineBalancedLow   float64 `json:"baseline_balanced_low"`
+	BaselineBalancedUpper float64 `json:"baseline_balanced_upper"`
+	Status                string  `json:"status"`
+}
+
+// PulseOx is the nightly blood oxygen saturation
+type PulseOx struct {
+	Date             string  `json:"date"`
+	AvgSpO2          float64 `json:"avg_spo2"`
+	LowestSpO2       float64 `json:"lowest_spo2"`
+	AvgSleepSpO2     float64 `json:"avg_sleep_spo2"`
+	LastSevenDaysAvg float64 `json:"last_seven_days_avg"`
+}
+
+// WeightEntry is a weigh-in with body composition
+type WeightEntry struct {
+	Date            string  `json:"date"`
+	Timestamp       string  `json:"timestamp"`
+	Weight          float64 `json:"weight"`
+	BodyFat         float64 `json:"body_fat"`
+	MuscleMass      float64 `json:"muscle_mass"`
+	BoneMass        float64 `json:"bone_mass"`
+	WaterPercentage float64 `json:"water_percentage"`
+}
+
+// SleepData is a nightly sleep summary
+type SleepData struct {
+	Date       string       `json:"date"`
+	StartTime  string       `json:"start_time"`
+	EndTime    string       `json:"end_time"`
+	Duration   int          `json:"duration"`
+	DeepSleep  int          `json:"deep_sleep"`
+	LightSleep int          `json:"light_sleep"`
+	RemSleep   int          `json:"rem_sleep"`
+	AwakeTime  int          `json:"awake_time"`
+	SleepScore int          `json:"sleep_score"`
+	Levels     []SleepLevel `json:"levels"`
+}
+
+// HeartRateDay is the heart rate for a single day
+type HeartRateDay struct {
+	Date      string            `json:"date"`
+	RestingHR int               `json:"resting_hr"`
+	MinHR     int               `json:"min_hr"`
+	MaxHR     int               `json:"max_hr"`
+	Samples   []HeartRateSample `json:"samples"`
+}
+
+// HeartRateSample is a heart rate measurement
+type HeartRateSample struct {
+	Timestamp string `json:"timestamp"`
+	HeartRate int    `json:"heart_rate"`
+}
+
+// SleepLevel is a sleep stage interval within a night
+type SleepLevel struct {
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Stage     string `json:"stage"`
+}
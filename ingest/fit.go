@@ -0,0 +1,116 @@
+package ingest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/saram12saram2/gormin"
+	"github.com/saram12saram2/gormin/analysis"
+	"github.com/saram12saram2/gormin/fit"
+	"github.com/saram12saram2/gormin/storage"
+)
+
+// FitProcessor handles processing of FIT files
+type FitProcessor struct {
+	dataPath string
+	store    storage.Store
+}
+
+// NewFitProcessor creates a new FIT processor storing into store
+func NewFitProcessor(dataPath string, store storage.Store) *FitProcessor {
+	return &FitProcessor{dataPath: dataPath, store: store}
+}
+
+// ProcessFitFiles processes all FIT files in the data directory, along with
+// GPX files which are imported as courses
+func (fp *FitProcessor) ProcessFitFiles() error {
+	return filepath.Walk(fp.dataPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".fit":
+			fmt.Printf("Processing FIT file: %s\n", path)
+			if err := fp.ProcessFile(path); err != nil {
+				fmt.Printf("Error processing %s: %v\n", path, err)
+				// Continue processing other files
+				return nil
+			}
+		case ".gpx":
+			fmt.Printf("Processing GPX course: %s\n", path)
+			if err := ImportGPXCourse(fp.store, path); err != nil {
+				fmt.Printf("Error processing %s: %v\n", path, err)
+				return nil
+			}
+		}
+
+		return nil
+	})
+}
+
+// ProcessFile processes a single FIT file
+func (fp *FitProcessor) ProcessFile(filename string) error {
+	parser, err := fit.NewFitParser(filename)
+	if err != nil {
+		return err
+	}
+	defer parser.Close()
+
+	records, err := parser.ParseRecords()
+	if err != nil {
+		return err
+	}
+
+	if fit.FileType(records) == fit.FIT_FILE_COURSE {
+		course := fit.CourseFromRecords(records)
+		course.FilePath = filename
+		return fp.store.SaveCourse(course)
+	}
+	activity := fit.ActivityFromRecords(records)
+
+	// Files downloaded by sync are named after their Connect activity id
+	fmt.Sscanf(filepath.Base(filename), "activity_%d.fit", &activity.ID)
+
+	// Store activity in database, merging it with the synced activity
+	localID, err := fp.store.SaveActivity(activity, gormin.SourceFit)
+	if err != nil {
+		return err
+	}
+
+	// Store per-activity heart rate linked to the stored activity
+	if err := fp.store.SaveHeartRate(fit.HeartRateFromRecords(records), &localID); err != nil {
+		return err
+	}
+
+	// Store the recorded samples for comparison against courses and best efforts
+	samples := fit.ActivityRecordsFromRecords(records)
+	if err := fp.store.SaveActivityRecords(localID, samples); err != nil {
+		return err
+	}
+	if err := ProcessBestEfforts(fp.store, localID, activity.Type, samples); err != nil {
+		return err
+	}
+
+	// Store the devices that recorded the activity
+	for _, device := range fit.DevicesFromRecords(records) {
+		if err := fp.store.SaveActivityDevice(localID, &device, activity.StartTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportGPXCourse parses a GPX file and stores it as a course
+func ImportGPXCourse(store storage.Store, path string) error {
+	course, err := analysis.ParseGPXCourse(path)
+	if err != nil {
+		return err
+	}
+	return store.SaveCourse(course)
+}
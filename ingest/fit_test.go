@@ -0,0 +1,164 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+	"github.com/saram12saram2/gormin/fit"
+	"github.com/saram12saram2/gormin/storage"
+)
+
+// testStart is the start of the activities in test FIT files and Connect responses
+var testStart = time.Date(2024, 3, 4, 7, 0, 0, 0, time.Local)
+
+// testStartTime is testStart as activities store it
+var testStartTime = testStart.Format("2006-01-02 15:04:05")
+
+// fitTimestamp converts a time to a FIT timestamp
+func fitTimestamp(t time.Time) uint32 {
+	return uint32(t.Unix() - fit.FIT_EPOCH_OFFSET)
+}
+
+// activityFit encodes a running activity starting at start with one record
+// per second for the given number of seconds, ending with its session
+func activityFit(t *testing.T, start time.Time, seconds int) []byte {
+	t.Helper()
+	fw := fit.NewFitWriter()
+	write := func(msg fit.FitMessage) {
+		if err := fw.Write(msg); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	write(fit.FitMessage{Num: fit.FIT_MESG_FILE_ID, Fields: []fit.FitField{
+		{Num: fit.FIT_FILE_ID_TYPE, BaseType: fit.FIT_BASE_ENUM, Value: uint8(fit.FIT_FILE_ACTIVITY)},
+		{Num: fit.FIT_FILE_ID_MANUFACTURER, BaseType: fit.FIT_BASE_UINT16, Value: uint16(1)},
+		{Num: fit.FIT_FILE_ID_SERIAL_NUMBER, BaseType: fit.FIT_BASE_UINT32Z, Value: uint32(3900000001)},
+	}})
+	for i := 0; i <= seconds; i++ {
+		write(fit.FitMessage{Num: fit.FIT_MESG_RECORD, Fields: []fit.FitField{
+			{Num: fit.FIT_FIELD_TIMESTAMP, BaseType: fit.FIT_BASE_UINT32, Value: fitTimestamp(start.Add(time.Duration(i) * time.Second))},
+			{Num: fit.FIT_RECORD_HEART_RATE, BaseType: fit.FIT_BASE_UINT8, Value: uint8(140 + i%10)},
+			{Num: fit.FIT_RECORD_DISTANCE, BaseType: fit.FIT_BASE_UINT32, Value: uint32(i * 300)}, // 3 m/s in cm
+		}})
+	}
+	write(fit.FitMessage{Num: fit.FIT_MESG_SESSION, Fields: []fit.FitField{
+		{Num: fit.FIT_FIELD_TIMESTAMP, BaseType: fit.FIT_BASE_UINT32, Value: fitTimestamp(start.Add(time.Duration(seconds) * time.Second))},
+		{Num: fit.FIT_SESSION_START_TIME, BaseType: fit.FIT_BASE_UINT32, Value: fitTimestamp(start)},
+		{Num: fit.FIT_SESSION_SPORT, BaseType: fit.FIT_BASE_ENUM, Value: uint8(1)},
+		{Num: fit.FIT_SESSION_TOTAL_ELAPSED_TIME, BaseType: fit.FIT_BASE_UINT32, Value: uint32(seconds * 1000)},
+		{Num: fit.FIT_SESSION_TOTAL_DISTANCE, BaseType: fit.FIT_BASE_UINT32, Value: uint32(seconds * 300)},
+		{Num: fit.FIT_SESSION_AVG_HEART_RATE, BaseType: fit.FIT_BASE_UINT8, Value: uint8(144)},
+		{Num: fit.FIT_SESSION_MAX_HEART_RATE, BaseType: fit.FIT_BASE_UINT8, Value: uint8(149)},
+	}})
+	return fw.Bytes()
+}
+
+// writeFile writes data to name in dir and returns its path
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestProcessFile(t *testing.T) {
+	store := storage.NewMemoryStore()
+	path := writeFile(t, t.TempDir(), "run.fit", activityFit(t, testStart, 600))
+
+	fp := NewFitProcessor(filepath.Dir(path), store)
+	if err := fp.ProcessFile(path); err != nil {
+		t.Fatalf("ProcessFile: %v", err)
+	}
+
+	if len(store.Activities) != 1 {
+		t.Fatalf("%d activities, want 1", len(store.Activities))
+	}
+	var localID int
+	var activity *gormin.Activity
+	for id, a := range store.Activities {
+		localID, activity = id, a
+	}
+	if activity.ID != 0 {
+		t.Errorf("Connect id = %d, want none for a file not named after an activity", activity.ID)
+	}
+	if activity.Type != "running" || activity.StartTime != testStartTime || activity.Duration != 600 ||
+		activity.AvgHR != 144 || activity.MaxHR != 149 {
+		t.Errorf("activity = %+v", activity)
+	}
+	if activity.Distance != 1.8 {
+		t.Errorf("distance = %.3f km, want 1.8", activity.Distance)
+	}
+
+	if n := len(store.Records[localID]); n != 601 {
+		t.Errorf("%d records, want 601", n)
+	}
+	if n := len(store.HeartRate[localID]); n != 601 {
+		t.Errorf("%d heart rate samples, want 601", n)
+	}
+	if n := len(store.HeartRate[0]); n != 0 {
+		t.Errorf("%d activity samples stored as all-day heart rate", n)
+	}
+	if len(store.BestEfforts[localID]) == 0 {
+		t.Error("no best efforts computed")
+	}
+	if len(store.ActivityDevices[localID]) == 0 {
+		t.Error("recording device not stored")
+	}
+
+	// Importing the file again updates the same activity
+	if err := fp.ProcessFile(path); err != nil {
+		t.Fatalf("second ProcessFile: %v", err)
+	}
+	if len(store.Activities) != 1 {
+		t.Errorf("%d activities after importing twice, want 1", len(store.Activities))
+	}
+}
+
+func TestProcessFitFilesMergesSyncedActivity(t *testing.T) {
+	store := storage.NewMemoryStore()
+	connectID, err := store.SaveActivity(&gormin.Activity{
+		ID: 9000000001, Name: "Morning Run", Type: "running", StartTime: testStartTime,
+		Duration: 605, Distance: 1.81,
+	}, gormin.SourceConnect)
+	if err != nil {
+		t.Fatalf("SaveActivity: %v", err)
+	}
+	// An unrelated activity recorded earlier the same day
+	if _, err := store.SaveActivity(&gormin.Activity{
+		ID: 9000000000, Name: "Walk", Type: "walking", StartTime: testStart.Add(-2 * time.Hour).Format("2006-01-02 15:04:05"),
+	}, gormin.SourceConnect); err != nil {
+		t.Fatalf("SaveActivity: %v", err)
+	}
+
+	dir := t.TempDir()
+	writeFile(t, dir, "activity_9000000001.fit", activityFit(t, testStart, 600))
+	writeFile(t, dir, "notes.txt", []byte("not a FIT file"))
+	// A corrupt file is reported and skipped
+	writeFile(t, dir, "broken.fit", []byte("not a FIT file"))
+
+	if err := NewFitProcessor(dir, store).ProcessFitFiles(); err != nil {
+		t.Fatalf("ProcessFitFiles: %v", err)
+	}
+
+	if len(store.Activities) != 2 {
+		t.Fatalf("%d activities, want 2", len(store.Activities))
+	}
+	activity := store.Activities[connectID]
+	if activity.ID != 9000000001 || activity.Name != "Morning Run" {
+		t.Errorf("synced activity became %+v", activity)
+	}
+	// Connect totals are kept and FIT fills in what Connect lacks
+	if activity.Duration != 605 || activity.Distance != 1.81 || activity.AvgHR != 144 {
+		t.Errorf("merged activity has %d s, %.2f km, %d bpm; want 605 s, 1.81 km, 144 bpm",
+			activity.Duration, activity.Distance, activity.AvgHR)
+	}
+	if n := len(store.Records[connectID]); n != 601 {
+		t.Errorf("%d records on the synced activity, want 601", n)
+	}
+}
@@ -0,0 +1,96 @@
+package ingest
+
+import (
+	"fmt"
+
+	"github.com/saram12saram2/gormin"
+	"github.com/saram12saram2/gormin/analysis"
+	"github.com/saram12saram2/gormin/storage"
+)
+
+// ProcessBestEfforts computes and stores the best efforts of an activity and
+// reports any that set a new local personal record
+func ProcessBestEfforts(store storage.Store, activityID int, activityType string, records []gormin.ActivityRecord) error {
+	efforts := analysis.BestEffortsFromRecords(activityType, records)
+	if len(efforts) == 0 {
+		return nil
+	}
+	if err := store.SaveBestEfforts(activityID, efforts); err != nil {
+		return err
+	}
+
+	current, err := store.PersonalRecords(gormin.SourceLocal)
+	if err != nil {
+		return err
+	}
+	for _, effort := range efforts {
+		previous, ok := current[effort.Effort]
+		if ok && !analysis.BeatsRecord(effort.Effort, effort.Value, previous.Value) {
+			continue
+		}
+
+		record := gormin.PersonalRecord{
+			RecordType: effort.Effort,
+			Source:     gormin.SourceLocal,
+			Value:      effort.Value,
+			ActivityID: activityID,
+			AchievedAt: effort.StartTime,
+		}
+		if err := store.SavePersonalRecord(&record); err != nil {
+			return err
+		}
+		if ok {
+			fmt.Printf("New personal record: %s %s (previous %s)\n", effort.Effort,
+				analysis.FormatRecordValue(effort.Effort, effort.Value), analysis.FormatRecordValue(effort.Effort, previous.Value))
+		} else {
+			fmt.Printf("New personal record: %s %s\n", effort.Effort, analysis.FormatRecordValue(effort.Effort, effort.Value))
+		}
+	}
+	return nil
+}
+
+// RecomputeBestEfforts recomputes the best efforts of every activity with
+// stored records and rebuilds the local personal records from them
+func RecomputeBestEfforts(store storage.Store) error {
+	activities, err := store.ActivitiesWithRecords()
+	if err != nil {
+		return err
+	}
+
+	best := make(map[string]gormin.PersonalRecord)
+	for _, activity := range activities {
+		records, err := store.ActivityRecords(activity.ID)
+		if err != nil {
+			return err
+		}
+		efforts := analysis.BestEffortsFromRecords(activity.Type, records)
+		if err := store.SaveBestEfforts(activity.ID, efforts); err != nil {
+			return err
+		}
+
+		for _, effort := range efforts {
+			if previous, ok := best[effort.Effort]; ok && !analysis.BeatsRecord(effort.Effort, effort.Value, previous.Value) {
+				continue
+			}
+			best[effort.Effort] = gormin.PersonalRecord{
+				RecordType: effort.Effort,
+				Source:     gormin.SourceLocal,
+				Value:      effort.Value,
+				ActivityID: activity.ID,
+				AchievedAt: effort.StartTime,
+			}
+		}
+	}
+
+	if err := store.DeletePersonalRecords(gormin.SourceLocal); err != nil {
+		return err
+	}
+	for _, record := range best {
+		if err := store.SavePersonalRecord(&record); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Recomputed best efforts for %d activities (%d records)\n", len(activities), len(best))
+	return nil
+}
@@ -0,0 +1,329 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/saram12saram2/gormin"
+	"github.com/saram12saram2/gormin/connect"
+	"github.com/saram12saram2/gormin/storage"
+)
+
+// archivedResponse is an archived response of a known kind with the id or
+// date it belongs to
+type archivedResponse struct {
+	id   string
+	body []byte
+}
+
+// Reprocess re-derives everything a sync stores from the Connect responses
+// archived by earlier syncs, without network access. It picks up changes in
+// how responses are mapped to our tables. Activities are replayed first so
+// details and gear can be stored for their local ids.
+func Reprocess(store storage.Store) error {
+	activityIDs, err := reprocessActivities(store)
+	if err != nil {
+		return err
+	}
+	days, err := reprocessDailyStats(store)
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := store.RawResponseEndpoints()
+	if err != nil {
+		return err
+	}
+	responses := make(map[connect.ResponseKind][]archivedResponse)
+	skipped := 0
+	for _, endpoint := range endpoints {
+		if endpoint == connect.EndpointActivities || endpoint == connect.EndpointDailyStats {
+			continue
+		}
+		archived, err := store.RawResponses(endpoint)
+		if err != nil {
+			return err
+		}
+		for _, response := range archived {
+			kind, id := connect.ClassifyResponse(endpoint, response.Key)
+			if kind == "" {
+				skipped++
+				continue
+			}
+			responses[kind] = append(responses[kind], archivedResponse{id: id, body: response.Body})
+		}
+	}
+
+	gearStats := responsesByID(responses[connect.ResponseGearStats])
+	splits := responsesByID(responses[connect.ResponseSplits])
+	hrZones := responsesByID(responses[connect.ResponseHRZones])
+	powerZones := responsesByID(responses[connect.ResponsePowerZones])
+	weather := responsesByID(responses[connect.ResponseWeather])
+	// Wellness samples by date and table, to derive the daily values from
+	wellness := make(map[string]map[string][]gormin.WellnessSample)
+
+	// Responses are replayed in the order a sync stores them; responses only
+	// read along with another kind have no entry of their own
+	steps := []struct {
+		kind  connect.ResponseKind
+		apply func(id string, body []byte) error
+	}{
+		{connect.ResponseGear, func(id string, body []byte) error {
+			gear, err := connect.ParseGear(body)
+			if err != nil {
+				return err
+			}
+			for i := range gear {
+				if stats, ok := gearStats[gear[i].UUID]; ok {
+					if err := connect.ParseGearStats(stats, &gear[i]); err != nil {
+						return err
+					}
+				}
+				if err := store.SaveGear(&gear[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{connect.ResponseDevices, func(id string, body []byte) error {
+			devices, err := connect.ParseDevices(body)
+			if err != nil {
+				return err
+			}
+			for i := range devices {
+				if _, err := store.SaveDevice(&devices[i], ""); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{connect.ResponseWorkout, func(id string, body []byte) error {
+			workout, err := connect.ParseWorkout(body)
+			if err != nil {
+				return err
+			}
+			return store.SaveWorkout(workout)
+		}},
+		{connect.ResponseCourse, func(id string, body []byte) error {
+			course, err := connect.ParseCourse(body)
+			if err != nil {
+				return err
+			}
+			return store.SaveCourse(course)
+		}},
+		{connect.ResponseActivityDetails, func(id string, body []byte) error {
+			details, err := connect.ParseActivityDetails(body)
+			if err != nil {
+				return err
+			}
+			if details.Splits, err = connect.ParseSplits(splits[id]); err != nil {
+				return err
+			}
+			if details.HRZones, err = connect.ParseZoneTimes(hrZones[id]); err != nil {
+				return err
+			}
+			if details.PowerZones, err = connect.ParseZoneTimes(powerZones[id]); err != nil {
+				return err
+			}
+			if details.Weather, err = connect.ParseWeather(weather[id]); err != nil {
+				return err
+			}
+
+			localID, ok := activityIDs[details.Activity.ID]
+			if !ok {
+				// The activity is missing from the archived activity lists
+				if localID, err = store.SaveActivity(&details.Activity, gormin.SourceConnect); err != nil {
+					return err
+				}
+				activityIDs[details.Activity.ID] = localID
+			}
+			return store.SaveActivityDetails(localID, details)
+		}},
+		{connect.ResponseActivityGear, func(id string, body []byte) error {
+			activityID, err := strconv.Atoi(id)
+			if err != nil {
+				return err
+			}
+			localID, ok := activityIDs[activityID]
+			if !ok {
+				return fmt.Errorf("activity %d not archived: %w", activityID, connect.ErrNotFound)
+			}
+			gear, err := connect.ParseGear(body)
+			if err != nil {
+				return err
+			}
+			for _, g := range gear {
+				if err := store.LinkActivityGear(localID, g.UUID); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{connect.ResponsePersonalRecords, func(id string, body []byte) error {
+			records, err := connect.ParsePersonalRecords(body)
+			if err != nil {
+				return err
+			}
+			for i := range records {
+				if err := store.SavePersonalRecord(&records[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{connect.ResponseSleep, func(id string, body []byte) error {
+			sleep, err := connect.ParseSleep(id, body)
+			if err != nil {
+				return err
+			}
+			return store.SaveSleep(sleep)
+		}},
+		{connect.ResponseHeartRate, func(id string, body []byte) error {
+			day, err := connect.ParseHeartRate(id, body)
+			if err != nil {
+				return err
+			}
+			if err := store.SaveHeartRate(day.Samples, nil); err != nil {
+				return err
+			}
+			if day.RestingHR > 0 {
+				return store.SaveRestingHeartRate(day.Date, day.RestingHR)
+			}
+			return nil
+		}},
+		{connect.ResponseWeight, func(id string, body []byte) error {
+			entries, err := connect.ParseWeight(body)
+			if err != nil {
+				return err
+			}
+			for i := range entries {
+				if err := store.SaveWeight(&entries[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{connect.ResponseBodyBattery, wellnessStep(store, "body_battery", connect.ParseBodyBattery, wellness)},
+		{connect.ResponseStress, wellnessStep(store, "stress", connect.ParseStress, wellness)},
+		{connect.ResponseRespiration, wellnessStep(store, "respiration", connect.ParseRespiration, wellness)},
+		{connect.ResponseHRV, func(id string, body []byte) error {
+			hrv, err := connect.ParseHRVStatus(id, body)
+			if err != nil {
+				return err
+			}
+			return store.SaveHRVStatus(hrv)
+		}},
+		{connect.ResponsePulseOx, func(id string, body []byte) error {
+			spo2, err := connect.ParsePulseOx(id, body)
+			if err != nil {
+				return err
+			}
+			return store.SavePulseOx(spo2)
+		}},
+	}
+
+	replayed := 0
+	for _, step := range steps {
+		for _, response := range responses[step.kind] {
+			err := step.apply(response.id, response.body)
+			if errors.Is(err, connect.ErrNotFound) {
+				// Days without a reading, kept like any other response
+				skipped++
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", step.kind, response.id, err)
+			}
+			replayed++
+		}
+	}
+
+	dates := make([]string, 0, len(wellness))
+	for date := range wellness {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	for _, date := range dates {
+		bodyBatteryAvg, stressMin := dailyWellness(wellness[date]["body_battery"], wellness[date]["stress"])
+		if err := store.SaveDailyWellness(date, bodyBatteryAvg, stressMin); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Reprocessed %d activities, %d days of daily stats and %d other responses (%d skipped)\n",
+		len(activityIDs), days, replayed, skipped)
+	return nil
+}
+
+// reprocessActivities replays the archived activities and returns their local
+// ids by Connect id
+func reprocessActivities(store storage.Store) (map[int]int, error) {
+	activities, err := store.RawResponses(connect.EndpointActivities)
+	if err != nil {
+		return nil, err
+	}
+	localIDs := make(map[int]int)
+	for _, response := range activities {
+		activity, err := connect.ParseActivity(response.Body)
+		if err != nil {
+			return nil, fmt.Errorf("activity %s: %w", response.Key, err)
+		}
+		localID, err := store.SaveActivity(activity, gormin.SourceConnect)
+		if err != nil {
+			return nil, err
+		}
+		localIDs[activity.ID] = localID
+	}
+	return localIDs, nil
+}
+
+// reprocessDailyStats replays the archived daily statistics and returns the
+// number of days
+func reprocessDailyStats(store storage.Store) (int, error) {
+	days, err := store.RawResponses(connect.EndpointDailyStats)
+	if err != nil {
+		return 0, err
+	}
+	for _, response := range days {
+		stats, err := connect.ParseDailyStats(response.Body)
+		if err != nil {
+			return 0, fmt.Errorf("daily stats %s: %w", response.Key, err)
+		}
+		// Days without data come back without a date
+		if stats.Date == "" {
+			stats.Date = response.Key
+		}
+		if err := store.SaveDailyStats(stats); err != nil {
+			return 0, err
+		}
+	}
+	return len(days), nil
+}
+
+// wellnessStep stores the samples parsed from a wellness response in table
+// and adds them to the samples of their date in wellness
+func wellnessStep(store storage.Store, table string, parse func([]byte) ([]gormin.WellnessSample, error),
+	wellness map[string]map[string][]gormin.WellnessSample) func(string, []byte) error {
+	return func(date string, body []byte) error {
+		samples, err := parse(body)
+		if err != nil {
+			return err
+		}
+		if wellness[date] == nil {
+			wellness[date] = make(map[string][]gormin.WellnessSample)
+		}
+		wellness[date][table] = samples
+		return store.SaveWellnessSamples(table, samples)
+	}
+}
+
+// responsesByID indexes responses by the id or date they belong to
+func responsesByID(responses []archivedResponse) map[string][]byte {
+	byID := make(map[string][]byte, len(responses))
+	for _, response := range responses {
+		byID[response.id] = response.body
+	}
+	return byID
+}
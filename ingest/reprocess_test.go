@@ -0,0 +1,196 @@
+package ingest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+	"github.com/saram12saram2/gormin/connect"
+	"github.com/saram12saram2/gormin/storage"
+)
+
+// saveResponses archives raw responses as a sync would have
+func saveResponses(t *testing.T, store storage.Store, endpoint string, responses map[string]string) {
+	t.Helper()
+	for key, body := range responses {
+		if err := store.SaveRawResponse(endpoint, key, []byte(body)); err != nil {
+			t.Fatalf("SaveRawResponse: %v", err)
+		}
+	}
+}
+
+func TestReprocess(t *testing.T) {
+	store := storage.NewMemoryStore()
+	saveResponses(t, store, connect.EndpointActivities, map[string]string{
+		"9000000001": `{"activityId":9000000001,"activityName":"Morning Run","activityTypeKey":"running",` +
+			`"startTimeLocal":"` + testStartTime + `","duration":3600,"distance":10200,"averageHR":150}`,
+	})
+	saveResponses(t, store, connect.EndpointDailyStats, map[string]string{
+		"2024-03-04": `{"calendarDate":"2024-03-04","totalSteps":12000,"totalDistance":9000,"averageStressLevel":31}`,
+		// Days without data come back without a date
+		"2024-03-05": `{}`,
+	})
+
+	// The activity was imported from its FIT file without a sync
+	fitID, err := store.SaveActivity(&gormin.Activity{
+		Name: "FIT running", Type: "running", StartTime: testStartTime, Duration: 3590, AvgHR: 148, MaxHR: 170,
+	}, gormin.SourceFit)
+	if err != nil {
+		t.Fatalf("SaveActivity: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := Reprocess(store); err != nil {
+			t.Fatalf("Reprocess: %v", err)
+		}
+	}
+
+	if len(store.Activities) != 1 {
+		t.Fatalf("%d activities, want 1", len(store.Activities))
+	}
+	activity := store.Activities[fitID]
+	if activity.ID != 9000000001 || activity.Name != "Morning Run" || activity.Duration != 3600 ||
+		activity.AvgHR != 150 || activity.MaxHR != 170 {
+		t.Errorf("reprocessed activity = %+v", activity)
+	}
+
+	if stats := store.DailyStats["2024-03-04"]; stats.Steps != 12000 || stats.Distance != 9 || stats.StressAvg != 31 {
+		t.Errorf("daily stats = %+v", stats)
+	}
+	if _, ok := store.DailyStats["2024-03-05"]; !ok {
+		t.Error("day without data not stored under its key")
+	}
+}
+
+// wellnessResponses are the Connect responses of every day and weigh-in
+// synced within the window from cutoff until today, leaving the last night
+// without sleep or HRV
+func wellnessResponses(cutoff time.Time) map[string]string {
+	responses := make(map[string]string)
+	today := time.Now().Format("2006-01-02")
+	for date := cutoff; !date.After(time.Now()); date = date.AddDate(0, 0, 1) {
+		d := date.Format("2006-01-02")
+		noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, time.UTC).UnixMilli()
+
+		responses["/modern/proxy/wellness-service/wellness/dailyHeartRate/runner?date="+d] = fmt.Sprintf(
+			`{"calendarDate":%q,"restingHeartRate":50,"heartRateValues":[[%d,60],[%d,62]]}`, d, noon, noon+120000)
+		responses["/modern/proxy/wellness-service/wellness/bodyBattery/reports/daily?startDate="+d+"&endDate="+d] = fmt.Sprintf(
+			`[{"date":%q,"bodyBatteryValuesArray":[[%d,80],[%d,78]]}]`, d, noon, noon+180000)
+		responses["/modern/proxy/wellness-service/wellness/dailyStress/"+d] = fmt.Sprintf(
+			`{"calendarDate":%q,"stressValuesArray":[[%d,25],[%d,-1]]}`, d, noon, noon+180000)
+		responses["/modern/proxy/wellness-service/wellness/daily/respiration/"+d] = fmt.Sprintf(
+			`{"calendarDate":%q,"respirationValuesArray":[[%d,14.5]]}`, d, noon)
+		responses["/modern/proxy/wellness-service/wellness/daily/spo2/"+d] = fmt.Sprintf(
+			`{"calendarDate":%q,"averageSpO2":96,"lowestSpO2":91}`, d)
+
+		if d == today {
+			responses["/modern/proxy/wellness-service/wellness/dailySleepData/runner?date="+d+"&nonSleepBufferMinutes=60"] = `{"dailySleepDTO":{}}`
+			responses["/modern/proxy/hrv-service/hrv/"+d] = `{"hrvSummary":{}}`
+			continue
+		}
+		responses["/modern/proxy/wellness-service/wellness/dailySleepData/runner?date="+d+"&nonSleepBufferMinutes=60"] = fmt.Sprintf(
+			`{"dailySleepDTO":{"calendarDate":%q,"sleepTimeSeconds":27000,"sleepStartTimestampGMT":%d,"sleepEndTimestampGMT":%d,"deepSleepSeconds":5400}}`,
+			d, noon-43200000, noon-16200000)
+		responses["/modern/proxy/hrv-service/hrv/"+d] = fmt.Sprintf(
+			`{"hrvSummary":{"calendarDate":%q,"lastNightAvg":48,"status":"BALANCED"}}`, d)
+	}
+	responses["/modern/proxy/weight-service/weight/dateRange?startDate="+cutoff.Format("2006-01-02")+"&endDate="+today] = fmt.Sprintf(
+		`{"dateWeightList":[{"calendarDate":%q,"timestampGMT":%d,"weight":70500,"bodyFat":18.5}]}`, today, time.Now().Add(-time.Hour).UnixMilli())
+	return responses
+}
+
+func TestReprocessReplaysSync(t *testing.T) {
+	recent := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	responses := syncResponses(recent, recent.AddDate(0, 0, -10))
+	// Only the synced activity, as reprocessing also stores archived activities outside the window
+	responses["/modern/proxy/activitylist-service/activities/search/activities?limit=100&start=0"] = fmt.Sprintf(
+		`[{"activityId":9000000002,"activityName":"Evening Run","activityTypeKey":"running","startTimeLocal":%q,"duration":3600,"distance":10200}]`,
+		recent.Format("2006-01-02 15:04:05"))
+	responses["/modern/proxy/activity-service/activity/9000000002/hrTimeInZones"] = `[{"zoneNumber":2,"secsInZone":1800,"zoneLowBoundary":120}]`
+	responses["/modern/proxy/activity-service/activity/9000000002/weather"] = `{"issueDate":"2024-03-04T18:00:00","temp":59,"windSpeed":10}`
+	responses["/modern/proxy/device-service/deviceregistration/devices"] = `[{"unitId":3900000001,"productDisplayName":"Forerunner 265"}]`
+	responses["/modern/proxy/workout-service/workouts?start=1&limit=999"] = `[{"workoutId":11}]`
+	responses["/modern/proxy/workout-service/workout/11"] = `{"workoutId":11,"workoutName":"Intervals","sportType":{"sportTypeKey":"running"}}`
+	responses["/modern/proxy/course-service/course"] = `[{"courseId":21}]`
+	responses["/modern/proxy/course-service/course/21"] = `{"courseId":21,"courseName":"Loop","distanceMeter":5000,"activityType":{"typeKey":"running"},` +
+		`"geoPoints":[{"latitude":52.0,"longitude":4.0},{"latitude":52.01,"longitude":4.0,"distance":1100}]}`
+	responses["/modern/proxy/personalrecord-service/personalrecord/prs/runner"] = `[{"typeId":3,"activityId":9000000002,"value":1500}]`
+	for uri, body := range wellnessResponses(time.Now().AddDate(0, 0, -1)) {
+		responses[uri] = body
+	}
+
+	gc, _ := newFakeConnect(t, responses)
+	synced := storage.NewMemoryStore()
+	gc.SetResponseRecorder(synced.SaveRawResponse)
+	if err := Sync(t.Context(), gc, synced, SyncOptions{DataPath: t.TempDir(), DownloadDays: 1}); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	reprocessed := storage.NewMemoryStore()
+	for endpoint, keys := range synced.Responses {
+		for key, body := range keys {
+			if err := reprocessed.SaveRawResponse(endpoint, key, body); err != nil {
+				t.Fatalf("SaveRawResponse: %v", err)
+			}
+		}
+	}
+	if err := Reprocess(reprocessed); err != nil {
+		t.Fatalf("Reprocess: %v", err)
+	}
+
+	// Every table a sync fills comes out the same from the archive alone
+	tables := []struct {
+		name           string
+		synced, replay interface{}
+	}{
+		{"activities", synced.Activities, reprocessed.Activities},
+		{"activity details", synced.ActivityDetails, reprocessed.ActivityDetails},
+		{"activity gear", synced.ActivityGear, reprocessed.ActivityGear},
+		{"daily stats", synced.DailyStats, reprocessed.DailyStats},
+		{"weight", synced.Weight, reprocessed.Weight},
+		{"sleep", synced.Sleep, reprocessed.Sleep},
+		{"heart rate", synced.HeartRate, reprocessed.HeartRate},
+		{"wellness", synced.Wellness, reprocessed.Wellness},
+		{"HRV", synced.HRV, reprocessed.HRV},
+		{"pulse ox", synced.PulseOx, reprocessed.PulseOx},
+		{"gear", synced.Gear, reprocessed.Gear},
+		{"devices", synced.Devices, reprocessed.Devices},
+		{"workouts", synced.Workouts, reprocessed.Workouts},
+		{"courses", synced.Courses, reprocessed.Courses},
+		{"personal records", synced.PRs, reprocessed.PRs},
+	}
+	// The daily values Connect's summary lacks come from the time series
+	for date, stats := range synced.DailyStats {
+		if stats.BodyBatteryAvg == nil || stats.StressMin == nil {
+			t.Errorf("%s has no Body Battery average or stress minimum", date)
+		} else if *stats.BodyBatteryAvg != 79 || *stats.StressMin != 25 {
+			t.Errorf("%s Body Battery average %d and stress minimum %d, want 79 and 25", date, *stats.BodyBatteryAvg, *stats.StressMin)
+		}
+	}
+	for _, table := range tables {
+		if reflect.ValueOf(table.synced).Len() == 0 {
+			t.Errorf("sync stored no %s", table.name)
+		}
+		if !reflect.DeepEqual(table.synced, table.replay) {
+			t.Errorf("reprocessed %s = %+v, want %+v", table.name, table.replay, table.synced)
+		}
+	}
+}
+
+func TestReprocessRejectsMalformedResponse(t *testing.T) {
+	store := storage.NewMemoryStore()
+	saveResponses(t, store, connect.EndpointActivities, map[string]string{"1": `{"activityId":`})
+	if err := Reprocess(store); err == nil {
+		t.Error("Reprocess accepted a truncated activity response")
+	}
+}
+
+func TestReprocessRejectsMalformedDetail(t *testing.T) {
+	store := storage.NewMemoryStore()
+	saveResponses(t, store, "/modern/proxy/hrv-service/hrv/2024-03-04", map[string]string{"": `{"hrvSummary":`})
+	if err := Reprocess(store); err == nil {
+		t.Error("Reprocess accepted a truncated HRV response")
+	}
+}
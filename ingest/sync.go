@@ -0,0 +1,536 @@
+// Package ingest fills a storage.Store from Garmin Connect and from FIT and
+// GPX files on disk.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+	"github.com/saram12saram2/gormin/connect"
+	"github.com/saram12saram2/gormin/storage"
+)
+
+// activityPageSize is the number of activities requested per page during sync
+const activityPageSize = 100
+
+// SyncOptions controls what Sync downloads
+type SyncOptions struct {
+	// DataPath is the directory FIT files are downloaded into
+	DataPath string
+	// DownloadDays is how many days back activities and daily data are synced
+	DownloadDays int
+	// RetainFiles downloads the FIT file of every synced activity
+	RetainFiles bool
+}
+
+// Sync logs in to Garmin Connect and downloads recent activities, daily
+// statistics and the user's library into store
+func Sync(ctx context.Context, gc *connect.GarminConnect, store storage.Store, opts SyncOptions) error {
+	if err := gc.Login(ctx); err != nil {
+		return err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -opts.DownloadDays)
+
+	if err := syncGear(ctx, gc, store); err != nil {
+		return fmt.Errorf("failed to sync gear: %w", err)
+	}
+	if err := syncDevices(ctx, gc, store); err != nil {
+		return fmt.Errorf("failed to sync devices: %w", err)
+	}
+	if err := syncWorkouts(ctx, gc, store); err != nil {
+		return fmt.Errorf("failed to sync workouts: %w", err)
+	}
+	if err := syncCourses(ctx, gc, store); err != nil {
+		return fmt.Errorf("failed to sync courses: %w", err)
+	}
+	if err := syncActivities(ctx, gc, store, cutoff, opts); err != nil {
+		return fmt.Errorf("failed to sync activities: %w", err)
+	}
+	if err := syncPersonalRecords(ctx, gc, store); err != nil {
+		return fmt.Errorf("failed to sync personal records: %w", err)
+	}
+	if err := syncDailyStats(ctx, gc, store, cutoff); err != nil {
+		return fmt.Errorf("failed to sync daily stats: %w", err)
+	}
+	if err := syncSleep(ctx, gc, store, cutoff); err != nil {
+		return fmt.Errorf("failed to sync sleep data: %w", err)
+	}
+	if err := syncHeartRate(ctx, gc, store, cutoff); err != nil {
+		return fmt.Errorf("failed to sync heart rate: %w", err)
+	}
+	if err := syncWeight(ctx, gc, store, cutoff); err != nil {
+		return fmt.Errorf("failed to sync weight: %w", err)
+	}
+	if err := syncWellness(ctx, gc, store, cutoff); err != nil {
+		return fmt.Errorf("failed to sync wellness data: %w", err)
+	}
+	if err := syncOvernightMetrics(ctx, gc, store, cutoff); err != nil {
+		return fmt.Errorf("failed to sync HRV and pulse ox: %w", err)
+	}
+
+	return nil
+}
+
+// syncActivities pages through the activity list until it reaches activities
+// older than cutoff, storing each one and downloading its FIT file
+func syncActivities(ctx context.Context, gc *connect.GarminConnect, store storage.Store, cutoff time.Time, opts SyncOptions) error {
+	for start := 0; ; start += activityPageSize {
+		var activities []gormin.Activity
+		err := gc.Retry(ctx, func() error {
+			var err error
+			activities, err = gc.GetActivities(ctx, activityPageSize, start)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if len(activities) == 0 {
+			return nil
+		}
+
+		for i := range activities {
+			activity := &activities[i]
+			startTime, err := time.ParseInLocation("2006-01-02 15:04:05", activity.StartTime, time.Local)
+			if err == nil && startTime.Before(cutoff) {
+				return nil
+			}
+
+			localID, err := store.SaveActivity(activity, gormin.SourceConnect)
+			if err != nil {
+				return err
+			}
+
+			if err := syncActivityDetails(ctx, gc, store, activity.ID, localID); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				fmt.Printf("Skipping details for activity %d: %v\n", activity.ID, err)
+			}
+			if err := syncActivityGear(ctx, gc, store, activity.ID, localID); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				fmt.Printf("Skipping gear for activity %d: %v\n", activity.ID, err)
+			}
+
+			if opts.RetainFiles {
+				if err := syncFitFile(ctx, gc, opts.DataPath, activity.ID); err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					fmt.Printf("Skipping FIT file for activity %d: %v\n", activity.ID, err)
+				}
+			}
+		}
+
+		if len(activities) < activityPageSize {
+			return nil
+		}
+	}
+}
+
+// syncActivityDetails downloads the splits, zones and weather of the activity
+// with Connect id activityID and stores them for its local id
+func syncActivityDetails(ctx context.Context, gc *connect.GarminConnect, store storage.Store, activityID, localID int) error {
+	var details *gormin.ActivityDetails
+	err := gc.Retry(ctx, func() error {
+		var err error
+		details, err = gc.GetActivityDetails(ctx, activityID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return store.SaveActivityDetails(localID, details)
+}
+
+// syncGear downloads and stores the gear of the user with its lifetime totals
+func syncGear(ctx context.Context, gc *connect.GarminConnect, store storage.Store) error {
+	var gear []gormin.Gear
+	err := gc.Retry(ctx, func() error {
+		var err error
+		gear, err = gc.GetGear(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range gear {
+		// Gear used before the sync window needs Connect's lifetime totals
+		err := gc.Retry(ctx, func() error {
+			return gc.GetGearStats(ctx, &gear[i])
+		})
+		if errors.Is(err, connect.ErrNotFound) {
+			fmt.Printf("No stats for gear %s, skipping\n", gear[i].Name)
+		} else if err != nil {
+			return err
+		}
+
+		if err := store.SaveGear(&gear[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncDevices downloads and stores the devices registered in Connect
+func syncDevices(ctx context.Context, gc *connect.GarminConnect, store storage.Store) error {
+	var devices []gormin.Device
+	err := gc.Retry(ctx, func() error {
+		var err error
+		devices, err = gc.GetDevices(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range devices {
+		if _, err := store.SaveDevice(&devices[i], ""); err != nil {
+			return err
+		}
+		fmt.Printf("Stored device: %s\n", devices[i].ProductName)
+	}
+
+	return nil
+}
+
+// syncWorkouts downloads and stores the workout library
+func syncWorkouts(ctx context.Context, gc *connect.GarminConnect, store storage.Store) error {
+	var workouts []gormin.Workout
+	err := gc.Retry(ctx, func() error {
+		var err error
+		workouts, err = gc.GetWorkouts(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range workouts {
+		if err := store.SaveWorkout(&workouts[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncCourses downloads and stores the saved courses
+func syncCourses(ctx context.Context, gc *connect.GarminConnect, store storage.Store) error {
+	var courses []gormin.Course
+	err := gc.Retry(ctx, func() error {
+		var err error
+		courses, err = gc.GetCourses(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range courses {
+		if err := store.SaveCourse(&courses[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncPersonalRecords downloads and stores the personal records from Connect
+func syncPersonalRecords(ctx context.Context, gc *connect.GarminConnect, store storage.Store) error {
+	var records []gormin.PersonalRecord
+	err := gc.Retry(ctx, func() error {
+		var err error
+		records, err = gc.GetPersonalRecords(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range records {
+		if err := store.SavePersonalRecord(&records[i]); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Stored %d personal records\n", len(records))
+
+	return nil
+}
+
+// syncActivityGear links the activity with Connect id activityID and local id
+// localID to the gear used for it
+func syncActivityGear(ctx context.Context, gc *connect.GarminConnect, store storage.Store, activityID, localID int) error {
+	var gear []gormin.Gear
+	err := gc.Retry(ctx, func() error {
+		var err error
+		gear, err = gc.GetActivityGear(ctx, activityID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, g := range gear {
+		if err := store.LinkActivityGear(localID, g.UUID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncFitFile downloads the FIT file of an activity into the data directory
+// unless it is already there
+func syncFitFile(ctx context.Context, gc *connect.GarminConnect, dataPath string, activityID int) error {
+	outputPath := filepath.Join(dataPath, fmt.Sprintf("activity_%d.fit", activityID))
+	if _, err := os.Stat(outputPath); err == nil {
+		return nil
+	}
+
+	return gc.Retry(ctx, func() error {
+		return gc.DownloadFitFile(ctx, activityID, outputPath)
+	})
+}
+
+// syncDailyStats downloads and stores daily statistics for each day since cutoff
+func syncDailyStats(ctx context.Context, gc *connect.GarminConnect, store storage.Store, cutoff time.Time) error {
+	for date := cutoff; !date.After(time.Now()); date = date.AddDate(0, 0, 1) {
+		// Stop at once when cancelled rather than reporting every remaining day as missing
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var stats *gormin.DailyStats
+		err := gc.Retry(ctx, func() error {
+			var err error
+			stats, err = gc.GetDailyStats(ctx, date)
+			return err
+		})
+		if errors.Is(err, connect.ErrNotFound) {
+			fmt.Printf("No daily stats for %s, skipping\n", date.Format("2006-01-02"))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := store.SaveDailyStats(stats); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncSleep downloads and stores sleep data for each night since cutoff
+func syncSleep(ctx context.Context, gc *connect.GarminConnect, store storage.Store, cutoff time.Time) error {
+	for date := cutoff; !date.After(time.Now()); date = date.AddDate(0, 0, 1) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var sleep *gormin.SleepData
+		err := gc.Retry(ctx, func() error {
+			var err error
+			sleep, err = gc.GetSleep(ctx, date)
+			return err
+		})
+		if errors.Is(err, connect.ErrNotFound) {
+			fmt.Printf("No sleep data for %s, skipping\n", date.Format("2006-01-02"))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := store.SaveSleep(sleep); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncHeartRate downloads and stores all-day heart rate for each day since cutoff
+func syncHeartRate(ctx context.Context, gc *connect.GarminConnect, store storage.Store, cutoff time.Time) error {
+	for date := cutoff; !date.After(time.Now()); date = date.AddDate(0, 0, 1) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var day *gormin.HeartRateDay
+		err := gc.Retry(ctx, func() error {
+			var err error
+			day, err = gc.GetHeartRate(ctx, date)
+			return err
+		})
+		if errors.Is(err, connect.ErrNotFound) {
+			fmt.Printf("No heart rate data for %s, skipping\n", date.Format("2006-01-02"))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := store.SaveHeartRate(day.Samples, nil); err != nil {
+			return err
+		}
+		if day.RestingHR > 0 {
+			if err := store.SaveRestingHeartRate(day.Date, day.RestingHR); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Stored heart rate: %s (%d samples, resting %d)\n", day.Date, len(day.Samples), day.RestingHR)
+	}
+
+	return nil
+}
+
+// syncWeight downloads and stores every weigh-in since cutoff
+func syncWeight(ctx context.Context, gc *connect.GarminConnect, store storage.Store, cutoff time.Time) error {
+	var entries []gormin.WeightEntry
+	err := gc.Retry(ctx, func() error {
+		var err error
+		entries, err = gc.GetWeight(ctx, cutoff, time.Now())
+		return err
+	})
+	if errors.Is(err, connect.ErrNotFound) {
+		fmt.Println("No weight data, skipping")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if err := store.SaveWeight(&entries[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncWellness downloads and stores Body Battery, stress and respiration time
+// series for each day since cutoff, along with the daily values derived from them
+func syncWellness(ctx context.Context, gc *connect.GarminConnect, store storage.Store, cutoff time.Time) error {
+	series := []struct {
+		table string
+		fetch func(context.Context, time.Time) ([]gormin.WellnessSample, error)
+	}{
+		{"body_battery", gc.GetBodyBattery},
+		{"stress", gc.GetStress},
+		{"respiration", gc.GetRespiration},
+	}
+
+	for date := cutoff; !date.After(time.Now()); date = date.AddDate(0, 0, 1) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		day := make(map[string][]gormin.WellnessSample)
+		for _, s := range series {
+			var samples []gormin.WellnessSample
+			err := gc.Retry(ctx, func() error {
+				var err error
+				samples, err = s.fetch(ctx, date)
+				return err
+			})
+			if errors.Is(err, connect.ErrNotFound) {
+				fmt.Printf("No %s data for %s, skipping\n", s.table, date.Format("2006-01-02"))
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := store.SaveWellnessSamples(s.table, samples); err != nil {
+				return err
+			}
+			fmt.Printf("Stored %s: %s (%d samples)\n", s.table, date.Format("2006-01-02"), len(samples))
+			day[s.table] = samples
+		}
+
+		bodyBatteryAvg, stressMin := dailyWellness(day["body_battery"], day["stress"])
+		if err := store.SaveDailyWellness(date.Format("2006-01-02"), bodyBatteryAvg, stressMin); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dailyWellness returns the average Body Battery and minimum stress level of
+// a day's samples, which Connect's daily summary lacks. Either is nil without
+// samples, so a series that was not fetched keeps its stored value.
+func dailyWellness(bodyBattery, stress []gormin.WellnessSample) (bodyBatteryAvg, stressMin *int) {
+	if len(bodyBattery) > 0 {
+		sum := 0.0
+		for _, sample := range bodyBattery {
+			sum += sample.Value
+		}
+		avg := int(math.Round(sum / float64(len(bodyBattery))))
+		bodyBatteryAvg = &avg
+	}
+	for _, sample := range stress {
+		if value := int(sample.Value); stressMin == nil || value < *stressMin {
+			stressMin = &value
+		}
+	}
+	return bodyBatteryAvg, stressMin
+}
+
+// syncOvernightMetrics downloads and stores HRV status and pulse ox for each
+// night since cutoff
+func syncOvernightMetrics(ctx context.Context, gc *connect.GarminConnect, store storage.Store, cutoff time.Time) error {
+	for date := cutoff; !date.After(time.Now()); date = date.AddDate(0, 0, 1) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		dateStr := date.Format("2006-01-02")
+
+		var hrv *gormin.HRVStatus
+		err := gc.Retry(ctx, func() error {
+			var err error
+			hrv, err = gc.GetHRVStatus(ctx, date)
+			return err
+		})
+		switch {
+		case errors.Is(err, connect.ErrNotFound):
+			fmt.Printf("No HRV status for %s, skipping\n", dateStr)
+		case err != nil:
+			return err
+		default:
+			if err := store.SaveHRVStatus(hrv); err != nil {
+				return err
+			}
+		}
+
+		var spo2 *gormin.PulseOx
+		err = gc.Retry(ctx, func() error {
+			var err error
+			spo2, err = gc.GetPulseOx(ctx, date)
+			return err
+		})
+		switch {
+		case errors.Is(err, connect.ErrNotFound):
+			fmt.Printf("No pulse ox data for %s, skipping\n", dateStr)
+		case err != nil:
+			return err
+		default:
+			if err := store.SavePulseOx(spo2); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,348 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+	"github.com/saram12saram2/gormin/connect"
+	"github.com/saram12saram2/gormin/storage"
+)
+
+// dailyStatsPath is the path daily statistics are requested under, followed by the date
+const dailyStatsPath = "/modern/proxy/userstats-service/wellness/daily/"
+
+// fakeConnect serves canned Connect responses by request path and query; an
+// empty response is served as 204 No Content. Logging in always succeeds,
+// daily statistics are served for every day and any other request is not
+// found.
+type fakeConnect struct {
+	mu        sync.Mutex
+	responses map[string][]byte
+	requests  []string
+	// onRequest, if set, is called with the path and query of every request
+	onRequest func(uri string)
+}
+
+func (f *fakeConnect) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.RequestURI())
+	if f.onRequest != nil {
+		f.onRequest(r.URL.RequestURI())
+	}
+
+	switch {
+	case r.URL.Path == "/sso/signin" && r.Method == http.MethodGet:
+		fmt.Fprint(w, `<form><input type="hidden" name="_csrf" value="token"></form>`)
+		return
+	case r.URL.Path == "/sso/signin":
+		fmt.Fprint(w, `<script>var response_url = "https://connect.garmin.com/modern/?ticket=ST-1";</script>`)
+		return
+	case strings.HasPrefix(r.URL.Path, dailyStatsPath):
+		date := strings.TrimPrefix(r.URL.Path, dailyStatsPath)
+		fmt.Fprintf(w, `{"calendarDate":%q,"totalSteps":8000,"restingHeartRate":52}`, date)
+		return
+	}
+
+	body, ok := f.responses[r.URL.RequestURI()]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if len(body) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Write(body)
+}
+
+// rewriteTransport sends every request to the test server, whatever its host
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (rt rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newFakeConnect starts a fake Connect server and returns a client talking to it
+func newFakeConnect(t *testing.T, responses map[string]string) (*connect.GarminConnect, *fakeConnect) {
+	t.Helper()
+	fake := &fakeConnect{responses: make(map[string][]byte)}
+	for uri, body := range responses {
+		fake.responses[uri] = []byte(body)
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+	jar, _ := cookiejar.New(nil)
+	gc := connect.NewGarminConnect("user@example.com", "secret")
+	gc.SetHTTPClient(&http.Client{Jar: jar, Transport: rewriteTransport{target}, Timeout: 10 * time.Second})
+	gc.SetRequestsPerMinute(60000)
+	gc.SetMaxRetries(0)
+	return gc, fake
+}
+
+// syncResponses are the Connect responses of an account with one recent and
+// one older run, a pair of shoes and an empty library
+func syncResponses(recent, old time.Time) map[string]string {
+	activity := func(id int, name string, start time.Time) string {
+		return fmt.Sprintf(`{"activityId":%d,"activityName":%q,"activityTypeKey":"running",`+
+			`"startTimeLocal":%q,"duration":3600,"distance":10200,"averageHR":150,"maxHR":171}`,
+			id, name, start.Format("2006-01-02 15:04:05"))
+	}
+	return map[string]string{
+		"/modern/proxy/userprofile-service/socialProfile":                `{"displayName":"runner","profileId":42}`,
+		"/modern/proxy/gear-service/gear/filterGear?userProfilePk=42":    `[{"uuid":"shoe-1","displayName":"Pegasus","gearTypeName":"Shoes","gearStatusName":"active"}]`,
+		"/modern/proxy/gear-service/gear/stats/shoe-1":                   `{"uuid":"shoe-1","totalDistance":612400,"totalActivities":58}`,
+		"/modern/proxy/device-service/deviceregistration/devices":        `[]`,
+		"/modern/proxy/workout-service/workouts?start=1&limit=999":       `[]`,
+		"/modern/proxy/course-service/course":                            `[]`,
+		"/modern/proxy/personalrecord-service/personalrecord/prs/runner": `[]`,
+		"/modern/proxy/activitylist-service/activities/search/activities?limit=100&start=0": "[" +
+			activity(9000000002, "Evening Run", recent) + "," + activity(9000000001, "Old Run", old) + "]",
+		"/modern/proxy/activity-service/activity/9000000002": `{"activityId":9000000002,"description":"Easy run",` +
+			`"accessControlRuleDTO":{"typeKey":"private"},"summaryDTO":{"movingDuration":3500,"elevationLoss":40,"averageSpeed":2.8}}`,
+		"/modern/proxy/activity-service/activity/9000000002/splits": `{"lapDTOs":[{"lapIndex":1,"startTimeGMT":"` +
+			recent.UTC().Format("2006-01-02T15:04:05") + `","duration":3600,"distance":10200}]}`,
+		"/modern/proxy/gear-service/gear/filterGear?activityId=9000000002": `[{"uuid":"shoe-1","displayName":"Pegasus","gearTypeName":"Shoes"}]`,
+	}
+}
+
+func TestSync(t *testing.T) {
+	recent := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	old := time.Now().AddDate(0, 0, -10)
+	gc, fake := newFakeConnect(t, syncResponses(recent, old))
+	store := storage.NewMemoryStore()
+	gc.SetResponseRecorder(store.SaveRawResponse)
+
+	// A FIT import of the recent run, before it was synced
+	fitID, err := store.SaveActivity(&gormin.Activity{
+		Name: "FIT running", Type: "running", StartTime: recent.Format("2006-01-02 15:04:05"),
+		Duration: 3590, Distance: 10.1, AvgHR: 148,
+	}, gormin.SourceFit)
+	if err != nil {
+		t.Fatalf("SaveActivity: %v", err)
+	}
+
+	err = Sync(t.Context(), gc, store, SyncOptions{DataPath: t.TempDir(), DownloadDays: 1})
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	// Only the activity within the window is synced, onto the FIT import
+	if len(store.Activities) != 1 {
+		t.Fatalf("%d activities, want 1", len(store.Activities))
+	}
+	activity := store.Activities[fitID]
+	if activity == nil || activity.ID != 9000000002 || activity.Name != "Evening Run" {
+		t.Fatalf("activity %d = %+v, want the synced Evening Run", fitID, activity)
+	}
+	if activity.Duration != 3600 || activity.Distance != 10.2 || activity.AvgHR != 150 {
+		t.Errorf("activity totals = %d s, %.1f km, %d bpm; want Connect's 3600 s, 10.2 km, 150 bpm",
+			activity.Duration, activity.Distance, activity.AvgHR)
+	}
+
+	// Details and gear are keyed by the local id
+	details, ok := store.ActivityDetails[fitID]
+	if !ok {
+		t.Fatalf("no details for local activity %d", fitID)
+	}
+	if details.Description != "Easy run" || len(details.Splits) != 1 {
+		t.Errorf("details = %q with %d splits, want %q with 1", details.Description, len(details.Splits), "Easy run")
+	}
+	if gear := store.ActivityGear[fitID]; len(gear) != 1 || gear[0] != "shoe-1" {
+		t.Errorf("activity gear = %v, want [shoe-1]", gear)
+	}
+	// Gear totals cover its whole history, not only the synced window
+	if gear := store.Gear["shoe-1"]; gear.TotalActivities != 58 || gear.TotalDistance != 612.4 {
+		t.Errorf("gear totals = %d activities, %.1f km; want 58, 612.4 km", gear.TotalActivities, gear.TotalDistance)
+	}
+
+	// Every day in the window has daily statistics, and missing wellness data is skipped
+	if len(store.DailyStats) != 2 {
+		t.Errorf("%d days of daily stats, want 2", len(store.DailyStats))
+	}
+	for date, stats := range store.DailyStats {
+		if stats.Date != date || stats.Steps != 8000 {
+			t.Errorf("daily stats for %s = %+v", date, stats)
+		}
+	}
+
+	// Responses are archived for reprocessing
+	if _, ok := store.Responses[connect.EndpointActivities]["9000000002"]; !ok {
+		t.Error("activity response not recorded")
+	}
+	if len(store.Responses[connect.EndpointDailyStats]) != 2 {
+		t.Errorf("%d daily stats responses recorded, want 2", len(store.Responses[connect.EndpointDailyStats]))
+	}
+
+	for _, request := range fake.requests {
+		if strings.Contains(request, "/activity/9000000001") {
+			t.Errorf("details requested for the activity outside the window: %s", request)
+		}
+	}
+}
+
+func TestSyncRetainsFitFiles(t *testing.T) {
+	recent := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	responses := syncResponses(recent, time.Now().AddDate(0, 0, -10))
+	responses["/modern/proxy/download-service/files/activity/9000000002"] = string(activityFit(t, recent, 300))
+	gc, _ := newFakeConnect(t, responses)
+	store := storage.NewMemoryStore()
+
+	dir := t.TempDir()
+	if err := Sync(t.Context(), gc, store, SyncOptions{DataPath: dir, DownloadDays: 1, RetainFiles: true}); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "activity_9000000002.fit" {
+		t.Fatalf("data directory holds %v, want only activity_9000000002.fit", entries)
+	}
+
+	// Processing the download adds its records to the synced activity
+	if err := NewFitProcessor(dir, store).ProcessFitFiles(); err != nil {
+		t.Fatalf("ProcessFitFiles: %v", err)
+	}
+	if len(store.Activities) != 1 {
+		t.Fatalf("%d activities, want 1", len(store.Activities))
+	}
+	for localID, activity := range store.Activities {
+		if activity.ID != 9000000002 {
+			t.Errorf("activity Connect id = %d, want 9000000002", activity.ID)
+		}
+		if n := len(store.Records[localID]); n != 301 {
+			t.Errorf("%d records, want 301", n)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "activity_9000000001.fit")); err == nil {
+		t.Error("FIT file downloaded for the activity outside the window")
+	}
+}
+
+// requested returns the requests made to the fake Connect server containing s
+func (f *fakeConnect) requested(s string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var matches []string
+	for _, request := range f.requests {
+		if strings.Contains(request, s) {
+			matches = append(matches, request)
+		}
+	}
+	return matches
+}
+
+func TestSyncStopsWhenCanceled(t *testing.T) {
+	tests := []struct {
+		name string
+		// cancelOn is the request during which the sync is cancelled
+		cancelOn string
+		// notRequested is a later request the sync must not make
+		notRequested string
+		// activities is the number of activities stored before stopping
+		activities int
+	}{
+		{"activity details", "/activity-service/activity/9000000002", "/activity-service/activity/9000000001", 1},
+		{"daily loop", dailyStatsPath, "dailySleepData", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recent := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+			// Both activities are within the window
+			gc, fake := newFakeConnect(t, syncResponses(recent, recent.Add(-time.Hour)))
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+			fake.onRequest = func(uri string) {
+				if strings.Contains(uri, tt.cancelOn) {
+					cancel()
+				}
+			}
+
+			store := storage.NewMemoryStore()
+			err := Sync(ctx, gc, store, SyncOptions{DataPath: t.TempDir(), DownloadDays: 5})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("Sync error = %v, want context.Canceled", err)
+			}
+			if n := len(store.Activities); n != tt.activities {
+				t.Errorf("%d activities stored, want %d", n, tt.activities)
+			}
+			if n := len(fake.requested(tt.cancelOn)); n != 1 {
+				t.Errorf("%d requests for %s after cancelling, want 1", n, tt.cancelOn)
+			}
+			if requests := fake.requested(tt.notRequested); len(requests) != 0 {
+				t.Errorf("sync went on after cancelling: %v", requests)
+			}
+		})
+	}
+}
+
+func TestSyncSkipsNoContent(t *testing.T) {
+	recent := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	responses := syncResponses(recent, recent.AddDate(0, 0, -10))
+	cutoff := time.Now().AddDate(0, 0, -1)
+	for uri, body := range wellnessResponses(cutoff) {
+		responses[uri] = body
+	}
+	// Connect answers 204 No Content for days and gear without data
+	today := time.Now().Format("2006-01-02")
+	for _, uri := range []string{
+		"/modern/proxy/gear-service/gear/stats/shoe-1",
+		"/modern/proxy/wellness-service/wellness/dailyHeartRate/runner?date=" + today,
+		"/modern/proxy/wellness-service/wellness/dailySleepData/runner?date=" + today + "&nonSleepBufferMinutes=60",
+		"/modern/proxy/wellness-service/wellness/bodyBattery/reports/daily?startDate=" + today + "&endDate=" + today,
+		"/modern/proxy/wellness-service/wellness/dailyStress/" + today,
+		"/modern/proxy/wellness-service/wellness/daily/respiration/" + today,
+		"/modern/proxy/wellness-service/wellness/daily/spo2/" + today,
+		"/modern/proxy/hrv-service/hrv/" + today,
+		"/modern/proxy/weight-service/weight/dateRange?startDate=" + cutoff.Format("2006-01-02") + "&endDate=" + today,
+	} {
+		if _, ok := responses[uri]; !ok {
+			t.Fatalf("no response to replace for %s", uri)
+		}
+		responses[uri] = ""
+	}
+	gc, _ := newFakeConnect(t, responses)
+	store := storage.NewMemoryStore()
+
+	if err := Sync(t.Context(), gc, store, SyncOptions{DataPath: t.TempDir(), DownloadDays: 1}); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	// The other day is stored as usual
+	yesterday := cutoff.Format("2006-01-02")
+	if _, ok := store.PulseOx[yesterday]; !ok {
+		t.Errorf("no pulse ox for %s", yesterday)
+	}
+	if _, ok := store.PulseOx[today]; ok {
+		t.Errorf("pulse ox stored for %s without content", today)
+	}
+	if len(store.Wellness["stress"]) != 1 {
+		t.Errorf("%d stress samples, want the 1 of %s", len(store.Wellness["stress"]), yesterday)
+	}
+	if gear := store.Gear["shoe-1"]; gear.Name != "Pegasus" || gear.TotalActivities != 0 {
+		t.Errorf("gear without stats = %+v", gear)
+	}
+}
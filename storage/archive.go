@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// compress gzips a response body for the archive
+func compress(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress reverses compress
+func decompress(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// SaveRawResponse archives a Connect response body compressed, replacing the
+// previous response with the same endpoint and key
+func (s *SQLStore) SaveRawResponse(endpoint, key string, body []byte) error {
+	data, err := compress(body)
+	if err != nil {
+		return fmt.Errorf("failed to compress response: %w", err)
+	}
+
+	_, err = s.db.Exec(s.rebind(`INSERT INTO raw_responses (endpoint, key, fetched_at, body)
+		VALUES (?, ?, CURRENT_TIMESTAMP, ?)
+		ON CONFLICT(endpoint, key) DO UPDATE SET
+			fetched_at = excluded.fetched_at,
+			body = excluded.body`),
+		endpoint, key, data)
+	if err != nil {
+		return fmt.Errorf("failed to store response: %w", err)
+	}
+	return nil
+}
+
+// RawResponses returns the archived responses of an endpoint ordered by key.
+// They are read in full before returning, so callers may write to the store
+// while going through them.
+func (s *SQLStore) RawResponses(endpoint string) ([]gormin.RawResponse, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT key, fetched_at, body FROM raw_responses
+		WHERE endpoint = ? ORDER BY key`), endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query responses: %w", err)
+	}
+	defer rows.Close()
+
+	var responses []gormin.RawResponse
+	for rows.Next() {
+		response := gormin.RawResponse{Endpoint: endpoint}
+		var data []byte
+		if err := rows.Scan(&response.Key, &response.FetchedAt, &data); err != nil {
+			return nil, fmt.Errorf("failed to read responses: %w", err)
+		}
+		if response.Body, err = decompress(data); err != nil {
+			return nil, fmt.Errorf("failed to decompress %s response %s: %w", endpoint, response.Key, err)
+		}
+		responses = append(responses, response)
+	}
+	return responses, rows.Err()
+}
+
+// RawResponseEndpoints returns the endpoints with archived responses, sorted
+func (s *SQLStore) RawResponseEndpoints() ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT endpoint FROM raw_responses ORDER BY endpoint`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query response endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var endpoints []string
+	for rows.Next() {
+		var endpoint string
+		if err := rows.Scan(&endpoint); err != nil {
+			return nil, fmt.Errorf("failed to read response endpoints: %w", err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// errSQLiteOnly is returned by maintenance operations PostgreSQL handles with
+// its own tools
+var errSQLiteOnly = errors.New("only supported for SQLite databases")
+
+// Backup copies a SQLite database to a new file at path with SQLite's online
+// backup API, so the copy is consistent even while the database is in use.
+// An existing file at path is never overwritten.
+func Backup(ctx context.Context, db *sql.DB, path string) error {
+	if driverName(db) != DriverSQLite {
+		return fmt.Errorf("backup is %w; use pg_dump for PostgreSQL", errSQLiteOnly)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file %s already exists", path)
+	}
+
+	dest, err := sql.Open(DriverSQLite, path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer dest.Close()
+
+	srcConn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer srcConn.Close()
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer destConn.Close()
+
+	err = destConn.Raw(func(destDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			backup, err := destDriverConn.(*sqlite3.SQLiteConn).Backup("main", srcDriverConn.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			// Copy all pages in one step, holding a read lock on the source
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// DatabaseFile returns the absolute path of the file holding a SQLite
+// database, as SQLite resolved it from the DSN. In-memory and temporary
+// databases have no file and return an error.
+func DatabaseFile(db *sql.DB) (string, error) {
+	if driverName(db) != DriverSQLite {
+		return "", fmt.Errorf("database file is %w", errSQLiteOnly)
+	}
+
+	rows, err := db.Query(`PRAGMA database_list`)
+	if err != nil {
+		return "", fmt.Errorf("failed to list databases: %w", err)
+	}
+	defer rows.Close()
+
+	var path string
+	for rows.Next() {
+		var (
+			seq  int
+			name string
+			file sql.NullString
+		)
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return "", fmt.Errorf("failed to read database list: %w", err)
+		}
+		if name == "main" {
+			path = file.String
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to read database list: %w", err)
+	}
+	if path == "" {
+		return "", fmt.Errorf("database is not stored in a file")
+	}
+	return path, nil
+}
+
+// Check runs SQLite's integrity and foreign key checks and returns every
+// problem found; a healthy database returns none
+func Check(db *sql.DB) ([]string, error) {
+	if driverName(db) != DriverSQLite {
+		return nil, fmt.Errorf("check is %w", errSQLiteOnly)
+	}
+
+	var problems []string
+	rows, err := db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read integrity check: %w", err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read integrity check: %w", err)
+	}
+
+	rows, err = db.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run foreign key check: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			table, parent string
+			rowID         sql.NullInt64
+			fkID          int
+		)
+		if err := rows.Scan(&table, &rowID, &parent, &fkID); err != nil {
+			return nil, fmt.Errorf("failed to read foreign key check: %w", err)
+		}
+		problems = append(problems, fmt.Sprintf("%s row %d references a missing %s row",
+			table, rowID.Int64, parent))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read foreign key check: %w", err)
+	}
+	return problems, nil
+}
+
+// Vacuum rebuilds the database to reclaim the space of deleted rows
+func Vacuum(db *sql.DB) error {
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	return nil
+}
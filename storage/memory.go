@@ -0,0 +1,621 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// MemoryStore is a Store keeping everything in memory, for tests and dry
+// runs. Its fields may be inspected directly once no other goroutine is
+// using the store.
+type MemoryStore struct {
+	mu sync.Mutex
+
+	Activities      map[int]*gormin.Activity // by local id; ID holds the Connect id
+	ActivityDetails map[int]gormin.ActivityDetails
+	ActivityGear    map[int][]string
+	Records         map[int][]gormin.ActivityRecord
+	ActivityDevices map[int][]gormin.Device
+	BestEfforts     map[int][]gormin.BestEffort
+
+	DailyStats map[string]gormin.DailyStats
+	Weight     map[string]gormin.WeightEntry // by timestamp
+	Sleep      map[string]gormin.SleepData
+	HeartRate  map[int][]gormin.HeartRateSample // by local activity id, 0 for all-day
+	Wellness   map[string][]gormin.WellnessSample
+	HRV        map[string]gormin.HRVStatus
+	PulseOx    map[string]gormin.PulseOx
+
+	Gear      map[string]gormin.Gear
+	Devices   []gormin.Device
+	Workouts  map[int]gormin.Workout
+	Courses   map[int]gormin.Course
+	PRs       map[string]map[string]gormin.PersonalRecord // by source and record type
+	Responses map[string]map[string][]byte                // by endpoint and key, uncompressed
+	nextLocal int
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		Activities:      make(map[int]*gormin.Activity),
+		ActivityDetails: make(map[int]gormin.ActivityDetails),
+		ActivityGear:    make(map[int][]string),
+		Records:         make(map[int][]gormin.ActivityRecord),
+		ActivityDevices: make(map[int][]gormin.Device),
+		BestEfforts:     make(map[int][]gormin.BestEffort),
+		DailyStats:      make(map[string]gormin.DailyStats),
+		Weight:          make(map[string]gormin.WeightEntry),
+		Sleep:           make(map[string]gormin.SleepData),
+		HeartRate:       make(map[int][]gormin.HeartRateSample),
+		Wellness:        make(map[string][]gormin.WellnessSample),
+		HRV:             make(map[string]gormin.HRVStatus),
+		PulseOx:         make(map[string]gormin.PulseOx),
+		Gear:            make(map[string]gormin.Gear),
+		Workouts:        make(map[int]gormin.Workout),
+		Courses:         make(map[int]gormin.Course),
+		PRs:             make(map[string]map[string]gormin.PersonalRecord),
+		Responses:       make(map[string]map[string][]byte),
+	}
+}
+
+// SaveActivity upserts an activity on its Connect id or start time, matching
+// rows and merging Connect and FIT data with the same precedence as the SQL store
+func (m *MemoryStore) SaveActivity(activity *gormin.Activity, source string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Visit rows in local id order, like the SQL store's ORDER BY id
+	ids := make([]int, 0, len(m.Activities))
+	for id := range m.Activities {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	localID := 0
+	if activity.ID != 0 {
+		// The row with the Connect id, or else the oldest FIT-only row starting at the same time
+		for _, id := range ids {
+			if m.Activities[id].ID == activity.ID {
+				localID = id
+				break
+			}
+		}
+		for _, id := range ids {
+			if a := m.Activities[id]; localID == 0 && a.ID == 0 && a.StartTime == activity.StartTime {
+				localID = id
+			}
+		}
+	} else {
+		// The oldest row starting at the same time, preferring one with a Connect id
+		for _, id := range ids {
+			a := m.Activities[id]
+			if a.StartTime != activity.StartTime {
+				continue
+			}
+			if a.ID != 0 {
+				localID = id
+				break
+			}
+			if localID == 0 {
+				localID = id
+			}
+		}
+	}
+
+	if localID == 0 {
+		m.nextLocal++
+		localID = m.nextLocal
+		stored := *activity
+		m.Activities[localID] = &stored
+		return localID, nil
+	}
+
+	stored := m.Activities[localID]
+	if stored.ID == 0 {
+		stored.ID = activity.ID
+	}
+	fill := func(dst *int, v int, overwrite bool) {
+		if v != 0 && (overwrite || *dst == 0) {
+			*dst = v
+		}
+	}
+	fromConnect := source != gormin.SourceFit
+	if fromConnect {
+		stored.Name, stored.Type, stored.StartTime = activity.Name, activity.Type, activity.StartTime
+	}
+	fill(&stored.Duration, activity.Duration, fromConnect)
+	fill(&stored.Calories, activity.Calories, fromConnect)
+	fill(&stored.AvgHR, activity.AvgHR, fromConnect)
+	fill(&stored.MaxHR, activity.MaxHR, fromConnect)
+	fill(&stored.ElevationGain, activity.ElevationGain, fromConnect)
+	if activity.Distance != 0 && (fromConnect || stored.Distance == 0) {
+		stored.Distance = activity.Distance
+	}
+	return localID, nil
+}
+
+// SaveActivityDetails stores the details of an activity
+func (m *MemoryStore) SaveActivityDetails(activityID int, details *gormin.ActivityDetails) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ActivityDetails[activityID] = *details
+	return nil
+}
+
+// LinkActivityGear links gear to an activity once
+func (m *MemoryStore) LinkActivityGear(activityID int, gearUUID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, uuid := range m.ActivityGear[activityID] {
+		if uuid == gearUUID {
+			return nil
+		}
+	}
+	m.ActivityGear[activityID] = append(m.ActivityGear[activityID], gearUUID)
+	return nil
+}
+
+// SaveActivityRecords replaces the records of an activity
+func (m *MemoryStore) SaveActivityRecords(activityID int, records []gormin.ActivityRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Records[activityID] = append([]gormin.ActivityRecord(nil), records...)
+	return nil
+}
+
+// ActivityRecords returns a copy of the records of an activity
+func (m *MemoryStore) ActivityRecords(activityID int) ([]gormin.ActivityRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]gormin.ActivityRecord(nil), m.Records[activityID]...), nil
+}
+
+// ActivitiesWithRecords returns the activities with records, oldest first
+func (m *MemoryStore) ActivitiesWithRecords() ([]gormin.Activity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var activities []gormin.Activity
+	for id := range m.Records {
+		if a, ok := m.Activities[id]; ok {
+			activities = append(activities, gormin.Activity{ID: id, Type: a.Type, StartTime: a.StartTime})
+		}
+	}
+	sort.Slice(activities, func(i, j int) bool { return activities[i].StartTime < activities[j].StartTime })
+	return activities, nil
+}
+
+// SaveActivityDevice stores a device and links it to an activity
+func (m *MemoryStore) SaveActivityDevice(activityID int, device *gormin.Device, startTime string) error {
+	if _, err := m.SaveDevice(device, startTime); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ActivityDevices[activityID] = append(m.ActivityDevices[activityID], *device)
+	return nil
+}
+
+// SaveBestEfforts replaces the best efforts of an activity
+func (m *MemoryStore) SaveBestEfforts(activityID int, efforts []gormin.BestEffort) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.BestEfforts[activityID] = append([]gormin.BestEffort(nil), efforts...)
+	return nil
+}
+
+// SaveDailyStats replaces the statistics of a day
+func (m *MemoryStore) SaveDailyStats(stats *gormin.DailyStats) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := *stats
+	// Keep the values derived from the time series unless stats has its own
+	if previous, ok := m.DailyStats[stats.Date]; ok {
+		if saved.BodyBatteryAvg == nil {
+			saved.BodyBatteryAvg = previous.BodyBatteryAvg
+		}
+		if saved.StressMin == nil {
+			saved.StressMin = previous.StressMin
+		}
+	}
+	m.DailyStats[stats.Date] = saved
+	return nil
+}
+
+// SaveRestingHeartRate sets the resting heart rate of a stored day
+func (m *MemoryStore) SaveRestingHeartRate(date string, restingHR int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stats, ok := m.DailyStats[date]; ok {
+		stats.RestingHR = restingHR
+		m.DailyStats[date] = stats
+	}
+	return nil
+}
+
+// SaveDailyWellness sets the Body Battery average and stress minimum of a stored day
+func (m *MemoryStore) SaveDailyWellness(date string, bodyBatteryAvg, stressMin *int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stats, ok := m.DailyStats[date]; ok {
+		if bodyBatteryAvg != nil {
+			stats.BodyBatteryAvg = bodyBatteryAvg
+		}
+		if stressMin != nil {
+			stats.StressMin = stressMin
+		}
+		m.DailyStats[date] = stats
+	}
+	return nil
+}
+
+// SaveWeight replaces the weigh-in with the same timestamp
+func (m *MemoryStore) SaveWeight(entry *gormin.WeightEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Weight[entry.Timestamp] = *entry
+	return nil
+}
+
+// SaveSleep replaces the sleep data of a night
+func (m *MemoryStore) SaveSleep(sleep *gormin.SleepData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Sleep[sleep.Date] = *sleep
+	return nil
+}
+
+// SaveHeartRate adds the samples not yet stored for the activity or day
+func (m *MemoryStore) SaveHeartRate(samples []gormin.HeartRateSample, activityID *int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := 0
+	if activityID != nil {
+		key = *activityID
+	}
+	seen := make(map[string]bool)
+	for _, sample := range m.HeartRate[key] {
+		seen[sample.Timestamp] = true
+	}
+	for _, sample := range samples {
+		if !seen[sample.Timestamp] {
+			seen[sample.Timestamp] = true
+			m.HeartRate[key] = append(m.HeartRate[key], sample)
+		}
+	}
+	return nil
+}
+
+// SaveWellnessSamples appends samples to a wellness series
+func (m *MemoryStore) SaveWellnessSamples(table string, samples []gormin.WellnessSample) error {
+	switch table {
+	case "body_battery", "stress", "respiration":
+	default:
+		return fmt.Errorf("unknown wellness series %q", table)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Wellness[table] = append(m.Wellness[table], samples...)
+	return nil
+}
+
+// SaveHRVStatus replaces the HRV status of a night
+func (m *MemoryStore) SaveHRVStatus(hrv *gormin.HRVStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.HRV[hrv.Date] = *hrv
+	return nil
+}
+
+// SavePulseOx replaces the pulse ox summary of a night
+func (m *MemoryStore) SavePulseOx(spo2 *gormin.PulseOx) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.PulseOx[spo2.Date] = *spo2
+	return nil
+}
+
+// SaveGear replaces the gear item with the same uuid
+func (m *MemoryStore) SaveGear(gear *gormin.Gear) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Gear[gear.UUID] = *gear
+	return nil
+}
+
+// SaveDevice stores a device matched by serial number, or by manufacturer
+// and product when the serial is unknown, and returns its index plus one
+func (m *MemoryStore) SaveDevice(device *gormin.Device, lastSeen string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, d := range m.Devices {
+		if (device.SerialNumber != "" && d.SerialNumber == device.SerialNumber) ||
+			(device.SerialNumber == "" && d.SerialNumber == "" &&
+				d.Manufacturer == device.Manufacturer && d.Product == device.Product) {
+			m.Devices[i] = *device
+			return int64(i + 1), nil
+		}
+	}
+	m.Devices = append(m.Devices, *device)
+	return int64(len(m.Devices)), nil
+}
+
+// SaveWorkout replaces the workout with the same id
+func (m *MemoryStore) SaveWorkout(workout *gormin.Workout) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Workouts[workout.ID] = *workout
+	return nil
+}
+
+// Workout returns a stored workout
+func (m *MemoryStore) Workout(workoutID int) (*gormin.Workout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	workout, ok := m.Workouts[workoutID]
+	if !ok {
+		return nil, fmt.Errorf("workout %d not found", workoutID)
+	}
+	return &workout, nil
+}
+
+// ListWorkouts returns the stored workouts ordered by name
+func (m *MemoryStore) ListWorkouts() ([]gormin.Workout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	workouts := make([]gormin.Workout, 0, len(m.Workouts))
+	for _, workout := range m.Workouts {
+		workouts = append(workouts, workout)
+	}
+	sort.Slice(workouts, func(i, j int) bool { return workouts[i].Name < workouts[j].Name })
+	return workouts, nil
+}
+
+// SaveCourse stores a course, replacing one with the same Connect id or file
+// path, and sets its local id
+func (m *MemoryStore) SaveCourse(course *gormin.Course) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if course.ID == 0 {
+		for id, c := range m.Courses {
+			if (course.GarminID != 0 && c.GarminID == course.GarminID) ||
+				(course.FilePath != "" && c.FilePath == course.FilePath) {
+				course.ID = id
+				break
+			}
+		}
+	}
+	if course.ID == 0 {
+		course.ID = len(m.Courses) + 1
+	}
+	m.Courses[course.ID] = *course
+	return nil
+}
+
+// Course returns a stored course
+func (m *MemoryStore) Course(courseID int) (*gormin.Course, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	course, ok := m.Courses[courseID]
+	if !ok {
+		return nil, fmt.Errorf("course %d not found", courseID)
+	}
+	return &course, nil
+}
+
+// ListCourses returns the stored courses without their points, ordered by name
+func (m *MemoryStore) ListCourses() ([]gormin.Course, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	courses := make([]gormin.Course, 0, len(m.Courses))
+	for _, course := range m.Courses {
+		course.Points = nil
+		courses = append(courses, course)
+	}
+	sort.Slice(courses, func(i, j int) bool { return courses[i].Name < courses[j].Name })
+	return courses, nil
+}
+
+// SavePersonalRecord replaces the record of the same type and source
+func (m *MemoryStore) SavePersonalRecord(record *gormin.PersonalRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.PRs[record.Source] == nil {
+		m.PRs[record.Source] = make(map[string]gormin.PersonalRecord)
+	}
+	m.PRs[record.Source][record.RecordType] = *record
+	return nil
+}
+
+// PersonalRecords returns the records from a source keyed by record type
+func (m *MemoryStore) PersonalRecords(source string) (map[string]gormin.PersonalRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	records := make(map[string]gormin.PersonalRecord)
+	for recordType, record := range m.PRs[source] {
+		records[recordType] = record
+	}
+	return records, nil
+}
+
+// DeletePersonalRecords removes all records from a source
+func (m *MemoryStore) DeletePersonalRecords(source string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.PRs, source)
+	return nil
+}
+
+// ActivitySummaries totals the stored activities of the last n periods like
+// the SQL store's rollups, oldest first and by activity type within a period
+func (m *MemoryStore) ActivitySummaries(period string, n int) ([]gormin.ActivitySummary, error) {
+	if !ValidPeriod(period) {
+		return nil, fmt.Errorf("invalid period %q: expected week, month or year", period)
+	}
+	since := summariesSince(period, n)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type summaryKey struct{ start, activityType string }
+	totals := make(map[summaryKey]*gormin.ActivitySummary)
+	hrSum := make(map[summaryKey]float64)
+	hrDuration := make(map[summaryKey]int)
+	for _, a := range m.Activities {
+		days := summaryDays(a.StartTime)
+		if len(days) == 0 {
+			continue
+		}
+		periodKey, start, _ := periodRange(period, days[0])
+		if start.Format("2006-01-02") < since {
+			continue
+		}
+		key := summaryKey{start.Format("2006-01-02"), a.Type}
+		summary, ok := totals[key]
+		if !ok {
+			summary = &gormin.ActivitySummary{Period: period, PeriodKey: periodKey, PeriodStart: key.start, Type: a.Type}
+			totals[key] = summary
+		}
+		summary.Activities++
+		summary.Distance += a.Distance
+		summary.Duration += a.Duration
+		summary.ElevationGain += a.ElevationGain
+		summary.Calories += a.Calories
+		// Weighted by duration like the SQL rollups
+		if a.AvgHR > 0 {
+			hrSum[key] += float64(a.AvgHR * a.Duration)
+			hrDuration[key] += a.Duration
+		}
+	}
+
+	summaries := make([]gormin.ActivitySummary, 0, len(totals))
+	for key, summary := range totals {
+		if hrDuration[key] > 0 {
+			summary.AvgHR = hrSum[key] / float64(hrDuration[key])
+		}
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].PeriodStart != summaries[j].PeriodStart {
+			return summaries[i].PeriodStart < summaries[j].PeriodStart
+		}
+		return summaries[i].Type < summaries[j].Type
+	})
+	return summaries, nil
+}
+
+// DailySummaries totals the stored daily stats of the last n periods like the
+// SQL store's rollups, oldest first
+func (m *MemoryStore) DailySummaries(period string, n int) ([]gormin.DailySummary, error) {
+	if !ValidPeriod(period) {
+		return nil, fmt.Errorf("invalid period %q: expected week, month or year", period)
+	}
+	since := summariesSince(period, n)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	totals := make(map[string]*gormin.DailySummary)
+	sleepHours := make(map[string][]float64)
+	restingHR := make(map[string][]float64)
+	for _, stats := range m.DailyStats {
+		days := summaryDays(stats.Date)
+		if len(days) == 0 {
+			continue
+		}
+		periodKey, start, _ := periodRange(period, days[0])
+		key := start.Format("2006-01-02")
+		if key < since {
+			continue
+		}
+		summary, ok := totals[key]
+		if !ok {
+			summary = &gormin.DailySummary{Period: period, PeriodKey: periodKey, PeriodStart: key}
+			totals[key] = summary
+		}
+		summary.Days++
+		summary.Steps += stats.Steps
+		summary.Distance += stats.Distance
+		summary.Calories += stats.Calories
+		// Averaged over the days that have them
+		if stats.SleepHours != 0 {
+			sleepHours[key] = append(sleepHours[key], stats.SleepHours)
+		}
+		if stats.RestingHR != 0 {
+			restingHR[key] = append(restingHR[key], float64(stats.RestingHR))
+		}
+	}
+
+	summaries := make([]gormin.DailySummary, 0, len(totals))
+	for key, summary := range totals {
+		summary.AvgSleepHours = mean(sleepHours[key])
+		summary.AvgRestingHR = mean(restingHR[key])
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].PeriodStart < summaries[j].PeriodStart })
+	return summaries, nil
+}
+
+// mean returns the average of values, or zero without values
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// RebuildSummaries does nothing, as the in-memory summaries are computed when read
+func (m *MemoryStore) RebuildSummaries() error {
+	return nil
+}
+
+// SaveRawResponse replaces the response with the same endpoint and key
+func (m *MemoryStore) SaveRawResponse(endpoint, key string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Responses[endpoint] == nil {
+		m.Responses[endpoint] = make(map[string][]byte)
+	}
+	m.Responses[endpoint][key] = append([]byte(nil), body...)
+	return nil
+}
+
+// RawResponses returns the responses of an endpoint ordered by key
+func (m *MemoryStore) RawResponses(endpoint string) ([]gormin.RawResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var responses []gormin.RawResponse
+	for key, body := range m.Responses[endpoint] {
+		responses = append(responses, gormin.RawResponse{Endpoint: endpoint, Key: key, Body: body})
+	}
+	sort.Slice(responses, func(i, j int) bool { return responses[i].Key < responses[j].Key })
+	return responses, nil
+}
+
+// RawResponseEndpoints returns the endpoints with responses, sorted
+func (m *MemoryStore) RawResponseEndpoints() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var endpoints []string
+	for endpoint := range m.Responses {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	return endpoints, nil
+}
+
+// Close does nothing for the in-memory store
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,778 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Migration is a numbered schema change, applied once in its own transaction.
+// Each migration has a SQLite and a PostgreSQL variant bringing both to the
+// same schema.
+type Migration struct {
+	Version     int
+	Description string
+	up          func(tx *sql.Tx) error
+	upPostgres  func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change in order. Append new migrations with
+// the next version number; never edit or reorder ones that have shipped.
+var migrations = []Migration{
+	{1, "baseline schema", baselineSchema, postgresBaselineSchema},
+	{2, "unique garmin_activity_id", uniqueGarminActivityID, postgresUniqueGarminActivityID},
+	{3, "summary rollup tables", summaryTables, postgresSummaryTables},
+	{4, "raw response archive", rawResponses, postgresRawResponses},
+	{5, "key activity details and gear by local id", localActivityKeys, postgresLocalActivityKeys},
+	{6, "gear lifetime totals", gearTotals, postgresGearTotals},
+	{7, "duration-weighted summary heart rate", weightedSummaryHR, postgresWeightedSummaryHR},
+	{8, "daily Body Battery average and stress minimum", dailyWellnessAggregates, postgresDailyWellnessAggregates},
+}
+
+// Migrations returns every schema migration in order
+func Migrations() []Migration {
+	return append([]Migration(nil), migrations...)
+}
+
+// LatestSchemaVersion returns the version the migrations bring a database to
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// Migrate applies all pending migrations in order
+func Migrate(db *sql.DB) error {
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current > LatestSchemaVersion() {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)",
+			current, LatestSchemaVersion())
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		fmt.Printf("Applied migration %d: %s\n", m.Version, m.Description)
+	}
+	return nil
+}
+
+// applyMigration runs a migration and records it in schema_version within
+// one transaction, so a failed migration leaves the schema untouched
+func applyMigration(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	driver := driverName(db)
+	up := m.up
+	if driver == DriverPostgres {
+		up = m.upPostgres
+	}
+	if err := up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(rebind(driver, `INSERT INTO schema_version (version, description) VALUES (?, ?)`),
+		m.Version, m.Description); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+	return tx.Commit()
+}
+
+// SchemaVersion returns the version of the database schema, creating the
+// schema_version table on first use
+func SchemaVersion(db *sql.DB) (int, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// AppliedMigrations returns when each applied migration ran, by version
+func AppliedMigrations(db *sql.DB) (map[int]string, error) {
+	if _, err := SchemaVersion(db); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]string)
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_version`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_version: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		var appliedAt sql.NullString
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema_version: %w", err)
+		}
+		applied[version] = appliedAt.String
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema_version: %w", err)
+	}
+	return applied, nil
+}
+
+// activityChildTables lists the tables referencing activities by local id as
+// of migration 2, which moves their rows when merging activities. It must not
+// change: the tables in connectKeyedTables still held Connect ids then, so
+// migration 2 leaves their rows alone for migration 5 to rekey.
+var activityChildTables = []string{
+	"heart_rate",
+	"activity_devices",
+	"activity_records",
+	"best_efforts",
+}
+
+// connectKeyedTables lists the tables that referenced activities by Connect
+// id before migration 5
+var connectKeyedTables = []string{
+	"activity_details",
+	"activity_splits",
+	"activity_zones",
+	"activity_weather",
+	"gear_activities",
+}
+
+// uniqueGarminActivityID merges the duplicate activity rows left by repeated
+// syncs and FIT imports, then makes garmin_activity_id unique. Rows with the
+// same Connect id, and FIT-only rows with the start time of another row, are
+// merged into the oldest row, preferring one with a Connect id.
+func uniqueGarminActivityID(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, garmin_activity_id, start_time FROM activities
+		ORDER BY garmin_activity_id IS NULL, id`)
+	if err != nil {
+		return err
+	}
+
+	type activityKey struct {
+		id        int64
+		garminID  sql.NullInt64
+		startTime string
+	}
+	var activities []activityKey
+	for rows.Next() {
+		var a activityKey
+		if err := rows.Scan(&a.id, &a.garminID, &a.startTime); err != nil {
+			rows.Close()
+			return err
+		}
+		activities = append(activities, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	byGarminID := make(map[int64]int64)
+	byStartTime := make(map[string]int64)
+	merged := 0
+	for _, a := range activities {
+		var keep int64
+		var found bool
+		if a.garminID.Valid {
+			keep, found = byGarminID[a.garminID.Int64]
+			if !found {
+				byGarminID[a.garminID.Int64] = a.id
+			}
+		} else {
+			keep, found = byStartTime[a.startTime]
+		}
+		if _, ok := byStartTime[a.startTime]; !ok {
+			byStartTime[a.startTime] = a.id
+		}
+		if !found {
+			continue
+		}
+
+		if err := mergeActivity(tx, keep, a.id); err != nil {
+			return fmt.Errorf("failed to merge activity %d into %d: %w", a.id, keep, err)
+		}
+		merged++
+	}
+	if merged > 0 {
+		fmt.Printf("Merged %d duplicate activities\n", merged)
+	}
+
+	for _, query := range []string{
+		`DROP INDEX IF EXISTS idx_activities_garmin_activity_id`,
+		`CREATE UNIQUE INDEX idx_activities_garmin_activity_id ON activities(garmin_activity_id)`,
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeActivity merges a duplicate activity row into the row kept, filling in
+// missing totals and moving the rows that reference it
+func mergeActivity(tx *sql.Tx, keep, duplicate int64) error {
+	_, err := tx.Exec(`UPDATE activities SET
+			duration = COALESCE(NULLIF(duration, 0), (SELECT duration FROM activities WHERE id = ?)),
+			distance = COALESCE(NULLIF(distance, 0), (SELECT distance FROM activities WHERE id = ?)),
+			calories = COALESCE(NULLIF(calories, 0), (SELECT calories FROM activities WHERE id = ?)),
+			avg_hr = COALESCE(NULLIF(avg_hr, 0), (SELECT avg_hr FROM activities WHERE id = ?)),
+			max_hr = COALESCE(NULLIF(max_hr, 0), (SELECT max_hr FROM activities WHERE id = ?)),
+			elevation_gain = COALESCE(NULLIF(elevation_gain, 0), (SELECT elevation_gain FROM activities WHERE id = ?))
+		WHERE id = ?`,
+		duplicate, duplicate, duplicate, duplicate, duplicate, duplicate, keep)
+	if err != nil {
+		return err
+	}
+
+	// Rows already present for the kept activity win over the duplicate's
+	for _, table := range activityChildTables {
+		if _, err := tx.Exec(fmt.Sprintf(`UPDATE OR IGNORE %s SET activity_id = ? WHERE activity_id = ?`, table), keep, duplicate); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE activity_id = ?`, table), duplicate); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE personal_records SET activity_id = ? WHERE activity_id = ?`, keep, duplicate); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM activities WHERE id = ?`, duplicate)
+	return err
+}
+
+// summaryTables creates the weekly, monthly and yearly rollups of activities
+// by type and of daily stats, and fills them from the existing data
+func summaryTables(tx *sql.Tx) error {
+	queries := []string{
+		`CREATE TABLE activity_summaries (
+			period TEXT NOT NULL,
+			period_key TEXT NOT NULL,
+			period_start TEXT NOT NULL,
+			activity_type TEXT NOT NULL,
+			activities INTEGER NOT NULL,
+			distance REAL,
+			duration INTEGER,
+			elevation_gain INTEGER,
+			calories INTEGER,
+			avg_hr REAL,
+			PRIMARY KEY (period, period_key, activity_type)
+		)`,
+		`CREATE TABLE daily_summaries (
+			period TEXT NOT NULL,
+			period_key TEXT NOT NULL,
+			period_start TEXT NOT NULL,
+			days INTEGER NOT NULL,
+			steps INTEGER,
+			distance REAL,
+			calories INTEGER,
+			avg_sleep_hours REAL,
+			avg_resting_hr REAL,
+			PRIMARY KEY (period, period_key)
+		)`,
+		`CREATE INDEX idx_activity_summaries_start ON activity_summaries(period, period_start)`,
+		`CREATE INDEX idx_daily_summaries_start ON daily_summaries(period, period_start)`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return rebuildSummaries(tx, DriverSQLite)
+}
+
+// rawResponses creates the archive of gzip-compressed Connect responses
+func rawResponses(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TABLE raw_responses (
+		endpoint TEXT NOT NULL,
+		key TEXT NOT NULL,
+		fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		body BLOB NOT NULL,
+		PRIMARY KEY (endpoint, key)
+	)`)
+	return err
+}
+
+// localActivityKeys moves the activity details, splits, zones, weather and
+// gear links from the Connect id to the local id of their activity, so every
+// table references activities the same way. Rows of activities that are not
+// stored locally have nothing to reference and are dropped. Moved ids are
+// negated until every row has moved, so they never collide with a row still
+// holding a Connect id.
+func localActivityKeys(tx *sql.Tx) error {
+	dropped := int64(0)
+	for _, table := range connectKeyedTables {
+		_, err := tx.Exec(fmt.Sprintf(`UPDATE %[1]s
+			SET activity_id = -(SELECT id FROM activities WHERE garmin_activity_id = %[1]s.activity_id)
+			WHERE activity_id IN (SELECT garmin_activity_id FROM activities)`, table))
+		if err != nil {
+			return fmt.Errorf("failed to rekey %s: %w", table, err)
+		}
+
+		result, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE activity_id >= 0`, table))
+		if err != nil {
+			return fmt.Errorf("failed to rekey %s: %w", table, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		dropped += n
+
+		if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET activity_id = -activity_id`, table)); err != nil {
+			return fmt.Errorf("failed to rekey %s: %w", table, err)
+		}
+	}
+	if dropped > 0 {
+		fmt.Printf("Dropped %d activity detail and gear rows without a local activity\n", dropped)
+	}
+	return nil
+}
+
+// gearTotals adds the lifetime distance and activity count Connect reports for
+// each gear item
+func gearTotals(tx *sql.Tx) error {
+	for _, query := range []string{
+		`ALTER TABLE gear ADD COLUMN total_distance REAL`,
+		`ALTER TABLE gear ADD COLUMN total_activities INTEGER`,
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// weightedSummaryHR recomputes the rollups, whose average heart rate was the
+// plain mean over activities before it was weighted by duration
+func weightedSummaryHR(tx *sql.Tx) error {
+	return rebuildSummaries(tx, DriverSQLite)
+}
+
+// dailyWellnessAggregates adds the daily Body Battery average and stress
+// minimum, derived from the time series
+func dailyWellnessAggregates(tx *sql.Tx) error {
+	for _, query := range []string{
+		`ALTER TABLE daily_stats ADD COLUMN body_battery_avg INTEGER`,
+		`ALTER TABLE daily_stats ADD COLUMN stress_min INTEGER`,
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// baselineSchema creates the schema as it was before versioned migrations.
+// Databases created before then may lack any of its tables and columns, so
+// every statement must be safe to run against a partial schema.
+func baselineSchema(tx *sql.Tx) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS activities (
+			id INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			start_time DATETIME NOT NULL,
+			duration INTEGER,
+			distance REAL,
+			calories INTEGER,
+			avg_hr INTEGER,
+			max_hr INTEGER,
+			elevation_gain INTEGER,
+			garmin_activity_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS activity_details (
+			activity_id INTEGER PRIMARY KEY,
+			description TEXT,
+			moving_duration INTEGER,
+			elevation_loss INTEGER,
+			avg_speed REAL,
+			max_speed REAL,
+			avg_power INTEGER,
+			max_power INTEGER,
+			avg_cadence REAL,
+			aerobic_te REAL,
+			anaerobic_te REAL,
+			privacy TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (activity_id) REFERENCES activities (id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS activity_splits (
+			activity_id INTEGER NOT NULL,
+			split_index INTEGER NOT NULL,
+			start_time DATETIME,
+			duration INTEGER,
+			distance REAL,
+			avg_speed REAL,
+			avg_hr INTEGER,
+			max_hr INTEGER,
+			elevation_gain INTEGER,
+			elevation_loss INTEGER,
+			calories INTEGER,
+			PRIMARY KEY (activity_id, split_index),
+			FOREIGN KEY (activity_id) REFERENCES activities (id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS activity_zones (
+			activity_id INTEGER NOT NULL,
+			zone_type TEXT NOT NULL,
+			zone INTEGER NOT NULL,
+			seconds INTEGER,
+			low_boundary REAL,
+			PRIMARY KEY (activity_id, zone_type, zone),
+			FOREIGN KEY (activity_id) REFERENCES activities (id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS activity_weather (
+			activity_id INTEGER PRIMARY KEY,
+			temperature REAL,
+			apparent_temperature REAL,
+			dew_point REAL,
+			humidity INTEGER,
+			wind_speed REAL,
+			wind_gust REAL,
+			wind_direction TEXT,
+			condition TEXT,
+			issued_at DATETIME,
+			FOREIGN KEY (activity_id) REFERENCES activities (id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS gear (
+			uuid TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			make_model TEXT,
+			type TEXT,
+			status TEXT,
+			date_begin TEXT,
+			max_distance REAL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS gear_activities (
+			gear_uuid TEXT NOT NULL,
+			activity_id INTEGER NOT NULL,
+			PRIMARY KEY (gear_uuid, activity_id),
+			FOREIGN KEY (gear_uuid) REFERENCES gear (uuid)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS devices (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			manufacturer TEXT NOT NULL DEFAULT '',
+			product TEXT NOT NULL DEFAULT '',
+			product_name TEXT,
+			serial_number TEXT NOT NULL DEFAULT '',
+			software_version TEXT,
+			hardware_version TEXT,
+			battery_status TEXT,
+			device_type TEXT,
+			source TEXT,
+			last_seen DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS activity_devices (
+			activity_id INTEGER NOT NULL,
+			device_id INTEGER NOT NULL,
+			software_version TEXT,
+			battery_status TEXT,
+			PRIMARY KEY (activity_id, device_id),
+			FOREIGN KEY (activity_id) REFERENCES activities (id),
+			FOREIGN KEY (device_id) REFERENCES devices (id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS workouts (
+			id INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			sport TEXT,
+			description TEXT,
+			updated_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS workout_steps (
+			workout_id INTEGER NOT NULL,
+			step_index INTEGER NOT NULL,
+			parent_index INTEGER,
+			type TEXT NOT NULL,
+			duration_type TEXT,
+			duration_value REAL,
+			target_type TEXT,
+			target_low REAL,
+			target_high REAL,
+			zone INTEGER,
+			repeat_count INTEGER,
+			description TEXT,
+			PRIMARY KEY (workout_id, step_index),
+			FOREIGN KEY (workout_id) REFERENCES workouts (id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS activity_records (
+			activity_id INTEGER NOT NULL,
+			timestamp DATETIME NOT NULL,
+			latitude REAL,
+			longitude REAL,
+			altitude REAL,
+			distance REAL,
+			heart_rate INTEGER,
+			speed REAL,
+			cadence INTEGER,
+			power INTEGER,
+			PRIMARY KEY (activity_id, timestamp),
+			FOREIGN KEY (activity_id) REFERENCES activities (id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS courses (
+			id INTEGER PRIMARY KEY,
+			garmin_course_id INTEGER UNIQUE,
+			name TEXT NOT NULL,
+			sport TEXT,
+			description TEXT,
+			distance REAL,
+			total_ascent INTEGER,
+			total_descent INTEGER,
+			source TEXT,
+			file_path TEXT UNIQUE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS course_points (
+			course_id INTEGER NOT NULL,
+			point_index INTEGER NOT NULL,
+			latitude REAL NOT NULL,
+			longitude REAL NOT NULL,
+			elevation REAL,
+			distance REAL,
+			PRIMARY KEY (course_id, point_index),
+			FOREIGN KEY (course_id) REFERENCES courses (id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS best_efforts (
+			activity_id INTEGER NOT NULL,
+			effort TEXT NOT NULL,
+			value REAL NOT NULL,
+			start_time DATETIME,
+			PRIMARY KEY (activity_id, effort),
+			FOREIGN KEY (activity_id) REFERENCES activities (id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS personal_records (
+			record_type TEXT NOT NULL,
+			source TEXT NOT NULL,
+			value REAL NOT NULL,
+			activity_id INTEGER,
+			garmin_activity_id INTEGER,
+			achieved_at DATETIME,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (record_type, source)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS daily_stats (
+			date TEXT PRIMARY KEY,
+			steps INTEGER,
+			distance REAL,
+			calories INTEGER,
+			sleep_hours REAL,
+			resting_hr INTEGER,
+			weight REAL,
+			body_fat REAL,
+			body_battery_min INTEGER,
+			body_battery_max INTEGER,
+			body_battery_charged INTEGER,
+			body_battery_drained INTEGER,
+			stress_avg INTEGER,
+			stress_max INTEGER,
+			respiration_min REAL,
+			respiration_max REAL,
+			respiration_avg REAL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS weight_data (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date TEXT NOT NULL,
+			weight REAL NOT NULL,
+			body_fat REAL,
+			muscle_mass REAL,
+			bone_mass REAL,
+			water_percentage REAL,
+			timestamp DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS heart_rate (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp DATETIME NOT NULL,
+			heart_rate INTEGER NOT NULL,
+			activity_id INTEGER,
+			FOREIGN KEY (activity_id) REFERENCES activities (id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS sleep_data (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date TEXT NOT NULL,
+			start_time DATETIME,
+			end_time DATETIME,
+			duration INTEGER,
+			deep_sleep INTEGER,
+			light_sleep INTEGER,
+			rem_sleep INTEGER,
+			awake_time INTEGER,
+			sleep_score INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS body_battery (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp DATETIME NOT NULL,
+			value REAL NOT NULL,
+			source TEXT NOT NULL,
+			UNIQUE (timestamp, source)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS stress (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp DATETIME NOT NULL,
+			value REAL NOT NULL,
+			source TEXT NOT NULL,
+			UNIQUE (timestamp, source)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS respiration (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp DATETIME NOT NULL,
+			value REAL NOT NULL,
+			source TEXT NOT NULL,
+			UNIQUE (timestamp, source)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS hrv_status (
+			date TEXT PRIMARY KEY,
+			weekly_avg REAL,
+			last_night_avg REAL,
+			last_night_5min_high REAL,
+			baseline_low_upper REAL,
+			baseline_balanced_low REAL,
+			baseline_balanced_upper REAL,
+			status TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS pulse_ox (
+			date TEXT PRIMARY KEY,
+			avg_spo2 REAL,
+			lowest_spo2 REAL,
+			avg_sleep_spo2 REAL,
+			last_seven_days_avg REAL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS sleep_levels (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date TEXT NOT NULL,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME NOT NULL,
+			stage TEXT NOT NULL
+		)`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	// Add columns introduced after the original schema to existing databases
+	columns := []struct{ table, column, definition string }{
+		{"activities", "garmin_activity_id", "INTEGER"},
+		{"activity_details", "privacy", "TEXT"},
+		{"sleep_data", "sleep_score", "INTEGER"},
+		{"weight_data", "timestamp", "DATETIME"},
+		{"daily_stats", "body_battery_min", "INTEGER"},
+		{"daily_stats", "body_battery_max", "INTEGER"},
+		{"daily_stats", "body_battery_charged", "INTEGER"},
+		{"daily_stats", "body_battery_drained", "INTEGER"},
+		{"daily_stats", "stress_avg", "INTEGER"},
+		{"daily_stats", "stress_max", "INTEGER"},
+		{"daily_stats", "respiration_min", "REAL"},
+		{"daily_stats", "respiration_max", "REAL"},
+		{"daily_stats", "respiration_avg", "REAL"},
+	}
+
+	for _, c := range columns {
+		if err := addColumnIfMissing(tx, c.table, c.column, c.definition); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", c.table, c.column, err)
+		}
+	}
+
+	// Create indexes for better performance
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_activities_start_time ON activities(start_time)`,
+		`CREATE INDEX IF NOT EXISTS idx_activities_type ON activities(type)`,
+		`CREATE INDEX IF NOT EXISTS idx_activities_garmin_activity_id ON activities(garmin_activity_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_daily_stats_date ON daily_stats(date)`,
+		`CREATE INDEX IF NOT EXISTS idx_weight_data_date ON weight_data(date)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_weight_data_timestamp ON weight_data(timestamp)`,
+		`CREATE INDEX IF NOT EXISTS idx_heart_rate_timestamp ON heart_rate(timestamp)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_heart_rate_sample ON heart_rate(timestamp, IFNULL(activity_id, 0))`,
+		`CREATE INDEX IF NOT EXISTS idx_sleep_data_date ON sleep_data(date)`,
+		`CREATE INDEX IF NOT EXISTS idx_devices_serial_number ON devices(serial_number)`,
+		`CREATE INDEX IF NOT EXISTS idx_sleep_levels_date ON sleep_levels(date)`,
+	}
+
+	for _, index := range indexes {
+		if _, err := tx.Exec(index); err != nil {
+			return fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already present
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
@@ -0,0 +1,355 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// openTestDB opens an empty SQLite database in a temporary directory
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Open(DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// exec runs setup statements, failing the test on the first error
+func exec(t *testing.T, db *sql.DB, queries ...string) {
+	t.Helper()
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+}
+
+// columnNames returns the columns of a SQLite table
+func columnNames(t *testing.T, db *sql.DB, table string) map[string]bool {
+	t.Helper()
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		t.Fatalf("table_info(%s): %v", table, err)
+	}
+	defer rows.Close()
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("table_info(%s): %v", table, err)
+		}
+		columns[name] = true
+	}
+	return columns
+}
+
+// queryInt runs a query returning a single integer
+func queryInt(t *testing.T, db *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+// legacySchema is a database as created before versioned migrations: the
+// activities of repeated syncs and FIT imports are duplicated, details are
+// keyed by Connect id and later columns are missing
+var legacySchema = []string{
+	`CREATE TABLE activities (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		start_time DATETIME NOT NULL,
+		duration INTEGER,
+		distance REAL,
+		calories INTEGER,
+		avg_hr INTEGER,
+		max_hr INTEGER,
+		elevation_gain INTEGER,
+		garmin_activity_id INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE activity_details (
+		activity_id INTEGER PRIMARY KEY,
+		description TEXT,
+		moving_duration INTEGER,
+		elevation_loss INTEGER,
+		avg_speed REAL,
+		max_speed REAL,
+		avg_power INTEGER,
+		max_power INTEGER,
+		avg_cadence REAL,
+		aerobic_te REAL,
+		anaerobic_te REAL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE activity_records (
+		activity_id INTEGER NOT NULL,
+		timestamp DATETIME NOT NULL,
+		latitude REAL,
+		longitude REAL,
+		altitude REAL,
+		distance REAL,
+		heart_rate INTEGER,
+		speed REAL,
+		cadence INTEGER,
+		power INTEGER,
+		PRIMARY KEY (activity_id, timestamp)
+	)`,
+	`CREATE TABLE heart_rate (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp DATETIME NOT NULL,
+		heart_rate INTEGER NOT NULL,
+		activity_id INTEGER
+	)`,
+	`CREATE TABLE daily_stats (
+		date TEXT PRIMARY KEY,
+		steps INTEGER,
+		distance REAL,
+		calories INTEGER,
+		sleep_hours REAL,
+		resting_hr INTEGER,
+		weight REAL,
+		body_fat REAL
+	)`,
+	// Synced twice, then imported from its FIT file
+	`INSERT INTO activities (id, name, type, start_time, duration, distance, avg_hr, garmin_activity_id)
+		VALUES (1, 'Morning Run', 'running', '2024-03-04 07:00:00', 0, 10.2, 0, 9000000001)`,
+	`INSERT INTO activities (id, name, type, start_time, duration, distance, avg_hr, garmin_activity_id)
+		VALUES (2, 'Morning Run', 'running', '2024-03-04 07:00:00', 3600, 10.2, 0, 9000000001)`,
+	`INSERT INTO activities (id, name, type, start_time, duration, distance, avg_hr)
+		VALUES (3, 'Run', 'running', '2024-03-04 07:00:00', 3590, 10.1, 151)`,
+	// An unrelated FIT-only activity
+	`INSERT INTO activities (id, name, type, start_time, duration, distance)
+		VALUES (4, 'Ride', 'cycling', '2024-03-05 17:30:00', 5400, 40.0)`,
+	`INSERT INTO activity_details (activity_id, description) VALUES (9000000001, 'Easy run')`,
+	`INSERT INTO activity_records (activity_id, timestamp, heart_rate) VALUES (3, '2024-03-04 07:00:00', 120)`,
+	`INSERT INTO activity_records (activity_id, timestamp, heart_rate) VALUES (3, '2024-03-04 07:00:01', 122)`,
+	`INSERT INTO heart_rate (timestamp, heart_rate, activity_id) VALUES ('2024-03-04 07:00:00', 120, 3)`,
+	`INSERT INTO heart_rate (timestamp, heart_rate, activity_id) VALUES ('2024-03-05 17:30:00', 110, 4)`,
+	`INSERT INTO daily_stats (date, steps) VALUES ('2024-03-04', 12000)`,
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	db := openTestDB(t)
+	exec(t, db, legacySchema...)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	version, err := SchemaVersion(db)
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("schema version = %d, want %d", version, LatestSchemaVersion())
+	}
+
+	for table, columns := range map[string][]string{
+		"activity_details": {"privacy"},
+		"daily_stats":      {"body_battery_min", "body_battery_avg", "stress_min", "stress_avg", "respiration_avg"},
+		"gear":             {"total_distance", "total_activities"},
+	} {
+		have := columnNames(t, db, table)
+		for _, column := range columns {
+			if !have[column] {
+				t.Errorf("%s.%s is missing", table, column)
+			}
+		}
+	}
+	for _, table := range []string{"activity_summaries", "daily_summaries", "raw_responses", "sleep_levels"} {
+		if len(columnNames(t, db, table)) == 0 {
+			t.Errorf("table %s is missing", table)
+		}
+	}
+
+	// The duplicates are merged into the oldest row with a Connect id
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM activities`); n != 2 {
+		t.Errorf("%d activities, want 2", n)
+	}
+	var duration, avgHR int
+	var garminID int64
+	err = db.QueryRow(`SELECT duration, avg_hr, garmin_activity_id FROM activities WHERE id = 1`).
+		Scan(&duration, &avgHR, &garminID)
+	if err != nil {
+		t.Fatalf("merged activity: %v", err)
+	}
+	if duration != 3600 || avgHR != 151 || garminID != 9000000001 {
+		t.Errorf("merged activity has duration %d, avg HR %d, Connect id %d; want 3600, 151, 9000000001",
+			duration, avgHR, garminID)
+	}
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM activity_records WHERE activity_id = 1`); n != 2 {
+		t.Errorf("%d records moved to the merged activity, want 2", n)
+	}
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM heart_rate WHERE activity_id = 1`); n != 1 {
+		t.Errorf("%d heart rate samples moved to the merged activity, want 1", n)
+	}
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM heart_rate WHERE activity_id = 4`); n != 1 {
+		t.Errorf("%d heart rate samples left on the unrelated activity, want 1", n)
+	}
+
+	// Details move from the Connect id to the local id
+	var description string
+	if err := db.QueryRow(`SELECT description FROM activity_details WHERE activity_id = 1`).Scan(&description); err != nil {
+		t.Fatalf("details of merged activity: %v", err)
+	}
+	if description != "Easy run" {
+		t.Errorf("description = %q, want %q", description, "Easy run")
+	}
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM activity_details`); n != 1 {
+		t.Errorf("%d activity details, want 1", n)
+	}
+
+	// The rollups include the merged activities once
+	if n := queryInt(t, db, `SELECT activities FROM activity_summaries
+		WHERE period = 'week' AND activity_type = 'running'`); n != 1 {
+		t.Errorf("week summary counts %d runs, want 1", n)
+	}
+
+	_, err = db.Exec(`INSERT INTO activities (name, type, start_time, garmin_activity_id)
+		VALUES ('Copy', 'running', '2024-03-06 07:00:00', 9000000001)`)
+	if err == nil {
+		t.Error("inserting a second activity with the same Connect id succeeded")
+	}
+}
+
+func TestMigrateMergeLeavesConnectKeyedRows(t *testing.T) {
+	db := openTestDB(t)
+	exec(t, db, legacySchema...)
+	// Details of an activity with Connect id 2, the local id of a duplicate
+	exec(t, db, `INSERT INTO activity_details (activity_id, description) VALUES (2, 'Not stored')`)
+	if _, err := SchemaVersion(db); err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	for _, m := range migrations {
+		if m.Version > 4 {
+			break
+		}
+		if err := applyMigration(db, m); err != nil {
+			t.Fatalf("migration %d: %v", m.Version, err)
+		}
+	}
+
+	// Until migration 5 details are keyed by Connect id, so merging the
+	// duplicate with local id 2 must not move or delete them
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM activity_details WHERE activity_id = 2`); n != 1 {
+		t.Errorf("%d details keyed by Connect id 2, want 1", n)
+	}
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM activity_details WHERE activity_id = 1`); n != 0 {
+		t.Errorf("%d details moved to the merged activity, want 0", n)
+	}
+}
+
+func TestMigrateRollsBackFailedMigration(t *testing.T) {
+	db := openTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	exec(t, db, `INSERT INTO activities (name, type, start_time) VALUES ('Run', 'running', '2024-03-04 07:00:00')`)
+
+	failing := Migration{
+		Version:     LatestSchemaVersion() + 1,
+		Description: "failing migration",
+	}
+	failing.up = func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`CREATE TABLE partial (id INTEGER PRIMARY KEY)`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM activities`); err != nil {
+			return err
+		}
+		return errors.New("migration failed halfway")
+	}
+	failing.upPostgres = failing.up
+
+	saved := migrations
+	migrations = append(append([]Migration(nil), migrations...), failing)
+	defer func() { migrations = saved }()
+
+	if err := Migrate(db); err == nil {
+		t.Fatal("Migrate succeeded with a failing migration")
+	}
+
+	version, err := SchemaVersion(db)
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if version != failing.Version-1 {
+		t.Errorf("schema version = %d, want %d", version, failing.Version-1)
+	}
+	if len(columnNames(t, db, "partial")) != 0 {
+		t.Error("table created by the failed migration was kept")
+	}
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM activities`); n != 1 {
+		t.Errorf("%d activities after the failed migration, want 1", n)
+	}
+}
+
+func TestMigrateTwiceIsNoop(t *testing.T) {
+	db := openTestDB(t)
+	exec(t, db, legacySchema...)
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	snapshot := func() (int, int, int) {
+		return queryInt(t, db, `SELECT COUNT(*) FROM schema_version`),
+			queryInt(t, db, `SELECT COUNT(*) FROM activities`),
+			queryInt(t, db, `SELECT COUNT(*) FROM sqlite_master`)
+	}
+	versions, activities, objects := snapshot()
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	v, a, o := snapshot()
+	if v != versions || a != activities || o != objects {
+		t.Errorf("second Migrate changed the database: %d/%d/%d schema versions/activities/objects, want %d/%d/%d",
+			v, a, o, versions, activities, objects)
+	}
+	if versions != len(migrations) {
+		t.Errorf("%d migrations recorded, want %d", versions, len(migrations))
+	}
+}
+
+func TestMigrateWeightsSummaryHRByDuration(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := SchemaVersion(db); err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	// A database last migrated before the average was weighted
+	for _, m := range migrations {
+		if m.Version > 6 {
+			break
+		}
+		if err := applyMigration(db, m); err != nil {
+			t.Fatalf("migration %d: %v", m.Version, err)
+		}
+	}
+	exec(t, db,
+		`INSERT INTO activities (name, type, start_time, duration, avg_hr) VALUES ('Warm-up', 'running', '2024-03-04 07:00:00', 600, 120)`,
+		`INSERT INTO activities (name, type, start_time, duration, avg_hr) VALUES ('Long run', 'running', '2024-03-05 07:00:00', 3000, 150)`,
+		`INSERT INTO activities (name, type, start_time, duration, avg_hr) VALUES ('No strap', 'running', '2024-03-06 07:00:00', 1200, 0)`,
+	)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	// (600 * 120 + 3000 * 150) / 3600, rather than the plain mean of 135
+	var avgHR float64
+	if err := db.QueryRow(`SELECT avg_hr FROM activity_summaries
+		WHERE period = 'week' AND activity_type = 'running'`).Scan(&avgHR); err != nil {
+		t.Fatalf("week summary: %v", err)
+	}
+	if avgHR != 145 {
+		t.Errorf("week summary average HR = %v, want 145", avgHR)
+	}
+}
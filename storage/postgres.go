@@ -0,0 +1,455 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// postgresBaselineSchema creates the baseline schema on PostgreSQL. It matches
+// the SQLite baseline with PostgreSQL types: ids are BIGINT as Connect ids
+// overflow 32 bits, REAL becomes DOUBLE PRECISION and DATETIME becomes
+// TIMESTAMP. Dates stay TEXT as in SQLite. Foreign keys are enforced here,
+// so they are only declared where every row is known to have its parent.
+// PostgreSQL databases never had the pre-migration schema, so every column is
+// created up front.
+func postgresBaselineSchema(tx *sql.Tx) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS activities (
+			id BIGSERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			start_time TIMESTAMP NOT NULL,
+			duration INTEGER,
+			distance DOUBLE PRECISION,
+			calories INTEGER,
+			avg_hr INTEGER,
+			max_hr INTEGER,
+			elevation_gain INTEGER,
+			garmin_activity_id BIGINT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS activity_details (
+			activity_id BIGINT PRIMARY KEY,
+			description TEXT,
+			moving_duration INTEGER,
+			elevation_loss INTEGER,
+			avg_speed DOUBLE PRECISION,
+			max_speed DOUBLE PRECISION,
+			avg_power INTEGER,
+			max_power INTEGER,
+			avg_cadence DOUBLE PRECISION,
+			aerobic_te DOUBLE PRECISION,
+			anaerobic_te DOUBLE PRECISION,
+			privacy TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS activity_splits (
+			activity_id BIGINT NOT NULL,
+			split_index INTEGER NOT NULL,
+			start_time TIMESTAMP,
+			duration INTEGER,
+			distance DOUBLE PRECISION,
+			avg_speed DOUBLE PRECISION,
+			avg_hr INTEGER,
+			max_hr INTEGER,
+			elevation_gain INTEGER,
+			elevation_loss INTEGER,
+			calories INTEGER,
+			PRIMARY KEY (activity_id, split_index)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS activity_zones (
+			activity_id BIGINT NOT NULL,
+			zone_type TEXT NOT NULL,
+			zone INTEGER NOT NULL,
+			seconds INTEGER,
+			low_boundary DOUBLE PRECISION,
+			PRIMARY KEY (activity_id, zone_type, zone)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS activity_weather (
+			activity_id BIGINT PRIMARY KEY,
+			temperature DOUBLE PRECISION,
+			apparent_temperature DOUBLE PRECISION,
+			dew_point DOUBLE PRECISION,
+			humidity INTEGER,
+			wind_speed DOUBLE PRECISION,
+			wind_gust DOUBLE PRECISION,
+			wind_direction TEXT,
+			condition TEXT,
+			issued_at TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS gear (
+			uuid TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			make_model TEXT,
+			type TEXT,
+			status TEXT,
+			date_begin TEXT,
+			max_distance DOUBLE PRECISION,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS gear_activities (
+			gear_uuid TEXT NOT NULL,
+			activity_id BIGINT NOT NULL,
+			PRIMARY KEY (gear_uuid, activity_id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS devices (
+			id BIGSERIAL PRIMARY KEY,
+			manufacturer TEXT NOT NULL DEFAULT '',
+			product TEXT NOT NULL DEFAULT '',
+			product_name TEXT,
+			serial_number TEXT NOT NULL DEFAULT '',
+			software_version TEXT,
+			hardware_version TEXT,
+			battery_status TEXT,
+			device_type TEXT,
+			source TEXT,
+			last_seen TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS activity_devices (
+			activity_id BIGINT NOT NULL REFERENCES activities (id),
+			device_id BIGINT NOT NULL REFERENCES devices (id),
+			software_version TEXT,
+			battery_status TEXT,
+			PRIMARY KEY (activity_id, device_id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS workouts (
+			id BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			sport TEXT,
+			description TEXT,
+			updated_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS workout_steps (
+			workout_id BIGINT NOT NULL REFERENCES workouts (id),
+			step_index INTEGER NOT NULL,
+			parent_index INTEGER,
+			type TEXT NOT NULL,
+			duration_type TEXT,
+			duration_value DOUBLE PRECISION,
+			target_type TEXT,
+			target_low DOUBLE PRECISION,
+			target_high DOUBLE PRECISION,
+			zone INTEGER,
+			repeat_count INTEGER,
+			description TEXT,
+			PRIMARY KEY (workout_id, step_index)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS activity_records (
+			activity_id BIGINT NOT NULL REFERENCES activities (id),
+			timestamp TIMESTAMP NOT NULL,
+			latitude DOUBLE PRECISION,
+			longitude DOUBLE PRECISION,
+			altitude DOUBLE PRECISION,
+			distance DOUBLE PRECISION,
+			heart_rate INTEGER,
+			speed DOUBLE PRECISION,
+			cadence INTEGER,
+			power INTEGER,
+			PRIMARY KEY (activity_id, timestamp)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS courses (
+			id BIGSERIAL PRIMARY KEY,
+			garmin_course_id BIGINT UNIQUE,
+			name TEXT NOT NULL,
+			sport TEXT,
+			description TEXT,
+			distance DOUBLE PRECISION,
+			total_ascent INTEGER,
+			total_descent INTEGER,
+			source TEXT,
+			file_path TEXT UNIQUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS course_points (
+			course_id BIGINT NOT NULL REFERENCES courses (id),
+			point_index INTEGER NOT NULL,
+			latitude DOUBLE PRECISION NOT NULL,
+			longitude DOUBLE PRECISION NOT NULL,
+			elevation DOUBLE PRECISION,
+			distance DOUBLE PRECISION,
+			PRIMARY KEY (course_id, point_index)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS best_efforts (
+			activity_id BIGINT NOT NULL REFERENCES activities (id),
+			effort TEXT NOT NULL,
+			value DOUBLE PRECISION NOT NULL,
+			start_time TIMESTAMP,
+			PRIMARY KEY (activity_id, effort)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS personal_records (
+			record_type TEXT NOT NULL,
+			source TEXT NOT NULL,
+			value DOUBLE PRECISION NOT NULL,
+			activity_id BIGINT,
+			garmin_activity_id BIGINT,
+			achieved_at TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (record_type, source)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS daily_stats (
+			date TEXT PRIMARY KEY,
+			steps INTEGER,
+			distance DOUBLE PRECISION,
+			calories INTEGER,
+			sleep_hours DOUBLE PRECISION,
+			resting_hr INTEGER,
+			weight DOUBLE PRECISION,
+			body_fat DOUBLE PRECISION,
+			body_battery_min INTEGER,
+			body_battery_max INTEGER,
+			body_battery_charged INTEGER,
+			body_battery_drained INTEGER,
+			stress_avg INTEGER,
+			stress_max INTEGER,
+			respiration_min DOUBLE PRECISION,
+			respiration_max DOUBLE PRECISION,
+			respiration_avg DOUBLE PRECISION,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS weight_data (
+			id BIGSERIAL PRIMARY KEY,
+			date TEXT NOT NULL,
+			weight DOUBLE PRECISION NOT NULL,
+			body_fat DOUBLE PRECISION,
+			muscle_mass DOUBLE PRECISION,
+			bone_mass DOUBLE PRECISION,
+			water_percentage DOUBLE PRECISION,
+			timestamp TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS heart_rate (
+			id BIGSERIAL PRIMARY KEY,
+			timestamp TIMESTAMP NOT NULL,
+			heart_rate INTEGER NOT NULL,
+			activity_id BIGINT REFERENCES activities (id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS sleep_data (
+			id BIGSERIAL PRIMARY KEY,
+			date TEXT NOT NULL,
+			start_time TIMESTAMP,
+			end_time TIMESTAMP,
+			duration INTEGER,
+			deep_sleep INTEGER,
+			light_sleep INTEGER,
+			rem_sleep INTEGER,
+			awake_time INTEGER,
+			sleep_score INTEGER,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS body_battery (
+			id BIGSERIAL PRIMARY KEY,
+			timestamp TIMESTAMP NOT NULL,
+			value DOUBLE PRECISION NOT NULL,
+			source TEXT NOT NULL,
+			UNIQUE (timestamp, source)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS stress (
+			id BIGSERIAL PRIMARY KEY,
+			timestamp TIMESTAMP NOT NULL,
+			value DOUBLE PRECISION NOT NULL,
+			source TEXT NOT NULL,
+			UNIQUE (timestamp, source)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS respiration (
+			id BIGSERIAL PRIMARY KEY,
+			timestamp TIMESTAMP NOT NULL,
+			value DOUBLE PRECISION NOT NULL,
+			source TEXT NOT NULL,
+			UNIQUE (timestamp, source)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS hrv_status (
+			date TEXT PRIMARY KEY,
+			weekly_avg DOUBLE PRECISION,
+			last_night_avg DOUBLE PRECISION,
+			last_night_5min_high DOUBLE PRECISION,
+			baseline_low_upper DOUBLE PRECISION,
+			baseline_balanced_low DOUBLE PRECISION,
+			baseline_balanced_upper DOUBLE PRECISION,
+			status TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS pulse_ox (
+			date TEXT PRIMARY KEY,
+			avg_spo2 DOUBLE PRECISION,
+			lowest_spo2 DOUBLE PRECISION,
+			avg_sleep_spo2 DOUBLE PRECISION,
+			last_seven_days_avg DOUBLE PRECISION,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS sleep_levels (
+			id BIGSERIAL PRIMARY KEY,
+			date TEXT NOT NULL,
+			start_time TIMESTAMP NOT NULL,
+			end_time TIMESTAMP NOT NULL,
+			stage TEXT NOT NULL
+		)`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_activities_start_time ON activities(start_time)`,
+		`CREATE INDEX IF NOT EXISTS idx_activities_type ON activities(type)`,
+		`CREATE INDEX IF NOT EXISTS idx_activities_garmin_activity_id ON activities(garmin_activity_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_daily_stats_date ON daily_stats(date)`,
+		`CREATE INDEX IF NOT EXISTS idx_weight_data_date ON weight_data(date)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_weight_data_timestamp ON weight_data(timestamp)`,
+		`CREATE INDEX IF NOT EXISTS idx_heart_rate_timestamp ON heart_rate(timestamp)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_heart_rate_sample ON heart_rate(timestamp, COALESCE(activity_id, 0))`,
+		`CREATE INDEX IF NOT EXISTS idx_sleep_data_date ON sleep_data(date)`,
+		`CREATE INDEX IF NOT EXISTS idx_devices_serial_number ON devices(serial_number)`,
+		`CREATE INDEX IF NOT EXISTS idx_sleep_levels_date ON sleep_levels(date)`,
+	}
+
+	for _, index := range indexes {
+		if _, err := tx.Exec(index); err != nil {
+			return fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// postgresUniqueGarminActivityID makes garmin_activity_id unique. PostgreSQL
+// databases start at the current upsert logic, so there are no duplicate
+// activities to merge first.
+func postgresUniqueGarminActivityID(tx *sql.Tx) error {
+	for _, query := range []string{
+		`DROP INDEX IF EXISTS idx_activities_garmin_activity_id`,
+		`CREATE UNIQUE INDEX idx_activities_garmin_activity_id ON activities(garmin_activity_id)`,
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postgresSummaryTables creates the summary rollups like summaryTables
+func postgresSummaryTables(tx *sql.Tx) error {
+	queries := []string{
+		`CREATE TABLE activity_summaries (
+			period TEXT NOT NULL,
+			period_key TEXT NOT NULL,
+			period_start TEXT NOT NULL,
+			activity_type TEXT NOT NULL,
+			activities INTEGER NOT NULL,
+			distance DOUBLE PRECISION,
+			duration INTEGER,
+			elevation_gain INTEGER,
+			calories INTEGER,
+			avg_hr DOUBLE PRECISION,
+			PRIMARY KEY (period, period_key, activity_type)
+		)`,
+		`CREATE TABLE daily_summaries (
+			period TEXT NOT NULL,
+			period_key TEXT NOT NULL,
+			period_start TEXT NOT NULL,
+			days INTEGER NOT NULL,
+			steps INTEGER,
+			distance DOUBLE PRECISION,
+			calories INTEGER,
+			avg_sleep_hours DOUBLE PRECISION,
+			avg_resting_hr DOUBLE PRECISION,
+			PRIMARY KEY (period, period_key)
+		)`,
+		`CREATE INDEX idx_activity_summaries_start ON activity_summaries(period, period_start)`,
+		`CREATE INDEX idx_daily_summaries_start ON daily_summaries(period, period_start)`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return rebuildSummaries(tx, DriverPostgres)
+}
+
+// postgresRawResponses creates the response archive like rawResponses
+func postgresRawResponses(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TABLE raw_responses (
+		endpoint TEXT NOT NULL,
+		key TEXT NOT NULL,
+		fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		body BYTEA NOT NULL,
+		PRIMARY KEY (endpoint, key)
+	)`)
+	return err
+}
+
+// postgresLocalActivityKeys rekeys the activity details and gear links like
+// localActivityKeys. Every remaining row then has its activity, so the foreign
+// keys left out of the baseline are declared.
+func postgresLocalActivityKeys(tx *sql.Tx) error {
+	if err := localActivityKeys(tx); err != nil {
+		return err
+	}
+	for _, table := range connectKeyedTables {
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s
+			ADD FOREIGN KEY (activity_id) REFERENCES activities (id)`, table)); err != nil {
+			return fmt.Errorf("failed to add foreign key to %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// postgresGearTotals adds the gear totals like gearTotals
+func postgresGearTotals(tx *sql.Tx) error {
+	for _, query := range []string{
+		`ALTER TABLE gear ADD COLUMN total_distance DOUBLE PRECISION`,
+		`ALTER TABLE gear ADD COLUMN total_activities INTEGER`,
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postgresWeightedSummaryHR recomputes the rollups like weightedSummaryHR
+func postgresWeightedSummaryHR(tx *sql.Tx) error {
+	return rebuildSummaries(tx, DriverPostgres)
+}
+
+// postgresDailyWellnessAggregates adds the columns like dailyWellnessAggregates
+func postgresDailyWellnessAggregates(tx *sql.Tx) error {
+	for _, query := range []string{
+		`ALTER TABLE daily_stats ADD COLUMN body_battery_avg INTEGER`,
+		`ALTER TABLE daily_stats ADD COLUMN stress_min INTEGER`,
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
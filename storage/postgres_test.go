@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// postgresDSNEnv names the variable holding the DSN of a PostgreSQL database
+// the tests may create schemas in. The PostgreSQL tests are skipped without it.
+const postgresDSNEnv = "GORMIN_TEST_POSTGRES_DSN"
+
+// openPostgresTestDB opens a connection to the test database whose tables
+// live in a new schema, dropped when the test ends
+func openPostgresTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+
+	admin, err := Open(DriverPostgres, dsn)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	schema := fmt.Sprintf("gormin_test_%d", time.Now().UnixNano())
+	exec(t, admin, `CREATE SCHEMA `+schema)
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Errorf("failed to drop schema %s: %v", schema, err)
+		}
+		admin.Close()
+	})
+
+	// Unknown DSN parameters are passed on as run-time settings
+	if strings.Contains(dsn, "://") {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + "search_path=" + schema
+	} else {
+		dsn += " search_path=" + schema
+	}
+	db, err := Open(DriverPostgres, dsn)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// openPostgresTestStore opens a store on a migrated test database
+func openPostgresTestStore(t *testing.T) (*SQLStore, *sql.DB) {
+	t.Helper()
+	db := openPostgresTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	store, err := NewSQLStore(db)
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+	return store, db
+}
+
+func TestRebind(t *testing.T) {
+	query := `SELECT id FROM activities WHERE type = ? AND start_time >= ? AND name LIKE ?`
+	if got := rebind(DriverSQLite, query); got != query {
+		t.Errorf("SQLite query rewritten to %q", got)
+	}
+	want := `SELECT id FROM activities WHERE type = $1 AND start_time >= $2 AND name LIKE $3`
+	if got := rebind(DriverPostgres, query); got != want {
+		t.Errorf("rebind = %q, want %q", got, want)
+	}
+}
+
+func TestPostgresMigrate(t *testing.T) {
+	db := openPostgresTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	version, err := SchemaVersion(db)
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("schema version = %d, want %d", version, LatestSchemaVersion())
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM schema_version`); n != len(migrations) {
+		t.Errorf("%d migrations recorded, want %d", n, len(migrations))
+	}
+}
+
+func TestPostgresSaveActivity(t *testing.T) {
+	store, db := openPostgresTestStore(t)
+
+	fitID, err := store.SaveActivity(&gormin.Activity{
+		Name: "Run", Type: "running", StartTime: "2024-03-04 07:00:00",
+		Duration: 3590, Distance: 10.1, AvgHR: 151,
+	}, gormin.SourceFit)
+	if err != nil {
+		t.Fatalf("SaveActivity from FIT: %v", err)
+	}
+	if fitID == 0 {
+		t.Fatal("SaveActivity returned no id")
+	}
+
+	// Connect adopts the FIT-only row and wins on the totals it has
+	connectID, err := store.SaveActivity(&gormin.Activity{
+		ID: 9000000001, Name: "Morning Run", Type: "running", StartTime: "2024-03-04 07:00:00",
+		Duration: 3600, Distance: 10.2,
+	}, gormin.SourceConnect)
+	if err != nil {
+		t.Fatalf("SaveActivity from Connect: %v", err)
+	}
+	if connectID != fitID {
+		t.Errorf("Connect activity stored as %d, want the FIT row %d", connectID, fitID)
+	}
+
+	// Syncing again moves the start time into the next week
+	againID, err := store.SaveActivity(&gormin.Activity{
+		ID: 9000000001, Name: "Morning Run", Type: "running", StartTime: "2024-03-11 07:00:00",
+		Duration: 3600, Distance: 10.2,
+	}, gormin.SourceConnect)
+	if err != nil {
+		t.Fatalf("SaveActivity again: %v", err)
+	}
+	if againID != fitID {
+		t.Errorf("re-synced activity stored as %d, want %d", againID, fitID)
+	}
+
+	var name string
+	var duration, avgHR int
+	var distance float64
+	err = db.QueryRow(`SELECT name, duration, distance, avg_hr FROM activities WHERE id = $1`, fitID).
+		Scan(&name, &duration, &distance, &avgHR)
+	if err != nil {
+		t.Fatalf("query activity: %v", err)
+	}
+	if name != "Morning Run" || duration != 3600 || distance != 10.2 || avgHR != 151 {
+		t.Errorf("activity is %q, %d s, %.1f km, %d bpm; want Morning Run, 3600 s, 10.2 km, 151 bpm",
+			name, duration, distance, avgHR)
+	}
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM activities`); n != 1 {
+		t.Errorf("%d activities, want 1", n)
+	}
+
+	// The previous start time is read back as a string to update its week
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM activity_summaries WHERE period = 'week' AND period_key = '2024-W10'`); n != 0 {
+		t.Errorf("activity left in the summary of its previous week")
+	}
+	if n := queryInt(t, db, `SELECT activities FROM activity_summaries WHERE period = 'week' AND period_key = '2024-W11'`); n != 1 {
+		t.Errorf("week 11 summary counts %d activities, want 1", n)
+	}
+
+	details := &gormin.ActivityDetails{
+		Activity:    gormin.Activity{ID: 9000000001},
+		Description: "Easy run",
+		Splits:      []gormin.ActivitySplit{{Index: 1, StartTime: "2024-03-11 07:00:00", Distance: 1}},
+		HRZones:     []gormin.ZoneTime{{Zone: 2, Seconds: 1800}},
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.SaveActivityDetails(fitID, details); err != nil {
+			t.Fatalf("SaveActivityDetails: %v", err)
+		}
+	}
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM activity_splits WHERE activity_id = $1`, fitID); n != 1 {
+		t.Errorf("%d splits, want 1", n)
+	}
+}
+
+func TestPostgresHeartRateConflict(t *testing.T) {
+	store, db := openPostgresTestStore(t)
+
+	activityID, err := store.SaveActivity(&gormin.Activity{
+		Name: "Run", Type: "running", StartTime: "2024-03-04 07:00:00",
+	}, gormin.SourceFit)
+	if err != nil {
+		t.Fatalf("SaveActivity: %v", err)
+	}
+
+	samples := []gormin.HeartRateSample{
+		{Timestamp: "2024-03-04 07:00:00", HeartRate: 120},
+		{Timestamp: "2024-03-04 07:00:05", HeartRate: 125},
+	}
+	// All-day samples have no activity, so only the expression index on
+	// COALESCE(activity_id, 0) makes them conflict
+	for i := 0; i < 2; i++ {
+		if err := store.SaveHeartRate(samples, nil); err != nil {
+			t.Fatalf("SaveHeartRate: %v", err)
+		}
+		if err := store.SaveHeartRate(samples, &activityID); err != nil {
+			t.Fatalf("SaveHeartRate for activity: %v", err)
+		}
+	}
+
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM heart_rate WHERE activity_id IS NULL`); n != 2 {
+		t.Errorf("%d all-day samples, want 2", n)
+	}
+	if n := queryInt(t, db, `SELECT COUNT(*) FROM heart_rate WHERE activity_id = $1`, activityID); n != 2 {
+		t.Errorf("%d activity samples, want 2", n)
+	}
+}
+
+func TestPostgresTimeColumns(t *testing.T) {
+	store, _ := openPostgresTestStore(t)
+
+	activityID, err := store.SaveActivity(&gormin.Activity{
+		Name: "Run", Type: "running", StartTime: "2024-03-04 07:00:00",
+	}, gormin.SourceFit)
+	if err != nil {
+		t.Fatalf("SaveActivity: %v", err)
+	}
+	records := []gormin.ActivityRecord{
+		{Timestamp: "2024-03-04 07:00:00", HeartRate: 120},
+		{Timestamp: "2024-03-04 07:00:01", HeartRate: 122},
+	}
+	if err := store.SaveActivityRecords(activityID, records); err != nil {
+		t.Fatalf("SaveActivityRecords: %v", err)
+	}
+
+	// TIMESTAMP columns come back as RFC 3339 strings rather than as stored
+	loaded, err := store.ActivityRecords(activityID)
+	if err != nil {
+		t.Fatalf("ActivityRecords: %v", err)
+	}
+	if len(loaded) != len(records) {
+		t.Fatalf("%d records, want %d", len(loaded), len(records))
+	}
+	for i, record := range loaded {
+		got, err := time.Parse(time.RFC3339, record.Timestamp)
+		if err != nil {
+			t.Errorf("record timestamp %q: %v", record.Timestamp, err)
+			continue
+		}
+		want, _ := time.Parse("2006-01-02 15:04:05", records[i].Timestamp)
+		if !got.Equal(want) {
+			t.Errorf("record timestamp = %s, want %s", got, want)
+		}
+	}
+
+	if err := store.SaveRawResponse("activities", "1", []byte(`{"activityId":1}`)); err != nil {
+		t.Fatalf("SaveRawResponse: %v", err)
+	}
+	responses, err := store.RawResponses("activities")
+	if err != nil {
+		t.Fatalf("RawResponses: %v", err)
+	}
+	if len(responses) != 1 || string(responses[0].Body) != `{"activityId":1}` {
+		t.Fatalf("RawResponses = %+v", responses)
+	}
+	if _, err := time.Parse(time.RFC3339, responses[0].FetchedAt); err != nil {
+		t.Errorf("fetched_at %q: %v", responses[0].FetchedAt, err)
+	}
+	if endpoints, err := store.RawResponseEndpoints(); err != nil || len(endpoints) != 1 || endpoints[0] != "activities" {
+		t.Errorf("RawResponseEndpoints = %v, %v; want [activities]", endpoints, err)
+	}
+
+	// Ad-hoc queries format times like SQLite stores them
+	result, err := ReadOnlyQuery(t.Context(), store.DB(), `SELECT start_time FROM activities`)
+	if err != nil {
+		t.Fatalf("ReadOnlyQuery: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0] != "2024-03-04 07:00:00" {
+		t.Errorf("ReadOnlyQuery rows = %v, want [[2024-03-04 07:00:00]]", result.Rows)
+	}
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// QueryResult holds the columns and rows of an ad-hoc query. Values are nil,
+// int64, float64, bool or string; times are formatted like stored times.
+type QueryResult struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// ReadOnlyQuery runs an ad-hoc query that cannot change the database: SQLite
+// connections are switched to query_only for its duration and PostgreSQL runs
+// it in a read-only transaction
+func ReadOnlyQuery(ctx context.Context, db *sql.DB, query string) (*QueryResult, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	if driverName(db) == DriverSQLite {
+		if _, err := conn.ExecContext(ctx, `PRAGMA query_only = ON`); err != nil {
+			return nil, fmt.Errorf("failed to make connection read-only: %w", err)
+		}
+		defer func() {
+			// Never hand a read-only connection back to the pool
+			if _, err := conn.ExecContext(context.Background(), `PRAGMA query_only = OFF`); err != nil {
+				conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+			}
+		}()
+	}
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin read-only transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &QueryResult{Columns: columns}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			switch v := value.(type) {
+			case []byte:
+				values[i] = string(v)
+			case time.Time:
+				values[i] = v.Format("2006-01-02 15:04:05")
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result, rows.Err()
+}
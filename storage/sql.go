@@ -0,0 +1,1240 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// Database drivers supported by Open
+const (
+	DriverSQLite   = "sqlite3"
+	DriverPostgres = "postgres"
+)
+
+// Open opens a SQLite or PostgreSQL database and checks the connection. For
+// SQLite the data source name is the path of the database file.
+func Open(driver, dsn string) (*sql.DB, error) {
+	if driver != DriverSQLite && driver != DriverPostgres {
+		return nil, fmt.Errorf("unsupported database driver %q: expected %s or %s", driver, DriverSQLite, DriverPostgres)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Test connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return db, nil
+}
+
+// driverName returns which of the supported drivers db was opened with
+func driverName(db *sql.DB) string {
+	if _, ok := db.Driver().(*pq.Driver); ok {
+		return DriverPostgres
+	}
+	return DriverSQLite
+}
+
+// Rebind rewrites the ? placeholders of a query into the numbered $1, $2, ...
+// form PostgreSQL expects when db is a PostgreSQL database. Queries must not
+// contain a literal question mark.
+func Rebind(db *sql.DB, query string) string {
+	return rebind(driverName(db), query)
+}
+
+// rebind rewrites the placeholders of a query for driver
+func rebind(driver, query string) string {
+	if driver != DriverPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// nullIfEmpty maps an empty string to NULL, for optional time columns that
+// PostgreSQL would otherwise reject
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// SQLStore is the Store backed by a SQLite or PostgreSQL database. Queries
+// are written for both and rebound to the placeholders of the driver.
+type SQLStore struct {
+	db     *sql.DB
+	driver string
+
+	// Statements run once per sample, prepared up front
+	insertHeartRate      *sql.Stmt
+	insertActivityRecord *sql.Stmt
+}
+
+// NewSQLStore creates a store on an open, migrated database
+func NewSQLStore(db *sql.DB) (*SQLStore, error) {
+	s := &SQLStore{db: db, driver: driverName(db)}
+
+	var err error
+	s.insertHeartRate, err = db.Prepare(s.rebind(`INSERT INTO heart_rate (timestamp, heart_rate, activity_id) VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare heart rate statement: %w", err)
+	}
+	s.insertActivityRecord, err = db.Prepare(s.rebind(`INSERT INTO activity_records
+		(activity_id, timestamp, latitude, longitude, altitude, distance, heart_rate, speed, cadence, power)
+		VALUES (?, ?, NULLIF(?, 0), NULLIF(?, 0), ?, ?, NULLIF(?, 0), ?, NULLIF(?, 0), NULLIF(?, 0))
+		ON CONFLICT(activity_id, timestamp) DO UPDATE SET
+			latitude = excluded.latitude,
+			longitude = excluded.longitude,
+			altitude = excluded.altitude,
+			distance = excluded.distance,
+			heart_rate = excluded.heart_rate,
+			speed = excluded.speed,
+			cadence = excluded.cadence,
+			power = excluded.power`))
+	if err != nil {
+		s.insertHeartRate.Close()
+		return nil, fmt.Errorf("failed to prepare activity record statement: %w", err)
+	}
+
+	return s, nil
+}
+
+// DB returns the underlying database for ad-hoc reporting queries
+func (s *SQLStore) DB() *sql.DB {
+	return s.db
+}
+
+// rebind rewrites the placeholders of a query for the store's driver
+func (s *SQLStore) rebind(query string) string {
+	return rebind(s.driver, query)
+}
+
+// Close releases the prepared statements and closes the database
+func (s *SQLStore) Close() error {
+	s.insertHeartRate.Close()
+	s.insertActivityRecord.Close()
+	return s.db.Close()
+}
+
+// SaveActivity stores an activity and returns its local id. Activities with
+// a Connect id are upserted on garmin_activity_id, adopting a FIT-only row with
+// the same start time; FIT-only activities are upserted on the row with the
+// same start time. Connect is authoritative for names and totals, while FIT
+// files only fill in values that are missing. The summaries of the periods
+// containing the activity are updated with it.
+func (s *SQLStore) SaveActivity(activity *gormin.Activity, source string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to store activity: %w", err)
+	}
+	defer tx.Rollback()
+
+	var localID, garminID interface{}
+	var previousStart string
+	conflict := "garmin_activity_id"
+	if activity.ID != 0 {
+		garminID = activity.ID
+		_, err := tx.Exec(s.rebind(`UPDATE activities SET garmin_activity_id = ?
+			WHERE id = (SELECT id FROM activities WHERE garmin_activity_id IS NULL AND start_time = ? ORDER BY id LIMIT 1)
+			AND NOT EXISTS (SELECT 1 FROM activities WHERE garmin_activity_id = ?)`),
+			garminID, activity.StartTime, garminID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to match activity: %w", err)
+		}
+
+		// Connect may move the start time, taking the activity out of its summaries
+		err = tx.QueryRow(s.rebind(`SELECT start_time FROM activities WHERE garmin_activity_id = ?`), garminID).
+			Scan(&previousStart)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("failed to match activity: %w", err)
+		}
+	} else {
+		conflict = "id"
+		var id int64
+		err := tx.QueryRow(s.rebind(`SELECT id FROM activities WHERE start_time = ?
+			ORDER BY garmin_activity_id IS NULL, id LIMIT 1`), activity.StartTime).Scan(&id)
+		if err == nil {
+			localID = id
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("failed to match activity: %w", err)
+		}
+	}
+
+	update := `name = excluded.name,
+			type = excluded.type,
+			start_time = excluded.start_time,
+			duration = COALESCE(NULLIF(excluded.duration, 0), duration),
+			distance = COALESCE(NULLIF(excluded.distance, 0), distance),
+			calories = COALESCE(NULLIF(excluded.calories, 0), calories),
+			avg_hr = COALESCE(NULLIF(excluded.avg_hr, 0), avg_hr),
+			max_hr = COALESCE(NULLIF(excluded.max_hr, 0), max_hr),
+			elevation_gain = COALESCE(NULLIF(excluded.elevation_gain, 0), elevation_gain)`
+	if source == gormin.SourceFit {
+		update = `duration = COALESCE(NULLIF(duration, 0), excluded.duration),
+			distance = COALESCE(NULLIF(distance, 0), excluded.distance),
+			calories = COALESCE(NULLIF(calories, 0), excluded.calories),
+			avg_hr = COALESCE(NULLIF(avg_hr, 0), excluded.avg_hr),
+			max_hr = COALESCE(NULLIF(max_hr, 0), excluded.max_hr),
+			elevation_gain = COALESCE(NULLIF(elevation_gain, 0), excluded.elevation_gain)`
+	}
+
+	// Leave the id out of new rows so the database assigns it
+	columns, values := "", ""
+	args := []interface{}{}
+	if localID != nil {
+		columns, values = "id, ", "?, "
+		args = append(args, localID)
+	}
+	query := fmt.Sprintf(`INSERT INTO activities
+		(%sname, type, start_time, duration, distance, calories, avg_hr, max_hr, elevation_gain, garmin_activity_id)
+		VALUES (%s?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(%s) DO UPDATE SET %s
+		RETURNING id`, columns, values, conflict, update)
+
+	args = append(args,
+		activity.Name,
+		activity.Type,
+		activity.StartTime,
+		activity.Duration,
+		activity.Distance,
+		activity.Calories,
+		activity.AvgHR,
+		activity.MaxHR,
+		activity.ElevationGain,
+		garminID,
+	)
+	var id int64
+	if err := tx.QueryRow(s.rebind(query), args...).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to store activity: %w", err)
+	}
+	if err := refreshActivitySummaries(tx, s.driver, activity.StartTime, previousStart); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to store activity: %w", err)
+	}
+
+	fmt.Printf("Stored activity: %s (%.2f km, %d cal)\n",
+		activity.Name, activity.Distance, activity.Calories)
+	return int(id), nil
+}
+
+// SaveDailyStats stores daily statistics, replacing any existing row for the
+// date, and updates the summaries of the periods containing it. The values
+// derived from the time series are kept where stats has none.
+func (s *SQLStore) SaveDailyStats(stats *gormin.DailyStats) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to store daily stats: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO daily_stats
+		(date, steps, distance, calories, sleep_hours, resting_hr, weight, body_fat,
+		body_battery_min, body_battery_max, body_battery_avg, body_battery_charged, body_battery_drained,
+		stress_min, stress_avg, stress_max, respiration_min, respiration_max, respiration_avg)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(date) DO UPDATE SET
+			steps = excluded.steps,
+			distance = excluded.distance,
+			calories = excluded.calories,
+			sleep_hours = excluded.sleep_hours,
+			resting_hr = excluded.resting_hr,
+			weight = excluded.weight,
+			body_fat = excluded.body_fat,
+			body_battery_min = excluded.body_battery_min,
+			body_battery_max = excluded.body_battery_max,
+			body_battery_avg = COALESCE(excluded.body_battery_avg, daily_stats.body_battery_avg),
+			body_battery_charged = excluded.body_battery_charged,
+			body_battery_drained = excluded.body_battery_drained,
+			stress_min = COALESCE(excluded.stress_min, daily_stats.stress_min),
+			stress_avg = excluded.stress_avg,
+			stress_max = excluded.stress_max,
+			respiration_min = excluded.respiration_min,
+			respiration_max = excluded.respiration_max,
+			respiration_avg = excluded.respiration_avg`
+
+	_, err = tx.Exec(s.rebind(query),
+		stats.Date,
+		stats.Steps,
+		stats.Distance,
+		stats.Calories,
+		stats.SleepHours,
+		stats.RestingHR,
+		stats.Weight,
+		stats.BodyFat,
+		stats.BodyBatteryMin,
+		stats.BodyBatteryMax,
+		stats.BodyBatteryAvg,
+		stats.BodyBatteryCharged,
+		stats.BodyBatteryDrained,
+		stats.StressMin,
+		stats.StressAvg,
+		stats.StressMax,
+		stats.RespirationMin,
+		stats.RespirationMax,
+		stats.RespirationAvg,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to store daily stats: %w", err)
+	}
+	if err := refreshDailySummaries(tx, s.driver, stats.Date); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store daily stats: %w", err)
+	}
+
+	fmt.Printf("Stored daily stats: %s (%d steps)\n", stats.Date, stats.Steps)
+	return nil
+}
+
+// SaveSleep stores a night of sleep and its stage intervals, replacing
+// any existing data for the same date
+func (s *SQLStore) SaveSleep(sleep *gormin.SleepData) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to store sleep data: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(s.rebind(`DELETE FROM sleep_data WHERE date = ?`), sleep.Date); err != nil {
+		return fmt.Errorf("failed to store sleep data: %w", err)
+	}
+	if _, err := tx.Exec(s.rebind(`DELETE FROM sleep_levels WHERE date = ?`), sleep.Date); err != nil {
+		return fmt.Errorf("failed to store sleep levels: %w", err)
+	}
+
+	query := `INSERT INTO sleep_data
+		(date, start_time, end_time, duration, deep_sleep, light_sleep, rem_sleep, awake_time, sleep_score)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err = tx.Exec(s.rebind(query),
+		sleep.Date,
+		nullIfEmpty(sleep.StartTime),
+		nullIfEmpty(sleep.EndTime),
+		sleep.Duration,
+		sleep.DeepSleep,
+		sleep.LightSleep,
+		sleep.RemSleep,
+		sleep.AwakeTime,
+		sleep.SleepScore,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store sleep data: %w", err)
+	}
+
+	for _, level := range sleep.Levels {
+		_, err := tx.Exec(s.rebind(`INSERT INTO sleep_levels (date, start_time, end_time, stage) VALUES (?, ?, ?, ?)`),
+			sleep.Date, level.StartTime, level.EndTime, level.Stage)
+		if err != nil {
+			return fmt.Errorf("failed to store sleep levels: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store sleep data: %w", err)
+	}
+
+	fmt.Printf("Stored sleep data: %s (%.1f h, score %d)\n",
+		sleep.Date, float64(sleep.Duration)/3600.0, sleep.SleepScore)
+	return nil
+}
+
+// SaveHeartRate stores heart rate samples, optionally linked to an activity.
+// Samples already stored for the same timestamp and activity are skipped.
+func (s *SQLStore) SaveHeartRate(samples []gormin.HeartRateSample, activityID *int) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to store heart rate: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt := tx.Stmt(s.insertHeartRate)
+	defer stmt.Close()
+
+	for _, sample := range samples {
+		if _, err := stmt.Exec(sample.Timestamp, sample.HeartRate, activityID); err != nil {
+			return fmt.Errorf("failed to store heart rate: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store heart rate: %w", err)
+	}
+	return nil
+}
+
+// SaveWeight stores a weigh-in, updating the existing row with the same
+// timestamp. Body composition already stored is kept when the new entry has none.
+func (s *SQLStore) SaveWeight(entry *gormin.WeightEntry) error {
+	query := `INSERT INTO weight_data
+		(date, timestamp, weight, body_fat, muscle_mass, bone_mass, water_percentage)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(timestamp) DO UPDATE SET
+			date = excluded.date,
+			weight = excluded.weight,
+			body_fat = COALESCE(NULLIF(excluded.body_fat, 0), body_fat),
+			muscle_mass = COALESCE(NULLIF(excluded.muscle_mass, 0), muscle_mass),
+			bone_mass = COALESCE(NULLIF(excluded.bone_mass, 0), bone_mass),
+			water_percentage = COALESCE(NULLIF(excluded.water_percentage, 0), water_percentage)`
+
+	_, err := s.db.Exec(s.rebind(query),
+		entry.Date,
+		entry.Timestamp,
+		entry.Weight,
+		entry.BodyFat,
+		entry.MuscleMass,
+		entry.BoneMass,
+		entry.WaterPercentage,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to store weight: %w", err)
+	}
+
+	fmt.Printf("Stored weight: %s (%.1f kg)\n", entry.Timestamp, entry.Weight)
+	return nil
+}
+
+// SaveWellnessSamples stores a wellness time series into one of the
+// body_battery, stress or respiration tables
+func (s *SQLStore) SaveWellnessSamples(table string, samples []gormin.WellnessSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to store %s: %w", table, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(s.rebind(fmt.Sprintf(`INSERT INTO %s (timestamp, value, source) VALUES (?, ?, ?)
+		ON CONFLICT(timestamp, source) DO UPDATE SET value = excluded.value`, table)))
+	if err != nil {
+		return fmt.Errorf("failed to store %s: %w", table, err)
+	}
+	defer stmt.Close()
+
+	for _, sample := range samples {
+		if _, err := stmt.Exec(sample.Timestamp, sample.Value, sample.Source); err != nil {
+			return fmt.Errorf("failed to store %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store %s: %w", table, err)
+	}
+	return nil
+}
+
+// SaveHRVStatus stores the HRV status of a night, replacing any existing row
+func (s *SQLStore) SaveHRVStatus(hrv *gormin.HRVStatus) error {
+	query := `INSERT INTO hrv_status
+		(date, weekly_avg, last_night_avg, last_night_5min_high,
+		baseline_low_upper, baseline_balanced_low, baseline_balanced_upper, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(date) DO UPDATE SET
+			weekly_avg = excluded.weekly_avg,
+			last_night_avg = excluded.last_night_avg,
+			last_night_5min_high = excluded.last_night_5min_high,
+			baseline_low_upper = excluded.baseline_low_upper,
+			baseline_balanced_low = excluded.baseline_balanced_low,
+			baseline_balanced_upper = excluded.baseline_balanced_upper,
+			status = excluded.status`
+
+	_, err := s.db.Exec(s.rebind(query),
+		hrv.Date,
+		hrv.WeeklyAvg,
+		hrv.LastNightAvg,
+		hrv.LastNight5MinHigh,
+		hrv.BaselineLowUpper,
+		hrv.BaselineBalancedLow,
+		hrv.BaselineBalancedUpper,
+		hrv.Status,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to store HRV status: %w", err)
+	}
+
+	fmt.Printf("Stored HRV status: %s (%.0f ms, %s)\n", hrv.Date, hrv.LastNightAvg, hrv.Status)
+	return nil
+}
+
+// SavePulseOx stores the SpO2 averages of a day, replacing any existing row
+func (s *SQLStore) SavePulseOx(spo2 *gormin.PulseOx) error {
+	query := `INSERT INTO pulse_ox
+		(date, avg_spo2, lowest_spo2, avg_sleep_spo2, last_seven_days_avg)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(date) DO UPDATE SET
+			avg_spo2 = excluded.avg_spo2,
+			lowest_spo2 = excluded.lowest_spo2,
+			avg_sleep_spo2 = excluded.avg_sleep_spo2,
+			last_seven_days_avg = excluded.last_seven_days_avg`
+
+	_, err := s.db.Exec(s.rebind(query),
+		spo2.Date,
+		spo2.AvgSpO2,
+		spo2.LowestSpO2,
+		spo2.AvgSleepSpO2,
+		spo2.LastSevenDaysAvg,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to store pulse ox: %w", err)
+	}
+
+	fmt.Printf("Stored pulse ox: %s (%.0f%%)\n", spo2.Date, spo2.AvgSpO2)
+	return nil
+}
+
+// SaveActivityDetails stores the detail, splits, zones and weather of the
+// activity with local id activityID, replacing anything stored for it before
+func (s *SQLStore) SaveActivityDetails(activityID int, details *gormin.ActivityDetails) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to store activity details: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"activity_splits", "activity_zones", "activity_weather"} {
+		if _, err := tx.Exec(s.rebind(fmt.Sprintf(`DELETE FROM %s WHERE activity_id = ?`, table)), activityID); err != nil {
+			return fmt.Errorf("failed to store activity details: %w", err)
+		}
+	}
+
+	query := `INSERT INTO activity_details
+		(activity_id, description, privacy, moving_duration, elevation_loss, avg_speed, max_speed,
+		avg_power, max_power, avg_cadence, aerobic_te, anaerobic_te)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(activity_id) DO UPDATE SET
+			description = excluded.description,
+			privacy = excluded.privacy,
+			moving_duration = excluded.moving_duration,
+			elevation_loss = excluded.elevation_loss,
+			avg_speed = excluded.avg_speed,
+			max_speed = excluded.max_speed,
+			avg_power = excluded.avg_power,
+			max_power = excluded.max_power,
+			avg_cadence = excluded.avg_cadence,
+			aerobic_te = excluded.aerobic_te,
+			anaerobic_te = excluded.anaerobic_te`
+
+	_, err = tx.Exec(s.rebind(query),
+		activityID,
+		details.Description,
+		details.Privacy,
+		details.MovingDuration,
+		details.ElevationLoss,
+		details.AvgSpeed,
+		details.MaxSpeed,
+		details.AvgPower,
+		details.MaxPower,
+		details.AvgCadence,
+		details.AerobicTE,
+		details.AnaerobicTE,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store activity details: %w", err)
+	}
+
+	for _, split := range details.Splits {
+		_, err := tx.Exec(s.rebind(`INSERT INTO activity_splits
+			(activity_id, split_index, start_time, duration, distance, avg_speed, avg_hr, max_hr,
+			elevation_gain, elevation_loss, calories)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			activityID, split.Index, nullIfEmpty(split.StartTime), split.Duration, split.Distance, split.AvgSpeed,
+			split.AvgHR, split.MaxHR, split.ElevationGain, split.ElevationLoss, split.Calories)
+		if err != nil {
+			return fmt.Errorf("failed to store activity splits: %w", err)
+		}
+	}
+
+	zones := map[string][]gormin.ZoneTime{"hr": details.HRZones, "power": details.PowerZones}
+	for zoneType, zoneTimes := range zones {
+		for _, z := range zoneTimes {
+			_, err := tx.Exec(s.rebind(`INSERT INTO activity_zones (activity_id, zone_type, zone, seconds, low_boundary)
+				VALUES (?, ?, ?, ?, ?)`),
+				activityID, zoneType, z.Zone, z.Seconds, z.LowBoundary)
+			if err != nil {
+				return fmt.Errorf("failed to store activity zones: %w", err)
+			}
+		}
+	}
+
+	if w := details.Weather; w != nil {
+		_, err := tx.Exec(s.rebind(`INSERT INTO activity_weather
+			(activity_id, temperature, apparent_temperature, dew_point, humidity, wind_speed, wind_gust,
+			wind_direction, condition, issued_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			activityID, w.Temperature, w.ApparentTemperature, w.DewPoint, w.Humidity, w.WindSpeed,
+			w.WindGust, w.WindDirection, w.Condition, nullIfEmpty(w.IssuedAt))
+		if err != nil {
+			return fmt.Errorf("failed to store activity weather: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store activity details: %w", err)
+	}
+
+	fmt.Printf("Stored details for activity %d (%d splits)\n", activityID, len(details.Splits))
+	return nil
+}
+
+// SaveGear stores a gear item, replacing any existing row with the same uuid.
+// Missing lifetime totals are stored as NULL, so the synced activities stand in.
+func (s *SQLStore) SaveGear(gear *gormin.Gear) error {
+	query := `INSERT INTO gear
+		(uuid, name, make_model, type, status, date_begin, max_distance, total_distance, total_activities)
+		VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, 0), NULLIF(?, 0))
+		ON CONFLICT(uuid) DO UPDATE SET
+			name = excluded.name,
+			make_model = excluded.make_model,
+			type = excluded.type,
+			status = excluded.status,
+			date_begin = excluded.date_begin,
+			max_distance = excluded.max_distance,
+			total_distance = excluded.total_distance,
+			total_activities = excluded.total_activities`
+
+	_, err := s.db.Exec(s.rebind(query),
+		gear.UUID,
+		gear.Name,
+		gear.MakeModel,
+		gear.Type,
+		gear.Status,
+		gear.DateBegin,
+		gear.MaxDistance,
+		gear.TotalDistance,
+		gear.TotalActivities,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to store gear: %w", err)
+	}
+
+	fmt.Printf("Stored gear: %s (%s)\n", gear.Name, gear.Type)
+	return nil
+}
+
+// SaveDevice stores a device and returns its id. Devices are matched by
+// serial number, or by manufacturer and product when the serial is unknown;
+// fields missing from the new data keep their stored values.
+func (s *SQLStore) SaveDevice(device *gormin.Device, lastSeen string) (int64, error) {
+	var id int64
+	var err error
+	if device.SerialNumber != "" {
+		err = s.db.QueryRow(s.rebind(`SELECT id FROM devices WHERE serial_number = ?`), device.SerialNumber).Scan(&id)
+	} else {
+		err = s.db.QueryRow(s.rebind(`SELECT id FROM devices WHERE manufacturer = ? AND product = ? AND serial_number = ''`),
+			device.Manufacturer, device.Product).Scan(&id)
+	}
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err := s.db.QueryRow(s.rebind(`INSERT INTO devices
+			(manufacturer, product, product_name, serial_number, software_version, hardware_version,
+			battery_status, device_type, source, last_seen)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id`),
+			device.Manufacturer, device.Product, device.ProductName, device.SerialNumber,
+			device.SoftwareVersion, device.HardwareVersion, device.BatteryStatus, device.DeviceType,
+			device.Source, nullIfEmpty(lastSeen)).Scan(&id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to store device: %w", err)
+		}
+		return id, nil
+	case err != nil:
+		return 0, fmt.Errorf("failed to find device: %w", err)
+	}
+
+	// Only move software version, battery and last seen forward for newer
+	// data. Comparisons with an unknown time are NULL: the software version
+	// still updates, while battery and last seen only fill in missing values.
+	seen := nullIfEmpty(lastSeen)
+	_, err = s.db.Exec(s.rebind(`UPDATE devices SET
+			manufacturer = COALESCE(NULLIF(?, ''), manufacturer),
+			product = COALESCE(NULLIF(?, ''), product),
+			product_name = COALESCE(NULLIF(?, ''), product_name),
+			hardware_version = COALESCE(NULLIF(?, ''), hardware_version),
+			device_type = COALESCE(NULLIF(?, ''), device_type),
+			software_version = CASE WHEN COALESCE(? >= last_seen, TRUE)
+				THEN COALESCE(NULLIF(?, ''), software_version) ELSE software_version END,
+			battery_status = CASE WHEN COALESCE(? >= last_seen, last_seen IS NULL)
+				THEN COALESCE(NULLIF(?, ''), battery_status) ELSE battery_status END,
+			last_seen = CASE WHEN COALESCE(? > last_seen, last_seen IS NULL)
+				THEN ? ELSE last_seen END
+		WHERE id = ?`),
+		device.Manufacturer, device.Product, device.ProductName, device.HardwareVersion, device.DeviceType,
+		seen, device.SoftwareVersion,
+		seen, device.BatteryStatus,
+		seen, seen, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update device: %w", err)
+	}
+	return id, nil
+}
+
+// SaveActivityDevice stores a device and links it to the activity it recorded
+func (s *SQLStore) SaveActivityDevice(activityID int, device *gormin.Device, startTime string) error {
+	deviceID, err := s.SaveDevice(device, startTime)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(s.rebind(`INSERT INTO activity_devices
+		(activity_id, device_id, software_version, battery_status)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(activity_id, device_id) DO UPDATE SET
+			software_version = excluded.software_version,
+			battery_status = excluded.battery_status`),
+		activityID, deviceID, device.SoftwareVersion, device.BatteryStatus)
+	if err != nil {
+		return fmt.Errorf("failed to link device: %w", err)
+	}
+	return nil
+}
+
+// SaveWorkout stores a workout and its steps, replacing any existing steps.
+// Steps are flattened in order, with the steps of a repeat group pointing at
+// the group through parent_index.
+func (s *SQLStore) SaveWorkout(workout *gormin.Workout) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to store workout: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(s.rebind(`INSERT INTO workouts (id, name, sport, description, updated_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			sport = excluded.sport,
+			description = excluded.description,
+			updated_at = excluded.updated_at`),
+		workout.ID, workout.Name, workout.Sport, workout.Description)
+	if err != nil {
+		return fmt.Errorf("failed to store workout: %w", err)
+	}
+	if _, err := tx.Exec(s.rebind(`DELETE FROM workout_steps WHERE workout_id = ?`), workout.ID); err != nil {
+		return fmt.Errorf("failed to store workout steps: %w", err)
+	}
+
+	index := 0
+	var insertSteps func(steps []gormin.WorkoutStep, parent sql.NullInt64) error
+	insertSteps = func(steps []gormin.WorkoutStep, parent sql.NullInt64) error {
+		for _, step := range steps {
+			stepIndex := index
+			index++
+			_, err := tx.Exec(s.rebind(`INSERT INTO workout_steps
+				(workout_id, step_index, parent_index, type, duration_type, duration_value,
+				target_type, target_low, target_high, zone, repeat_count, description)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+				workout.ID, stepIndex, parent, step.Type, step.DurationType, step.DurationValue,
+				step.TargetType, step.TargetLow, step.TargetHigh, step.Zone, step.Repeat, step.Description)
+			if err != nil {
+				return fmt.Errorf("failed to store workout steps: %w", err)
+			}
+			if err := insertSteps(step.Steps, sql.NullInt64{Int64: int64(stepIndex), Valid: true}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := insertSteps(workout.Steps, sql.NullInt64{}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store workout: %w", err)
+	}
+
+	fmt.Printf("Stored workout: %s (%d steps)\n", workout.Name, index)
+	return nil
+}
+
+// Workout reads a stored workout, rebuilding its repeat groups
+func (s *SQLStore) Workout(workoutID int) (*gormin.Workout, error) {
+	workout := &gormin.Workout{ID: workoutID}
+	var sport, description sql.NullString
+	err := s.db.QueryRow(s.rebind(`SELECT name, sport, description FROM workouts WHERE id = ?`), workoutID).
+		Scan(&workout.Name, &sport, &description)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("workout %d not found", workoutID)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load workout: %w", err)
+	}
+	workout.Sport = sport.String
+	workout.Description = description.String
+
+	rows, err := s.db.Query(s.rebind(`SELECT step_index, parent_index, type, duration_type, duration_value,
+			target_type, target_low, target_high, zone, repeat_count, description
+		FROM workout_steps WHERE workout_id = ? ORDER BY step_index`), workoutID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workout steps: %w", err)
+	}
+	defer rows.Close()
+
+	type storedStep struct {
+		step   gormin.WorkoutStep
+		parent sql.NullInt64
+	}
+	var steps []storedStep
+	for rows.Next() {
+		var (
+			index                                     int
+			s                                         storedStep
+			durationType, targetType, stepDescription sql.NullString
+			durationValue, targetLow, targetHigh      sql.NullFloat64
+			zone, repeat                              sql.NullInt64
+		)
+		if err := rows.Scan(&index, &s.parent, &s.step.Type, &durationType, &durationValue,
+			&targetType, &targetLow, &targetHigh, &zone, &repeat, &stepDescription); err != nil {
+			return nil, fmt.Errorf("failed to read workout steps: %w", err)
+		}
+		s.step.DurationType = durationType.String
+		s.step.DurationValue = durationValue.Float64
+		s.step.TargetType = targetType.String
+		s.step.TargetLow = targetLow.Float64
+		s.step.TargetHigh = targetHigh.Float64
+		s.step.Zone = int(zone.Int64)
+		s.step.Repeat = int(repeat.Int64)
+		s.step.Description = stepDescription.String
+		steps = append(steps, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read workout steps: %w", err)
+	}
+
+	// Children always follow their repeat group, so build the tree bottom up
+	for i := len(steps) - 1; i >= 0; i-- {
+		if p := steps[i].parent; p.Valid && int(p.Int64) < i {
+			parent := &steps[p.Int64].step
+			parent.Steps = append([]gormin.WorkoutStep{steps[i].step}, parent.Steps...)
+		}
+	}
+	for _, s := range steps {
+		if !s.parent.Valid {
+			workout.Steps = append(workout.Steps, s.step)
+		}
+	}
+
+	return workout, nil
+}
+
+// ListWorkouts reads every stored workout with its steps, ordered by name
+func (s *SQLStore) ListWorkouts() ([]gormin.Workout, error) {
+	rows, err := s.db.Query(`SELECT id FROM workouts ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workouts: %w", err)
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read workouts: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read workouts: %w", err)
+	}
+
+	workouts := make([]gormin.Workout, 0, len(ids))
+	for _, id := range ids {
+		workout, err := s.Workout(id)
+		if err != nil {
+			return nil, err
+		}
+		workouts = append(workouts, *workout)
+	}
+	return workouts, nil
+}
+
+// SaveCourse stores a course and its points, replacing an existing course
+// with the same Connect id or file path, and sets the local id of the course
+func (s *SQLStore) SaveCourse(course *gormin.Course) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to store course: %w", err)
+	}
+	defer tx.Rollback()
+
+	var garminID, filePath interface{}
+	if course.GarminID != 0 {
+		garminID = course.GarminID
+	}
+	if course.FilePath != "" {
+		filePath = course.FilePath
+	}
+
+	var id int64
+	err = tx.QueryRow(s.rebind(`SELECT id FROM courses WHERE garmin_course_id = ? OR file_path = ?`), garminID, filePath).Scan(&id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err := tx.QueryRow(s.rebind(`INSERT INTO courses
+			(garmin_course_id, name, sport, description, distance, total_ascent, total_descent, source, file_path)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id`),
+			garminID, course.Name, course.Sport, course.Description, course.Distance,
+			course.TotalAscent, course.TotalDescent, course.Source, filePath).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to store course: %w", err)
+		}
+	case err != nil:
+		return fmt.Errorf("failed to find course: %w", err)
+	default:
+		_, err := tx.Exec(s.rebind(`UPDATE courses SET
+				name = ?, sport = ?, description = ?, distance = ?, total_ascent = ?, total_descent = ?, source = ?
+			WHERE id = ?`),
+			course.Name, course.Sport, course.Description, course.Distance,
+			course.TotalAscent, course.TotalDescent, course.Source, id)
+		if err != nil {
+			return fmt.Errorf("failed to update course: %w", err)
+		}
+		if _, err := tx.Exec(s.rebind(`DELETE FROM course_points WHERE course_id = ?`), id); err != nil {
+			return fmt.Errorf("failed to store course points: %w", err)
+		}
+	}
+
+	for i, p := range course.Points {
+		_, err := tx.Exec(s.rebind(`INSERT INTO course_points
+			(course_id, point_index, latitude, longitude, elevation, distance)
+			VALUES (?, ?, ?, ?, ?, ?)`),
+			id, i, p.Latitude, p.Longitude, p.Elevation, p.Distance)
+		if err != nil {
+			return fmt.Errorf("failed to store course points: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store course: %w", err)
+	}
+	course.ID = int(id)
+
+	fmt.Printf("Stored course: %s (%.2f km, %d m ascent)\n", course.Name, course.Distance, course.TotalAscent)
+	return nil
+}
+
+// courseColumns are the columns of a course read by scanCourse
+const courseColumns = `id, garmin_course_id, name, sport, description, distance,
+	total_ascent, total_descent, source, file_path`
+
+// scanCourse reads a course without its points from a row of courseColumns
+func scanCourse(row interface{ Scan(...interface{}) error }) (*gormin.Course, error) {
+	course := &gormin.Course{}
+	var (
+		garminID                         sql.NullInt64
+		sport, description, source, path sql.NullString
+		distance                         sql.NullFloat64
+		ascent, descent                  sql.NullInt64
+	)
+	err := row.Scan(&course.ID, &garminID, &course.Name, &sport, &description, &distance,
+		&ascent, &descent, &source, &path)
+	if err != nil {
+		return nil, err
+	}
+	course.GarminID = int(garminID.Int64)
+	course.Sport = sport.String
+	course.Description = description.String
+	course.Distance = distance.Float64
+	course.TotalAscent = int(ascent.Int64)
+	course.TotalDescent = int(descent.Int64)
+	course.Source = source.String
+	course.FilePath = path.String
+	return course, nil
+}
+
+// ListCourses reads every stored course without its points, ordered by name
+func (s *SQLStore) ListCourses() ([]gormin.Course, error) {
+	rows, err := s.db.Query(`SELECT ` + courseColumns + ` FROM courses ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query courses: %w", err)
+	}
+	defer rows.Close()
+
+	var courses []gormin.Course
+	for rows.Next() {
+		course, err := scanCourse(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read courses: %w", err)
+		}
+		courses = append(courses, *course)
+	}
+	return courses, rows.Err()
+}
+
+// Course reads a stored course with its points
+func (s *SQLStore) Course(courseID int) (*gormin.Course, error) {
+	course, err := scanCourse(s.db.QueryRow(s.rebind(`SELECT `+courseColumns+` FROM courses WHERE id = ?`), courseID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("course %d not found", courseID)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load course: %w", err)
+	}
+
+	rows, err := s.db.Query(s.rebind(`SELECT latitude, longitude, COALESCE(elevation, 0), COALESCE(distance, 0)
+		FROM course_points WHERE course_id = ? ORDER BY point_index`), courseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load course points: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p gormin.CoursePoint
+		if err := rows.Scan(&p.Latitude, &p.Longitude, &p.Elevation, &p.Distance); err != nil {
+			return nil, fmt.Errorf("failed to read course points: %w", err)
+		}
+		course.Points = append(course.Points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read course points: %w", err)
+	}
+
+	return course, nil
+}
+
+// SaveActivityRecords stores the recorded samples of an activity, replacing
+// any already stored for the same timestamps
+func (s *SQLStore) SaveActivityRecords(activityID int, records []gormin.ActivityRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to store activity records: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt := tx.Stmt(s.insertActivityRecord)
+	defer stmt.Close()
+
+	for _, r := range records {
+		_, err := stmt.Exec(activityID, r.Timestamp, r.Latitude, r.Longitude, r.Altitude,
+			r.Distance, r.HeartRate, r.Speed, r.Cadence, r.Power)
+		if err != nil {
+			return fmt.Errorf("failed to store activity records: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store activity records: %w", err)
+	}
+
+	fmt.Printf("Stored %d records for activity %d\n", len(records), activityID)
+	return nil
+}
+
+// ActivityRecords reads the recorded samples of an activity in time order
+func (s *SQLStore) ActivityRecords(activityID int) ([]gormin.ActivityRecord, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT timestamp, COALESCE(latitude, 0), COALESCE(longitude, 0), COALESCE(altitude, 0),
+			COALESCE(distance, 0), COALESCE(heart_rate, 0), COALESCE(speed, 0), COALESCE(cadence, 0), COALESCE(power, 0)
+		FROM activity_records WHERE activity_id = ? ORDER BY timestamp`), activityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load activity records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []gormin.ActivityRecord
+	for rows.Next() {
+		var r gormin.ActivityRecord
+		if err := rows.Scan(&r.Timestamp, &r.Latitude, &r.Longitude, &r.Altitude,
+			&r.Distance, &r.HeartRate, &r.Speed, &r.Cadence, &r.Power); err != nil {
+			return nil, fmt.Errorf("failed to read activity records: %w", err)
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// SavePersonalRecord stores a personal record, replacing the previous record
+// of the same type and source. Records from Connect are linked to the local
+// activity with their Connect activity id when it has been synced.
+func (s *SQLStore) SavePersonalRecord(record *gormin.PersonalRecord) error {
+	var activityID, garminID interface{}
+	if record.ActivityID != 0 {
+		activityID = record.ActivityID
+	}
+	if record.GarminActivityID != 0 {
+		garminID = record.GarminActivityID
+	}
+
+	_, err := s.db.Exec(s.rebind(`INSERT INTO personal_records
+		(record_type, source, value, activity_id, garmin_activity_id, achieved_at, updated_at)
+		VALUES (?, ?, ?, COALESCE(?, (SELECT id FROM activities WHERE garmin_activity_id = ? LIMIT 1)),
+			COALESCE(?, (SELECT garmin_activity_id FROM activities WHERE id = ?)), ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(record_type, source) DO UPDATE SET
+			value = excluded.value,
+			activity_id = excluded.activity_id,
+			garmin_activity_id = excluded.garmin_activity_id,
+			achieved_at = excluded.achieved_at,
+			updated_at = excluded.updated_at`),
+		record.RecordType, record.Source, record.Value,
+		activityID, garminID,
+		garminID, activityID,
+		nullIfEmpty(record.AchievedAt))
+	if err != nil {
+		return fmt.Errorf("failed to store personal record: %w", err)
+	}
+	return nil
+}
+
+// PersonalRecords reads the personal records from a source keyed by record type
+func (s *SQLStore) PersonalRecords(source string) (map[string]gormin.PersonalRecord, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT record_type, value, COALESCE(activity_id, 0), COALESCE(garmin_activity_id, 0),
+			achieved_at
+		FROM personal_records WHERE source = ?`), source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load personal records: %w", err)
+	}
+	defer rows.Close()
+
+	records := make(map[string]gormin.PersonalRecord)
+	for rows.Next() {
+		record := gormin.PersonalRecord{Source: source}
+		var achievedAt sql.NullString
+		if err := rows.Scan(&record.RecordType, &record.Value, &record.ActivityID,
+			&record.GarminActivityID, &achievedAt); err != nil {
+			return nil, fmt.Errorf("failed to read personal records: %w", err)
+		}
+		record.AchievedAt = achievedAt.String
+		records[record.RecordType] = record
+	}
+	return records, rows.Err()
+}
+
+// DeletePersonalRecords removes all personal records from a source
+func (s *SQLStore) DeletePersonalRecords(source string) error {
+	if _, err := s.db.Exec(s.rebind(`DELETE FROM personal_records WHERE source = ?`), source); err != nil {
+		return fmt.Errorf("failed to delete personal records: %w", err)
+	}
+	return nil
+}
+
+// SaveBestEfforts replaces the best efforts of an activity
+func (s *SQLStore) SaveBestEfforts(activityID int, efforts []gormin.BestEffort) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to store best efforts: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(s.rebind(`DELETE FROM best_efforts WHERE activity_id = ?`), activityID); err != nil {
+		return fmt.Errorf("failed to store best efforts: %w", err)
+	}
+	for _, effort := range efforts {
+		_, err := tx.Exec(s.rebind(`INSERT INTO best_efforts (activity_id, effort, value, start_time) VALUES (?, ?, ?, ?)`),
+			activityID, effort.Effort, effort.Value, effort.StartTime)
+		if err != nil {
+			return fmt.Errorf("failed to store best efforts: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store best efforts: %w", err)
+	}
+	return nil
+}
+
+// ActivitiesWithRecords returns the id and type of every activity with
+// stored records, oldest first
+func (s *SQLStore) ActivitiesWithRecords() ([]gormin.Activity, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT id, type FROM activities
+		WHERE id IN (SELECT DISTINCT activity_id FROM activity_records)
+		ORDER BY start_time`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activities: %w", err)
+	}
+	defer rows.Close()
+
+	var activities []gormin.Activity
+	for rows.Next() {
+		var activity gormin.Activity
+		if err := rows.Scan(&activity.ID, &activity.Type); err != nil {
+			return nil, fmt.Errorf("failed to read activities: %w", err)
+		}
+		activities = append(activities, activity)
+	}
+	return activities, rows.Err()
+}
+
+// LinkActivityGear records that a gear item was used for the activity with
+// local id activityID
+func (s *SQLStore) LinkActivityGear(activityID int, gearUUID string) error {
+	if _, err := s.db.Exec(s.rebind(`INSERT INTO gear_activities (gear_uuid, activity_id) VALUES (?, ?)
+		ON CONFLICT DO NOTHING`),
+		gearUUID, activityID); err != nil {
+		return fmt.Errorf("failed to link gear: %w", err)
+	}
+	return nil
+}
+
+// SaveRestingHeartRate sets the resting heart rate of a day already in daily_stats
+func (s *SQLStore) SaveRestingHeartRate(date string, restingHR int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to store resting heart rate: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(s.rebind(`UPDATE daily_stats SET resting_hr = ? WHERE date = ?`), restingHR, date); err != nil {
+		return fmt.Errorf("failed to store resting heart rate: %w", err)
+	}
+	if err := refreshDailySummaries(tx, s.driver, date); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store resting heart rate: %w", err)
+	}
+	return nil
+}
+
+// SaveDailyWellness sets the Body Battery average and stress minimum of a day
+// already in daily_stats, keeping the stored value of either that is nil
+func (s *SQLStore) SaveDailyWellness(date string, bodyBatteryAvg, stressMin *int) error {
+	_, err := s.db.Exec(s.rebind(`UPDATE daily_stats SET
+			body_battery_avg = COALESCE(?, body_battery_avg),
+			stress_min = COALESCE(?, stress_min)
+		WHERE date = ?`), bodyBatteryAvg, stressMin, date)
+	if err != nil {
+		return fmt.Errorf("failed to store daily wellness: %w", err)
+	}
+	return nil
+}
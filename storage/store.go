@@ -0,0 +1,103 @@
+// Package storage persists activity and health data behind the Store
+// interface, with a SQLite and PostgreSQL implementation managed by versioned
+// migrations and an in-memory implementation for tests.
+package storage
+
+import (
+	"github.com/saram12saram2/gormin"
+)
+
+// ActivityStore persists activities and the data recorded with them.
+// Activity ids are local ids unless documented otherwise.
+type ActivityStore interface {
+	// SaveActivity upserts an activity and returns its local id; source is
+	// SourceConnect or SourceFit
+	SaveActivity(activity *gormin.Activity, source string) (int, error)
+	SaveActivityDetails(activityID int, details *gormin.ActivityDetails) error
+	LinkActivityGear(activityID int, gearUUID string) error
+	SaveActivityRecords(activityID int, records []gormin.ActivityRecord) error
+	ActivityRecords(activityID int) ([]gormin.ActivityRecord, error)
+	// ActivitiesWithRecords returns the id and type of activities with records, oldest first
+	ActivitiesWithRecords() ([]gormin.Activity, error)
+	SaveActivityDevice(activityID int, device *gormin.Device, startTime string) error
+	SaveBestEfforts(activityID int, efforts []gormin.BestEffort) error
+}
+
+// HealthStore persists daily health and wellness data
+type HealthStore interface {
+	SaveDailyStats(stats *gormin.DailyStats) error
+	SaveRestingHeartRate(date string, restingHR int) error
+	// SaveDailyWellness sets the Body Battery average and stress minimum of a
+	// stored day, derived from its time series. Either is left as stored when
+	// nil, as for a series that was not fetched.
+	SaveDailyWellness(date string, bodyBatteryAvg, stressMin *int) error
+	SaveWeight(entry *gormin.WeightEntry) error
+	SaveSleep(sleep *gormin.SleepData) error
+	// SaveHeartRate stores samples, linked to the activity with local id
+	// activityID unless it is nil
+	SaveHeartRate(samples []gormin.HeartRateSample, activityID *int) error
+	// SaveWellnessSamples stores samples in the body_battery, stress or
+	// respiration series
+	SaveWellnessSamples(table string, samples []gormin.WellnessSample) error
+	SaveHRVStatus(hrv *gormin.HRVStatus) error
+	SavePulseOx(spo2 *gormin.PulseOx) error
+}
+
+// LibraryStore persists gear, devices, workouts and courses
+type LibraryStore interface {
+	SaveGear(gear *gormin.Gear) error
+	SaveDevice(device *gormin.Device, lastSeen string) (int64, error)
+	SaveWorkout(workout *gormin.Workout) error
+	Workout(workoutID int) (*gormin.Workout, error)
+	// ListWorkouts returns every workout with its steps, ordered by name
+	ListWorkouts() ([]gormin.Workout, error)
+	SaveCourse(course *gormin.Course) error
+	Course(courseID int) (*gormin.Course, error)
+	// ListCourses returns every course without its points, ordered by name
+	ListCourses() ([]gormin.Course, error)
+}
+
+// RecordStore persists personal records
+type RecordStore interface {
+	SavePersonalRecord(record *gormin.PersonalRecord) error
+	PersonalRecords(source string) (map[string]gormin.PersonalRecord, error)
+	DeletePersonalRecords(source string) error
+}
+
+// SummaryStore reads the weekly, monthly and yearly rollups of activities and
+// daily stats; period is PeriodWeek, PeriodMonth or PeriodYear
+type SummaryStore interface {
+	// ActivitySummaries returns the activity rollups of the last n periods,
+	// oldest first and by activity type within a period
+	ActivitySummaries(period string, n int) ([]gormin.ActivitySummary, error)
+	// DailySummaries returns the daily stats rollups of the last n periods, oldest first
+	DailySummaries(period string, n int) ([]gormin.DailySummary, error)
+	RebuildSummaries() error
+}
+
+// ArchiveStore persists the raw Garmin Connect responses, keeping the latest
+// response for each endpoint and key
+type ArchiveStore interface {
+	SaveRawResponse(endpoint, key string, body []byte) error
+	// RawResponses returns the archived responses of an endpoint ordered by key
+	RawResponses(endpoint string) ([]gormin.RawResponse, error)
+	// RawResponseEndpoints returns the endpoints with archived responses, sorted
+	RawResponseEndpoints() ([]string, error)
+}
+
+// Store is the persistence layer used by the FIT processor, sync code and commands
+type Store interface {
+	ActivityStore
+	HealthStore
+	LibraryStore
+	RecordStore
+	SummaryStore
+	ArchiveStore
+	Close() error
+}
+
+// Both implementations must satisfy Store
+var (
+	_ Store = (*SQLStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
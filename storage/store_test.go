@@ -0,0 +1,421 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// activityStore is a store under test with ways to seed and read back its
+// activities, which the Store interface has no methods for
+type activityStore struct {
+	Store
+	// insert adds rows as they are, without merging, as older versions stored them
+	insert func(t *testing.T, activities ...gormin.Activity)
+	// activities returns the stored activities by local id
+	activities func(t *testing.T) map[int]gormin.Activity
+}
+
+// activityStores returns an empty SQLite and memory store, which must match
+// and merge activities the same way
+func activityStores(t *testing.T) map[string]activityStore {
+	t.Helper()
+
+	db := openTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	sqlStore, err := NewSQLStore(db)
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+	memory := NewMemoryStore()
+
+	return map[string]activityStore{
+		"sqlite": {
+			Store: sqlStore,
+			insert: func(t *testing.T, activities ...gormin.Activity) {
+				t.Helper()
+				for _, a := range activities {
+					var garminID interface{}
+					if a.ID != 0 {
+						garminID = a.ID
+					}
+					_, err := db.Exec(`INSERT INTO activities
+						(name, type, start_time, duration, distance, calories, avg_hr, max_hr, elevation_gain, garmin_activity_id)
+						VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+						a.Name, a.Type, a.StartTime, a.Duration, a.Distance, a.Calories, a.AvgHR, a.MaxHR, a.ElevationGain, garminID)
+					if err != nil {
+						t.Fatalf("insert activity: %v", err)
+					}
+				}
+			},
+			activities: func(t *testing.T) map[int]gormin.Activity {
+				t.Helper()
+				rows, err := db.Query(`SELECT id, COALESCE(garmin_activity_id, 0), name, type, CAST(start_time AS TEXT),
+					duration, distance, calories, avg_hr, max_hr, elevation_gain FROM activities`)
+				if err != nil {
+					t.Fatalf("query activities: %v", err)
+				}
+				defer rows.Close()
+				result := make(map[int]gormin.Activity)
+				for rows.Next() {
+					var id int
+					var a gormin.Activity
+					err := rows.Scan(&id, &a.ID, &a.Name, &a.Type, &a.StartTime,
+						&a.Duration, &a.Distance, &a.Calories, &a.AvgHR, &a.MaxHR, &a.ElevationGain)
+					if err != nil {
+						t.Fatalf("scan activity: %v", err)
+					}
+					result[id] = a
+				}
+				return result
+			},
+		},
+		"memory": {
+			Store: memory,
+			insert: func(t *testing.T, activities ...gormin.Activity) {
+				for i := range activities {
+					memory.nextLocal++
+					memory.Activities[memory.nextLocal] = &activities[i]
+				}
+			},
+			activities: func(t *testing.T) map[int]gormin.Activity {
+				result := make(map[int]gormin.Activity)
+				for id, a := range memory.Activities {
+					result[id] = *a
+				}
+				return result
+			},
+		},
+	}
+}
+
+// saveActivity saves an activity, failing the test on error
+func saveActivity(t *testing.T, store Store, activity gormin.Activity, source string) int {
+	t.Helper()
+	id, err := store.SaveActivity(&activity, source)
+	if err != nil {
+		t.Fatalf("SaveActivity: %v", err)
+	}
+	return id
+}
+
+const testStartTime = "2024-03-04 07:00:00"
+
+func TestSaveActivityConnectAdoptsFitImport(t *testing.T) {
+	for name, store := range activityStores(t) {
+		t.Run(name, func(t *testing.T) {
+			fitID := saveActivity(t, store, gormin.Activity{
+				Name: "FIT running", Type: "running", StartTime: testStartTime,
+				Duration: 3590, Distance: 10.1, AvgHR: 151, MaxHR: 175, ElevationGain: 80,
+			}, gormin.SourceFit)
+			connectID := saveActivity(t, store, gormin.Activity{
+				ID: 9000000001, Name: "Morning Run", Type: "running", StartTime: testStartTime,
+				Duration: 3600, Distance: 10.2, Calories: 700,
+			}, gormin.SourceConnect)
+			if connectID != fitID {
+				t.Errorf("Connect activity stored as %d, want the FIT import %d", connectID, fitID)
+			}
+
+			activities := store.activities(t)
+			if len(activities) != 1 {
+				t.Fatalf("%d activities, want 1", len(activities))
+			}
+			// Connect wins on the values it has; FIT keeps the ones Connect lacks
+			want := gormin.Activity{
+				ID: 9000000001, Name: "Morning Run", Type: "running", StartTime: testStartTime,
+				Duration: 3600, Distance: 10.2, Calories: 700, AvgHR: 151, MaxHR: 175, ElevationGain: 80,
+			}
+			if got := activities[fitID]; got != want {
+				t.Errorf("activity = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestSaveActivityFitFillsConnectActivity(t *testing.T) {
+	for name, store := range activityStores(t) {
+		t.Run(name, func(t *testing.T) {
+			connectID := saveActivity(t, store, gormin.Activity{
+				ID: 9000000001, Name: "Morning Run", Type: "running", StartTime: testStartTime,
+				Duration: 3600, Distance: 10.2,
+			}, gormin.SourceConnect)
+			// Without the Connect id, as for a FIT file copied off the watch
+			fitID := saveActivity(t, store, gormin.Activity{
+				Name: "FIT running", Type: "training", StartTime: testStartTime,
+				Duration: 3590, Distance: 10.1, AvgHR: 151,
+			}, gormin.SourceFit)
+			if fitID != connectID {
+				t.Errorf("FIT import stored as %d, want the synced activity %d", fitID, connectID)
+			}
+
+			want := gormin.Activity{
+				ID: 9000000001, Name: "Morning Run", Type: "running", StartTime: testStartTime,
+				Duration: 3600, Distance: 10.2, AvgHR: 151,
+			}
+			if got := store.activities(t)[connectID]; got != want {
+				t.Errorf("activity = %+v, want %+v", got, want)
+			}
+
+			// Syncing again keeps what FIT filled in
+			saveActivity(t, store, gormin.Activity{
+				ID: 9000000001, Name: "Renamed Run", Type: "running", StartTime: testStartTime,
+				Duration: 3600, Distance: 10.2,
+			}, gormin.SourceConnect)
+			want.Name = "Renamed Run"
+			if got := store.activities(t)[connectID]; got != want {
+				t.Errorf("re-synced activity = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestSaveActivityMatchesOldestRow(t *testing.T) {
+	for name, store := range activityStores(t) {
+		t.Run(name, func(t *testing.T) {
+			// Duplicates left by imports before activities were merged
+			store.insert(t,
+				gormin.Activity{Name: "Ride", Type: "cycling", StartTime: "2024-03-05 17:30:00"},
+				gormin.Activity{Name: "FIT running", Type: "running", StartTime: testStartTime, Duration: 3590},
+				gormin.Activity{Name: "FIT running", Type: "running", StartTime: testStartTime, Duration: 3591},
+			)
+			byDuration := make(map[int]int)
+			for id, a := range store.activities(t) {
+				byDuration[a.Duration] = id
+			}
+			oldest, newer := byDuration[3590], byDuration[3591]
+
+			// A FIT import without Connect rows matches the oldest duplicate
+			if id := saveActivity(t, store, gormin.Activity{StartTime: testStartTime, AvgHR: 151}, gormin.SourceFit); id != oldest {
+				t.Errorf("FIT import stored as %d, want the oldest row %d", id, oldest)
+			}
+			// Connect adopts the oldest FIT-only row
+			connectID := saveActivity(t, store, gormin.Activity{
+				ID: 9000000001, Name: "Morning Run", Type: "running", StartTime: testStartTime, Duration: 3600,
+			}, gormin.SourceConnect)
+			if connectID != oldest {
+				t.Errorf("Connect activity stored as %d, want the oldest row %d", connectID, oldest)
+			}
+			activities := store.activities(t)
+			if len(activities) != 3 {
+				t.Fatalf("%d activities, want 3", len(activities))
+			}
+			if a := activities[connectID]; a.AvgHR != 151 || a.Duration != 3600 {
+				t.Errorf("merged activity = %+v", a)
+			}
+			if a := activities[newer]; a.ID != 0 || a.Duration != 3591 {
+				t.Errorf("newer duplicate changed to %+v", a)
+			}
+		})
+	}
+}
+
+func TestSaveActivityFitPrefersConnectRow(t *testing.T) {
+	for name, store := range activityStores(t) {
+		t.Run(name, func(t *testing.T) {
+			// A FIT-only row older than the synced row starting at the same time
+			store.insert(t,
+				gormin.Activity{Name: "FIT running", Type: "running", StartTime: testStartTime, Duration: 3590},
+				gormin.Activity{ID: 9000000001, Name: "Morning Run", Type: "running", StartTime: testStartTime, Duration: 3600},
+			)
+			var fitOnly, synced int
+			for id, a := range store.activities(t) {
+				if a.ID == 0 {
+					fitOnly = id
+				} else {
+					synced = id
+				}
+			}
+
+			if id := saveActivity(t, store, gormin.Activity{StartTime: testStartTime, MaxHR: 175}, gormin.SourceFit); id != synced {
+				t.Errorf("FIT import stored as %d, want the synced row %d", id, synced)
+			}
+			activities := store.activities(t)
+			if a := activities[synced]; a.MaxHR != 175 {
+				t.Errorf("synced activity max HR = %d, want 175", a.MaxHR)
+			}
+			if a := activities[fitOnly]; a.MaxHR != 0 {
+				t.Errorf("FIT-only activity max HR = %d, want it untouched", a.MaxHR)
+			}
+		})
+	}
+}
+
+func TestSaveActivityKeepsDistinctConnectActivities(t *testing.T) {
+	for name, store := range activityStores(t) {
+		t.Run(name, func(t *testing.T) {
+			first := saveActivity(t, store, gormin.Activity{
+				ID: 9000000001, Name: "Run", Type: "running", StartTime: testStartTime,
+			}, gormin.SourceConnect)
+			// Two activities recorded at once on different devices
+			second := saveActivity(t, store, gormin.Activity{
+				ID: 9000000002, Name: "Run", Type: "running", StartTime: testStartTime,
+			}, gormin.SourceConnect)
+			if first == second {
+				t.Errorf("both Connect activities stored as %d", first)
+			}
+
+			// Connect moving the start time updates the same row
+			moved := saveActivity(t, store, gormin.Activity{
+				ID: 9000000001, Name: "Run", Type: "running", StartTime: "2024-03-04 07:05:00",
+			}, gormin.SourceConnect)
+			if moved != first {
+				t.Errorf("moved activity stored as %d, want %d", moved, first)
+			}
+			if a := store.activities(t)[first]; a.StartTime != "2024-03-04 07:05:00" {
+				t.Errorf("start time = %s, want 2024-03-04 07:05:00", a.StartTime)
+			}
+			if n := len(store.activities(t)); n != 2 {
+				t.Errorf("%d activities, want 2", n)
+			}
+		})
+	}
+}
+
+func TestRawResponseEndpoints(t *testing.T) {
+	for name, store := range activityStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, endpoint := range []string{"daily_stats", "activities", "daily_stats"} {
+				if err := store.SaveRawResponse(endpoint, name, []byte(`{}`)); err != nil {
+					t.Fatalf("SaveRawResponse: %v", err)
+				}
+			}
+			endpoints, err := store.RawResponseEndpoints()
+			if err != nil || len(endpoints) != 2 || endpoints[0] != "activities" || endpoints[1] != "daily_stats" {
+				t.Errorf("RawResponseEndpoints = %v, %v; want [activities daily_stats]", endpoints, err)
+			}
+		})
+	}
+}
+
+func TestSaveDailyStatsKeepsDerivedWellness(t *testing.T) {
+	db := openTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	sqlStore, err := NewSQLStore(db)
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+	memory := NewMemoryStore()
+
+	nullInt := func(v *int) sql.NullInt64 {
+		if v == nil {
+			return sql.NullInt64{}
+		}
+		return sql.NullInt64{Int64: int64(*v), Valid: true}
+	}
+	stores := map[string]struct {
+		Store
+		derived func(t *testing.T) (bodyBatteryAvg, stressMin sql.NullInt64)
+	}{
+		"sqlite": {sqlStore, func(t *testing.T) (bodyBatteryAvg, stressMin sql.NullInt64) {
+			if err := db.QueryRow(`SELECT body_battery_avg, stress_min FROM daily_stats WHERE date = '2024-03-04'`).
+				Scan(&bodyBatteryAvg, &stressMin); err != nil {
+				t.Fatalf("query daily stats: %v", err)
+			}
+			return bodyBatteryAvg, stressMin
+		}},
+		"memory": {memory, func(t *testing.T) (sql.NullInt64, sql.NullInt64) {
+			stats := memory.DailyStats["2024-03-04"]
+			return nullInt(stats.BodyBatteryAvg), nullInt(stats.StressMin)
+		}},
+	}
+	value := func(v int) *int { return &v }
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			check := func(step string, bodyBatteryAvg, stressMin *int) {
+				t.Helper()
+				avg, min := store.derived(t)
+				if avg != nullInt(bodyBatteryAvg) || min != nullInt(stressMin) {
+					t.Errorf("%s: Body Battery average %v, stress minimum %v; want %v, %v",
+						step, avg, min, nullInt(bodyBatteryAvg), nullInt(stressMin))
+				}
+			}
+
+			stats := gormin.DailyStats{Date: "2024-03-04", Steps: 12000, StressAvg: 31, StressMax: 80}
+			if err := store.SaveDailyStats(&stats); err != nil {
+				t.Fatalf("SaveDailyStats: %v", err)
+			}
+			check("before the time series", nil, nil)
+
+			// A minimum of zero is a reading, not a missing value
+			if err := store.SaveDailyWellness("2024-03-04", value(54), value(0)); err != nil {
+				t.Fatalf("SaveDailyWellness: %v", err)
+			}
+			check("after the time series", value(54), value(0))
+
+			// Syncing the day's summary again keeps the derived values
+			stats.Steps = 12500
+			if err := store.SaveDailyStats(&stats); err != nil {
+				t.Fatalf("SaveDailyStats: %v", err)
+			}
+			check("after re-sync", value(54), value(0))
+
+			// A series that was not fetched keeps its value
+			if err := store.SaveDailyWellness("2024-03-04", nil, value(12)); err != nil {
+				t.Fatalf("SaveDailyWellness: %v", err)
+			}
+			check("without Body Battery", value(54), value(12))
+			if err := store.SaveDailyWellness("2024-03-04", value(60), nil); err != nil {
+				t.Fatalf("SaveDailyWellness: %v", err)
+			}
+			check("without stress", value(60), value(12))
+		})
+	}
+}
+
+func TestListWorkoutsAndCourses(t *testing.T) {
+	for name, store := range activityStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, w := range []gormin.Workout{
+				{ID: 12, Name: "Tempo", Sport: "running", Steps: []gormin.WorkoutStep{
+					{Type: "warmup", DurationType: "time", DurationValue: 600},
+					{Type: "repeat", Repeat: 3, Steps: []gormin.WorkoutStep{
+						{Type: "interval", DurationType: "distance", DurationValue: 1000},
+						{Type: "recovery", DurationType: "time", DurationValue: 90},
+					}},
+				}},
+				{ID: 11, Name: "Easy", Sport: "running", Steps: []gormin.WorkoutStep{
+					{Type: "interval", DurationType: "time", DurationValue: 1800},
+				}},
+			} {
+				if err := store.SaveWorkout(&w); err != nil {
+					t.Fatalf("SaveWorkout: %v", err)
+				}
+			}
+			workouts, err := store.ListWorkouts()
+			if err != nil {
+				t.Fatalf("ListWorkouts: %v", err)
+			}
+			if len(workouts) != 2 || workouts[0].Name != "Easy" || workouts[1].Name != "Tempo" {
+				t.Fatalf("ListWorkouts = %+v, want Easy and Tempo", workouts)
+			}
+			if steps := workouts[1].Steps; len(steps) != 2 || len(steps[1].Steps) != 2 {
+				t.Errorf("Tempo steps = %+v, want a warm-up and a repeat of 2", steps)
+			}
+
+			for _, c := range []gormin.Course{
+				{GarminID: 7, Name: "River loop", Sport: "running", Distance: 8.4, TotalAscent: 60, Source: "connect",
+					Points: []gormin.CoursePoint{{Latitude: 59.9, Longitude: 10.7}}},
+				{Name: "Hill climb", Sport: "cycling", Distance: 12.1, TotalAscent: 540, Source: "gpx", FilePath: "hill.gpx"},
+			} {
+				if err := store.SaveCourse(&c); err != nil {
+					t.Fatalf("SaveCourse: %v", err)
+				}
+			}
+			courses, err := store.ListCourses()
+			if err != nil {
+				t.Fatalf("ListCourses: %v", err)
+			}
+			if len(courses) != 2 || courses[0].Name != "Hill climb" || courses[1].Name != "River loop" {
+				t.Fatalf("ListCourses = %+v, want Hill climb and River loop", courses)
+			}
+			if c := courses[1]; c.GarminID != 7 || c.TotalAscent != 60 || c.Source != "connect" || c.Points != nil {
+				t.Errorf("River loop = %+v, want Connect id 7, 60 m ascent and no points", c)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+)
+
+// Periods summaries are kept for
+const (
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+	PeriodYear  = "year"
+)
+
+// summaryPeriods lists every period rollups are maintained for
+var summaryPeriods = []string{PeriodWeek, PeriodMonth, PeriodYear}
+
+// ValidPeriod reports whether period is one summaries are kept for
+func ValidPeriod(period string) bool {
+	for _, p := range summaryPeriods {
+		if p == period {
+			return true
+		}
+	}
+	return false
+}
+
+// periodRange returns the key of the period containing day, e.g. 2024-W07,
+// 2024-02 or 2024, with its first day and the first day after it. Weeks are
+// ISO weeks starting on Monday.
+func periodRange(period string, day time.Time) (key string, start, end time.Time) {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case PeriodWeek:
+		start = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		year, week := day.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week), start, start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		start = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01"), start, start.AddDate(0, 1, 0)
+	default:
+		start = time.Date(day.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		return start.Format("2006"), start, start.AddDate(1, 0, 0)
+	}
+}
+
+// summaryDays returns the distinct days of dates and timestamps, skipping
+// values without a date
+func summaryDays(values ...string) []time.Time {
+	seen := make(map[string]bool)
+	var days []time.Time
+	for _, value := range values {
+		if len(value) < 10 || seen[value[:10]] {
+			continue
+		}
+		day, err := time.Parse("2006-01-02", value[:10])
+		if err != nil {
+			continue
+		}
+		seen[value[:10]] = true
+		days = append(days, day)
+	}
+	return days
+}
+
+// refreshActivitySummaries recomputes the activity rollups of every period
+// containing one of startTimes from the activities table
+func refreshActivitySummaries(tx *sql.Tx, driver string, startTimes ...string) error {
+	for _, day := range summaryDays(startTimes...) {
+		for _, period := range summaryPeriods {
+			if err := refreshActivitySummary(tx, driver, period, day); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// refreshActivitySummary recomputes the activity rollups of one period. The
+// average heart rate is weighted by duration over activities with one, so a
+// short warm-up counts less than the long run after it.
+func refreshActivitySummary(tx *sql.Tx, driver, period string, day time.Time) error {
+	key, start, end := periodRange(period, day)
+
+	rows, err := tx.Query(rebind(driver, `SELECT type, COUNT(*), COALESCE(SUM(distance), 0),
+		COALESCE(SUM(duration), 0), COALESCE(SUM(elevation_gain), 0), COALESCE(SUM(calories), 0),
+		1.0 * SUM(CASE WHEN avg_hr > 0 THEN avg_hr * duration END) /
+			NULLIF(SUM(CASE WHEN avg_hr > 0 THEN duration END), 0)
+		FROM activities WHERE start_time >= ? AND start_time < ?
+		GROUP BY type`),
+		start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if err != nil {
+		return fmt.Errorf("failed to summarize activities: %w", err)
+	}
+	var summaries []gormin.ActivitySummary
+	for rows.Next() {
+		var summary gormin.ActivitySummary
+		var avgHR sql.NullFloat64
+		if err := rows.Scan(&summary.Type, &summary.Activities, &summary.Distance, &summary.Duration,
+			&summary.ElevationGain, &summary.Calories, &avgHR); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to summarize activities: %w", err)
+		}
+		summary.AvgHR = avgHR.Float64
+		summaries = append(summaries, summary)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to summarize activities: %w", err)
+	}
+
+	if _, err := tx.Exec(rebind(driver, `DELETE FROM activity_summaries WHERE period = ? AND period_key = ?`),
+		period, key); err != nil {
+		return fmt.Errorf("failed to store activity summary: %w", err)
+	}
+	for _, summary := range summaries {
+		var avgHR interface{}
+		if summary.AvgHR > 0 {
+			avgHR = summary.AvgHR
+		}
+		_, err := tx.Exec(rebind(driver, `INSERT INTO activity_summaries
+			(period, period_key, period_start, activity_type, activities, distance, duration,
+			elevation_gain, calories, avg_hr)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			period, key, start.Format("2006-01-02"), summary.Type, summary.Activities, summary.Distance,
+			summary.Duration, summary.ElevationGain, summary.Calories, avgHR)
+		if err != nil {
+			return fmt.Errorf("failed to store activity summary: %w", err)
+		}
+	}
+	return nil
+}
+
+// refreshDailySummaries recomputes the daily stats rollups of every period
+// containing one of dates from the daily_stats table
+func refreshDailySummaries(tx *sql.Tx, driver string, dates ...string) error {
+	for _, day := range summaryDays(dates...) {
+		for _, period := range summaryPeriods {
+			if err := refreshDailySummary(tx, driver, period, day); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// refreshDailySummary recomputes the daily stats rollup of one period
+func refreshDailySummary(tx *sql.Tx, driver, period string, day time.Time) error {
+	key, start, end := periodRange(period, day)
+
+	var summary gormin.DailySummary
+	var sleepHours, restingHR sql.NullFloat64
+	err := tx.QueryRow(rebind(driver, `SELECT COUNT(*), COALESCE(SUM(steps), 0), COALESCE(SUM(distance), 0),
+		COALESCE(SUM(calories), 0), AVG(NULLIF(sleep_hours, 0)), AVG(NULLIF(resting_hr, 0))
+		FROM daily_stats WHERE date >= ? AND date < ?`),
+		start.Format("2006-01-02"), end.Format("2006-01-02")).
+		Scan(&summary.Days, &summary.Steps, &summary.Distance, &summary.Calories, &sleepHours, &restingHR)
+	if err != nil {
+		return fmt.Errorf("failed to summarize daily stats: %w", err)
+	}
+
+	if _, err := tx.Exec(rebind(driver, `DELETE FROM daily_summaries WHERE period = ? AND period_key = ?`),
+		period, key); err != nil {
+		return fmt.Errorf("failed to store daily summary: %w", err)
+	}
+	if summary.Days == 0 {
+		return nil
+	}
+	_, err = tx.Exec(rebind(driver, `INSERT INTO daily_summaries
+		(period, period_key, period_start, days, steps, distance, calories, avg_sleep_hours, avg_resting_hr)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		period, key, start.Format("2006-01-02"), summary.Days, summary.Steps, summary.Distance,
+		summary.Calories, sleepHours, restingHR)
+	if err != nil {
+		return fmt.Errorf("failed to store daily summary: %w", err)
+	}
+	return nil
+}
+
+// rebuildSummaries recomputes every rollup from the activities and
+// daily_stats tables
+func rebuildSummaries(tx *sql.Tx, driver string) error {
+	for _, table := range []string{"activity_summaries", "daily_summaries"} {
+		if _, err := tx.Exec(`DELETE FROM ` + table); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+	}
+
+	startTimes, err := queryStrings(tx, `SELECT DISTINCT start_time FROM activities`)
+	if err != nil {
+		return fmt.Errorf("failed to query activities: %w", err)
+	}
+	dates, err := queryStrings(tx, `SELECT date FROM daily_stats`)
+	if err != nil {
+		return fmt.Errorf("failed to query daily stats: %w", err)
+	}
+
+	// Refresh each period once, however many days it has
+	refreshed := make(map[string]bool)
+	refresh := func(table string, days []time.Time, fn func(*sql.Tx, string, string, time.Time) error) error {
+		for _, day := range days {
+			for _, period := range summaryPeriods {
+				key, _, _ := periodRange(period, day)
+				if refreshed[table+" "+period+" "+key] {
+					continue
+				}
+				refreshed[table+" "+period+" "+key] = true
+				if err := fn(tx, driver, period, day); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := refresh("activity_summaries", summaryDays(startTimes...), refreshActivitySummary); err != nil {
+		return err
+	}
+	return refresh("daily_summaries", summaryDays(dates...), refreshDailySummary)
+}
+
+// queryStrings returns the first column of every row of a query
+func queryStrings(tx *sql.Tx, query string) ([]string, error) {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// RefreshActivitySummaries recomputes the activity rollups of the periods
+// containing startTime, for changes made outside the Store
+func RefreshActivitySummaries(db *sql.DB, startTime string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to refresh summaries: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := refreshActivitySummaries(tx, driverName(db), startTime); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RebuildSummaries recomputes every rollup from scratch
+func (s *SQLStore) RebuildSummaries() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to rebuild summaries: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := rebuildSummaries(tx, s.driver); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// summariesSince returns the first day of the last n periods up to today
+func summariesSince(period string, n int) string {
+	_, start, _ := periodRange(period, time.Now())
+	switch period {
+	case PeriodWeek:
+		start = start.AddDate(0, 0, -7*(n-1))
+	case PeriodMonth:
+		start = start.AddDate(0, -(n - 1), 0)
+	default:
+		start = start.AddDate(-(n - 1), 0, 0)
+	}
+	return start.Format("2006-01-02")
+}
+
+// ActivitySummaries returns the activity rollups of the last n periods,
+// oldest first and by activity type within a period
+func (s *SQLStore) ActivitySummaries(period string, n int) ([]gormin.ActivitySummary, error) {
+	if !ValidPeriod(period) {
+		return nil, fmt.Errorf("invalid period %q: expected week, month or year", period)
+	}
+
+	rows, err := s.db.Query(s.rebind(`SELECT period_key, period_start, activity_type, activities,
+		distance, duration, elevation_gain, calories, avg_hr
+		FROM activity_summaries WHERE period = ? AND period_start >= ?
+		ORDER BY period_start, activity_type`), period, summariesSince(period, n))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activity summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []gormin.ActivitySummary
+	for rows.Next() {
+		summary := gormin.ActivitySummary{Period: period}
+		var avgHR sql.NullFloat64
+		if err := rows.Scan(&summary.PeriodKey, &summary.PeriodStart, &summary.Type, &summary.Activities,
+			&summary.Distance, &summary.Duration, &summary.ElevationGain, &summary.Calories, &avgHR); err != nil {
+			return nil, fmt.Errorf("failed to read activity summaries: %w", err)
+		}
+		summary.AvgHR = avgHR.Float64
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+// DailySummaries returns the daily stats rollups of the last n periods, oldest first
+func (s *SQLStore) DailySummaries(period string, n int) ([]gormin.DailySummary, error) {
+	if !ValidPeriod(period) {
+		return nil, fmt.Errorf("invalid period %q: expected week, month or year", period)
+	}
+
+	rows, err := s.db.Query(s.rebind(`SELECT period_key, period_start, days, steps, distance, calories,
+		avg_sleep_hours, avg_resting_hr
+		FROM daily_summaries WHERE period = ? AND period_start >= ?
+		ORDER BY period_start`), period, summariesSince(period, n))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []gormin.DailySummary
+	for rows.Next() {
+		summary := gormin.DailySummary{Period: period}
+		var sleepHours, restingHR sql.NullFloat64
+		if err := rows.Scan(&summary.PeriodKey, &summary.PeriodStart, &summary.Days, &summary.Steps,
+			&summary.Distance, &summary.Calories, &sleepHours, &restingHR); err != nil {
+			return nil, fmt.Errorf("failed to read daily summaries: %w", err)
+		}
+		summary.AvgSleepHours = sleepHours.Float64
+		summary.AvgRestingHR = restingHR.Float64
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/saram12saram2/gormin"
+)
+
+func TestActivitySummaryWeightsHRByDuration(t *testing.T) {
+	db := openTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	store, err := NewSQLStore(db)
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+
+	for _, a := range []gormin.Activity{
+		{Name: "Intervals", Type: "running", StartTime: "2024-03-04 07:00:00", Duration: 1200, AvgHR: 165},
+		{Name: "Easy run", Type: "running", StartTime: "2024-03-06 07:00:00", Duration: 3600, AvgHR: 141},
+		// Activities without heart rate leave the average alone
+		{Name: "Treadmill", Type: "running", StartTime: "2024-03-07 07:00:00", Duration: 1800},
+		// Nor does a reading without a duration count
+		{Name: "Manual entry", Type: "running", StartTime: "2024-03-08 07:00:00", AvgHR: 100},
+	} {
+		saveActivity(t, store, a, gormin.SourceFit)
+	}
+
+	var activities int
+	var avgHR float64
+	if err := db.QueryRow(`SELECT activities, avg_hr FROM activity_summaries
+		WHERE period = 'week' AND activity_type = 'running'`).Scan(&activities, &avgHR); err != nil {
+		t.Fatalf("week summary: %v", err)
+	}
+	// (1200 * 165 + 3600 * 141) / 4800
+	if activities != 4 || avgHR != 147 {
+		t.Errorf("week summary = %d runs at %v bpm, want 4 at 147", activities, avgHR)
+	}
+}
+
+func TestSummariesMatchAcrossStores(t *testing.T) {
+	now := time.Now()
+	thisYear := time.Date(now.Year(), 1, 2, 7, 0, 0, 0, time.UTC)
+	lastYear := thisYear.AddDate(-1, 0, 0)
+	longAgo := thisYear.AddDate(-5, 0, 0)
+
+	results := make(map[string][]interface{})
+	for name, store := range activityStores(t) {
+		for _, a := range []gormin.Activity{
+			{Name: "Intervals", Type: "running", StartTime: thisYear.Format("2006-01-02 15:04:05"), Duration: 1200, Distance: 5, AvgHR: 165},
+			{Name: "Easy run", Type: "running", StartTime: thisYear.Add(24 * time.Hour).Format("2006-01-02 15:04:05"), Duration: 3600, Distance: 10, AvgHR: 141},
+			{Name: "Commute", Type: "cycling", StartTime: thisYear.Format("2006-01-02 08:00:00"), Duration: 1800, Distance: 12},
+			{Name: "Old run", Type: "running", StartTime: lastYear.Format("2006-01-02 15:04:05"), Duration: 3000, Distance: 9},
+			// Outside the periods asked for
+			{Name: "Ancient run", Type: "running", StartTime: longAgo.Format("2006-01-02 15:04:05"), Duration: 3000, Distance: 9},
+		} {
+			saveActivity(t, store, a, gormin.SourceFit)
+		}
+		for _, stats := range []gormin.DailyStats{
+			{Date: thisYear.Format("2006-01-02"), Steps: 10000, SleepHours: 7, RestingHR: 50},
+			{Date: thisYear.AddDate(0, 0, 1).Format("2006-01-02"), Steps: 6000, RestingHR: 54},
+			{Date: lastYear.Format("2006-01-02"), Steps: 8000},
+		} {
+			if err := store.SaveDailyStats(&stats); err != nil {
+				t.Fatalf("%s: SaveDailyStats: %v", name, err)
+			}
+		}
+		if err := store.RebuildSummaries(); err != nil {
+			t.Fatalf("%s: RebuildSummaries: %v", name, err)
+		}
+
+		activities, err := store.ActivitySummaries(PeriodYear, 2)
+		if err != nil {
+			t.Fatalf("%s: ActivitySummaries: %v", name, err)
+		}
+		daily, err := store.DailySummaries(PeriodYear, 2)
+		if err != nil {
+			t.Fatalf("%s: DailySummaries: %v", name, err)
+		}
+		results[name] = []interface{}{activities, daily}
+
+		if len(activities) != 3 {
+			t.Fatalf("%s: ActivitySummaries = %+v, want last year's runs and this year's rides and runs", name, activities)
+		}
+		if runs := activities[2]; runs.Type != "running" || runs.Activities != 2 || runs.Distance != 15 || runs.AvgHR != 147 {
+			t.Errorf("%s: this year's runs = %+v, want 2 runs of 15 km at 147 bpm", name, runs)
+		}
+		if len(daily) != 2 || daily[1].Days != 2 || daily[1].Steps != 16000 || daily[1].AvgSleepHours != 7 || daily[1].AvgRestingHR != 52 {
+			t.Errorf("%s: DailySummaries = %+v, want this year's 2 days with 16000 steps, 7 h sleep and 52 bpm", name, daily)
+		}
+	}
+
+	if !reflect.DeepEqual(results["sqlite"], results["memory"]) {
+		t.Errorf("SQLite summaries %+v differ from memory summaries %+v", results["sqlite"], results["memory"])
+	}
+}